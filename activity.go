@@ -4,6 +4,7 @@ import (
   "time"
   "strings"
   "fmt"
+  "encoding/json"
 )
 
 type Activity struct {
@@ -13,6 +14,16 @@ type Activity struct {
   Tags []string
   Start time.Time
   End time.Time
+  Pauses []Pause
+  Notes string
+  /* who recorded it, empty for activities from before users were tracked */
+  User string
+}
+
+/* break taken during an activity, end is zero while paused */
+type Pause struct {
+  Start time.Time
+  End time.Time
 }
 
 type Duration time.Duration
@@ -23,29 +34,144 @@ func (d Duration) String() string {
   return fmt.Sprintf("%02dh%02dm", hours, minutes)
 }
 
+/* decimal hours, for billing */
+func (d Duration) Hours() string {
+  return fmt.Sprintf("%.2f", float64(d) / float64(time.Hour))
+}
+
+/* rounding direction */
+type RoundingMode int
+
+const (
+  RoundNearest RoundingMode = iota
+  RoundUp
+  RoundDown
+)
+
+/* round to a multiple of unit; a zero unit leaves the duration alone */
+func (d Duration) Round(unit time.Duration, mode RoundingMode) Duration {
+  if unit <= 0 {
+    return d
+  }
+  remainder := d % Duration(unit)
+  if remainder == 0 {
+    return d
+  }
+  switch mode {
+  case RoundUp:
+    return d - remainder + Duration(unit)
+  case RoundDown:
+    return d - remainder
+  default:
+    if remainder * 2 >= Duration(unit) {
+      return d - remainder + Duration(unit)
+    }
+    return d - remainder
+  }
+}
+
 func (a *Activity) TagList() string {
   return strings.Join(a.Tags, ", ")
 }
 
 func (a *Activity) SetTagList(tagList string) {
+  a.Tags = splitTagList(tagList)
+}
+
+func splitTagList(tagList string) []string {
   if tagList == "" {
-    a.Tags = nil
-  } else {
-    a.Tags = strings.Split(tagList, ", ")
+    return nil
+  }
+  return strings.Split(tagList, ", ")
+}
+
+/* tags as the backends store them, a JSON array so any tag survives */
+func encodeTags(tags []string) string {
+  if len(tags) == 0 {
+    return ""
   }
+  data, _ := json.Marshal(tags)
+  return string(data)
+}
+
+func decodeTags(data string) (tags []string, err error) {
+  if data == "" {
+    return
+  }
+  err = json.Unmarshal([]byte(data), &tags)
+  if err == nil && len(tags) == 0 {
+    tags = nil
+  }
+  return
 }
 
 func (a *Activity) Duration(clock Clock) Duration {
   if a.IsRunning() {
-    return Duration(clock.Since(a.Start))
+    return Duration(clock.Since(a.Start)) - a.BreakTime(clock)
   }
-  return Duration(a.End.Sub(a.Start))
+  return Duration(a.End.Sub(a.Start)) - a.BreakTime(clock)
+}
+
+func (a *Activity) BreakTime(clock Clock) Duration {
+  var total time.Duration
+  for _, pause := range a.Pauses {
+    switch {
+    case !pause.End.IsZero():
+      total += pause.End.Sub(pause.Start)
+    case a.IsRunning():
+      total += clock.Since(pause.Start)
+    default:
+      total += a.End.Sub(pause.Start)
+    }
+  }
+  return Duration(total)
 }
 
 func (a *Activity) IsRunning() bool {
   return a.End.IsZero()
 }
 
+func (a *Activity) IsPaused() bool {
+  n := len(a.Pauses)
+  return a.IsRunning() && n > 0 && a.Pauses[n-1].End.IsZero()
+}
+
+func (a *Activity) Pause(t time.Time) {
+  if !a.IsPaused() {
+    a.Pauses = append(a.Pauses, Pause{Start: t})
+  }
+}
+
+func (a *Activity) Resume(t time.Time) {
+  if a.IsPaused() {
+    a.Pauses[len(a.Pauses)-1].End = t
+  }
+}
+
+/*
+ * stop the activity, ending any break that is still going on; breaks after
+ * an end in the past are dropped or cut short
+ */
+func (a *Activity) Stop(t time.Time) {
+  var pauses []Pause
+  for _, pause := range a.Pauses {
+    if pause.Start.After(t) {
+      continue
+    }
+    if pause.End.IsZero() || pause.End.After(t) {
+      pause.End = t
+    }
+    pauses = append(pauses, pause)
+  }
+  a.Pauses = pauses
+  a.End = t
+}
+
+/* running for longer than max, probably forgotten */
+func (a *Activity) IsOverdue(clock Clock, max time.Duration) bool {
+  return a.IsRunning() && max > 0 && clock.Since(a.Start) > max
+}
+
 func (a *Activity) Equal(b *Activity) bool {
   if a.Id != b.Id {
     return false
@@ -70,19 +196,61 @@ func (a *Activity) Equal(b *Activity) bool {
   if !a.End.Equal(b.End) {
     return false
   }
-  return true
+  if len(a.Pauses) != len(b.Pauses) {
+    return false
+  }
+  for i, pause := range a.Pauses {
+    if !b.Pauses[i].Start.Equal(pause.Start) || !b.Pauses[i].End.Equal(pause.End) {
+      return false
+    }
+  }
+  return a.Notes == b.Notes && a.User == b.User
+}
+
+/* notes are kept one per line */
+func (a *Activity) AddNote(note string) {
+  if a.Notes != "" {
+    a.Notes += "\n"
+  }
+  a.Notes += note
 }
 
 func (a *Activity) Status() string {
+  if a.IsPaused() {
+    return "paused"
+  }
   if a.IsRunning() {
     return "running"
   }
   return "stopped"
 }
 
+/* a copy with its times in the local time zone, the way databases return them */
+func (a *Activity) local() *Activity {
+  b := a.Clone()
+  b.Start = localTime(b.Start)
+  b.End = localTime(b.End)
+  for i, pause := range b.Pauses {
+    b.Pauses[i] = Pause{localTime(pause.Start), localTime(pause.End)}
+  }
+  return b
+}
+
+/* zero times are left alone, so they still compare equal to time.Time{} */
+func localTime(t time.Time) time.Time {
+  if t.IsZero() {
+    return t
+  }
+  return t.Local()
+}
+
 func (a *Activity) Clone() *Activity {
-  b := &Activity{a.Id, a.Name, a.Project, nil, a.Start, a.End}
+  b := &Activity{a.Id, a.Name, a.Project, nil, a.Start, a.End, nil, a.Notes, a.User}
   b.Tags = make([]string, len(a.Tags))
   copy(b.Tags, a.Tags)
+  if a.Pauses != nil {
+    b.Pauses = make([]Pause, len(a.Pauses))
+    copy(b.Pauses, a.Pauses)
+  }
   return b
 }
@@ -18,6 +18,12 @@ func (c fakeClock) Local(t time.Time) time.Time {
 func (c fakeClock) Since(t time.Time) time.Duration {
   return c.now.Sub(t)
 }
+/* fires right away */
+func (c fakeClock) After(d time.Duration) <-chan time.Time {
+  ch := make(chan time.Time, 1)
+  ch <- c.now.Add(d)
+  return ch
+}
 
 func TestActivity_Duration(t *testing.T) {
   c := fakeClock{time.Now()}
@@ -44,6 +50,94 @@ func TestActivity_Duration_WithNoEnd(t *testing.T) {
   }
 }
 
+func TestActivity_Duration_WithPauses(t *testing.T) {
+  c := fakeClock{time.Date(2013, time.April, 24, 17, 0, 0, 0, time.UTC)}
+  activity := Activity{Name: "foo"}
+  activity.Start = time.Date(2013, time.April, 24, 13, 0, 0, 0, time.UTC)
+  activity.Pauses = []Pause{
+    Pause{activity.Start.Add(time.Hour), activity.Start.Add(90 * time.Minute)},
+    Pause{activity.Start.Add(3 * time.Hour), time.Time{}},
+  }
+
+  /* 4 hours minus a half hour break and an hour long break so far */
+  expected := Duration(150 * time.Minute)
+  if result := activity.Duration(c); result != expected {
+    t.Error("expected", expected, "got", result)
+  }
+  expected = Duration(90 * time.Minute)
+  if result := activity.BreakTime(c); result != expected {
+    t.Error("expected", expected, "got", result)
+  }
+}
+
+func TestActivity_PauseAndResume(t *testing.T) {
+  start := time.Date(2013, time.April, 24, 13, 0, 0, 0, time.UTC)
+  activity := Activity{Name: "foo", Start: start}
+
+  activity.Pause(start.Add(time.Hour))
+  if !activity.IsPaused() {
+    t.Error("expected activity to be paused")
+  }
+  if activity.Status() != "paused" {
+    t.Errorf("expected 'paused', got '%s'", activity.Status())
+  }
+
+  /* pausing twice doesn't start another break */
+  activity.Pause(start.Add(2 * time.Hour))
+  if len(activity.Pauses) != 1 {
+    t.Errorf("expected 1 pause, got %d", len(activity.Pauses))
+  }
+
+  activity.Resume(start.Add(3 * time.Hour))
+  if activity.IsPaused() {
+    t.Error("expected activity not to be paused")
+  }
+  if !activity.Pauses[0].End.Equal(start.Add(3 * time.Hour)) {
+    t.Error("expected pause to end at", start.Add(3 * time.Hour), "got", activity.Pauses[0].End)
+  }
+}
+
+func TestActivity_Stop_WhilePaused(t *testing.T) {
+  start := time.Date(2013, time.April, 24, 13, 0, 0, 0, time.UTC)
+  activity := Activity{Name: "foo", Start: start}
+  activity.Pause(start.Add(time.Hour))
+  activity.Stop(start.Add(2 * time.Hour))
+
+  if activity.IsRunning() || activity.IsPaused() {
+    t.Error("expected activity to be stopped")
+  }
+  if !activity.Pauses[0].End.Equal(activity.End) {
+    t.Error("expected pause to end at", activity.End, "got", activity.Pauses[0].End)
+  }
+
+  /* breaks starting after the new end are dropped */
+  activity = Activity{Name: "foo", Start: start}
+  activity.Pause(start.Add(3 * time.Hour))
+  activity.Stop(start.Add(2 * time.Hour))
+  if len(activity.Pauses) != 0 {
+    t.Errorf("expected no pauses, got %v", activity.Pauses)
+  }
+}
+
+func TestActivity_Stop_BeforeBreaks(t *testing.T) {
+  start := time.Date(2013, time.April, 24, 13, 0, 0, 0, time.UTC)
+  activity := Activity{Name: "foo", Start: start}
+  activity.Pause(start.Add(time.Hour))
+  activity.Resume(start.Add(3 * time.Hour))
+  activity.Pause(start.Add(4 * time.Hour))
+  activity.Resume(start.Add(5 * time.Hour))
+  activity.Stop(start.Add(2 * time.Hour))
+
+  expected := []Pause{Pause{start.Add(time.Hour), start.Add(2 * time.Hour)}}
+  if len(activity.Pauses) != 1 || !activity.Pauses[0].End.Equal(expected[0].End) {
+    t.Errorf("expected pauses %v, got %v", expected, activity.Pauses)
+  }
+  c := fakeClock{start.Add(6 * time.Hour)}
+  if d := activity.Duration(c); d != Duration(time.Hour) {
+    t.Errorf("expected duration %v, got %v", Duration(time.Hour), d)
+  }
+}
+
 func TestActivity_IsRunning(t *testing.T) {
   activity := Activity{Name: "foo", Project: "bar", Start: time.Now()}
   if !activity.IsRunning() {
@@ -51,6 +145,25 @@ func TestActivity_IsRunning(t *testing.T) {
   }
 }
 
+func TestActivity_IsOverdue(t *testing.T) {
+  c := fakeClock{time.Now()}
+  activity := Activity{Name: "foo", Start: c.Now().Add(-11 * time.Hour)}
+  if !activity.IsOverdue(c, 10 * time.Hour) {
+    t.Error("expected activity to be overdue")
+  }
+  if activity.IsOverdue(c, 12 * time.Hour) {
+    t.Error("expected activity not to be overdue")
+  }
+  if activity.IsOverdue(c, 0) {
+    t.Error("expected activity not to be overdue without a limit")
+  }
+
+  activity.End = c.Now()
+  if activity.IsOverdue(c, 10 * time.Hour) {
+    t.Error("expected stopped activity not to be overdue")
+  }
+}
+
 func TestActivity_TagList(t *testing.T) {
   activity := Activity{Tags: []string{"foo", "bar", "baz"}}
   expected := "foo, bar, baz"
@@ -88,18 +201,46 @@ func TestActivity_SetTagList_WithEmptyString(t *testing.T) {
   }
 }
 
+var tagEncodingTests = [][]string{
+  nil,
+  []string{"foo"},
+  []string{"foo, bar", "baz"},
+  []string{"\"quoted\"", "back\\slash", "comma,"},
+}
+
+func TestEncodeTags(t *testing.T) {
+  for i, tags := range tagEncodingTests {
+    decoded, err := decodeTags(encodeTags(tags))
+    if err != nil {
+      t.Errorf("test %d: %s", i, err)
+      continue
+    }
+    a, b := Activity{Tags: tags}, Activity{Tags: decoded}
+    if !a.Equal(&b) {
+      t.Errorf("test %d: expected %q, got %q", i, tags, decoded)
+    }
+  }
+  if encodeTags(nil) != "" {
+    t.Errorf("expected no tags to be encoded as an empty string, got %q", encodeTags(nil))
+  }
+  _, err := decodeTags("foo, bar")
+  if err == nil {
+    t.Error("expected error decoding an old tag list")
+  }
+}
+
 func TestActivity_Equal(t *testing.T) {
   end := time.Now()
   start := end.Add(-time.Duration(time.Hour))
-  activity_1 := &Activity{1, "foo", "bar", []string{"baz"}, start, end}
-  activity_2 := &Activity{1, "foo", "bar", []string{"baz"}, start, end}
+  activity_1 := &Activity{1, "foo", "bar", []string{"baz"}, start, end, nil, "", ""}
+  activity_2 := &Activity{1, "foo", "bar", []string{"baz"}, start, end, nil, "", ""}
   if !activity_1.Equal(activity_2) {
     t.Error("expected activities to be equal")
   }
 }
 
 func TestActivity_Status(t *testing.T) {
-  activity := &Activity{1, "foo", "bar", []string{}, time.Now(), time.Time{}}
+  activity := &Activity{1, "foo", "bar", []string{}, time.Now(), time.Time{}, nil, "", ""}
   if activity.Status() != "running" {
     t.Errorf("expected 'running', got '%s'", activity.Status())
   }
@@ -113,7 +254,7 @@ func TestActivity_Status(t *testing.T) {
 func TestActivity_Clone(t *testing.T) {
   end := time.Now()
   start := end.Add(-time.Duration(time.Hour))
-  activity_1 := &Activity{1, "foo", "bar", []string{"baz"}, start, end}
+  activity_1 := &Activity{1, "foo", "bar", []string{"baz"}, start, end, nil, "", ""}
   activity_2 := activity_1.Clone()
 
   activity_2.Name = "qux"
@@ -142,3 +283,40 @@ func TestDuration_String(t *testing.T) {
     }
   }
 }
+
+var roundTests = []struct {
+  duration time.Duration
+  unit time.Duration
+  mode RoundingMode
+  output string
+  hours string
+}{
+  {62 * time.Minute, 15 * time.Minute, RoundUp, "01h15m", "1.25"},
+  {62 * time.Minute, 15 * time.Minute, RoundDown, "01h00m", "1.00"},
+  {62 * time.Minute, 15 * time.Minute, RoundNearest, "01h00m", "1.00"},
+  {68 * time.Minute, 15 * time.Minute, RoundNearest, "01h15m", "1.25"},
+  {63 * time.Minute, 6 * time.Minute, RoundNearest, "01h06m", "1.10"},
+  {60 * time.Minute, 6 * time.Minute, RoundUp, "01h00m", "1.00"},
+  {61 * time.Minute, 0, RoundUp, "01h01m", "1.02"},
+}
+
+func TestDuration_Round(t *testing.T) {
+  for i, config := range roundTests {
+    duration := Duration(config.duration).Round(config.unit, config.mode)
+    if duration.String() != config.output {
+      t.Errorf("test %d: expected '%s', got '%s'", i, config.output, duration.String())
+    }
+    if duration.Hours() != config.hours {
+      t.Errorf("test %d: expected '%s', got '%s'", i, config.hours, duration.Hours())
+    }
+  }
+}
+
+func TestActivity_AddNote(t *testing.T) {
+  activity := &Activity{}
+  activity.AddNote("foo")
+  activity.AddNote("bar")
+  if activity.Notes != "foo\nbar" {
+    t.Errorf("expected notes on separate lines, got %q", activity.Notes)
+  }
+}
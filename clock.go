@@ -8,6 +8,19 @@ type Clock interface {
   Since(time.Time) time.Duration
 }
 
+/* a clock that can also wait, which tests use to skip ahead */
+type Timer interface {
+  After(time.Duration) <-chan time.Time
+}
+
+/* wait with the clock if it can, otherwise in real time */
+func after(c Clock, d time.Duration) <-chan time.Time {
+  if timer, ok := c.(Timer); ok {
+    return timer.After(d)
+  }
+  return time.After(d)
+}
+
 type DefaultClock struct {}
 
 func (DefaultClock) Now() time.Time {
@@ -21,3 +34,7 @@ func (DefaultClock) Local(t time.Time) time.Time {
 func (DefaultClock) Since(t time.Time) time.Duration {
   return time.Since(t)
 }
+
+func (DefaultClock) After(d time.Duration) <-chan time.Time {
+  return time.After(d)
+}
@@ -34,3 +34,22 @@ func TestDefaultClock_Local(t *testing.T) {
     t.Error("expected '%s', got '%s'", when.Local(), clock.Local(when))
   }
 }
+
+func TestDefaultClock_After(t *testing.T) {
+  clock := DefaultClock{}
+  start := time.Now()
+  <-clock.After(10 * time.Millisecond)
+  if duration := time.Since(start); duration < 10 * time.Millisecond {
+    t.Errorf("expected to wait at least 10ms, waited %s", duration)
+  }
+}
+
+func TestAfter_WithoutTimer(t *testing.T) {
+  /* only the methods of Clock, so it can't wait by itself */
+  clock := struct{ Clock }{DefaultClock{}}
+  start := time.Now()
+  <-after(clock, 10 * time.Millisecond)
+  if duration := time.Since(start); duration < 10 * time.Millisecond {
+    t.Errorf("expected to wait at least 10ms, waited %s", duration)
+  }
+}
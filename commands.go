@@ -1,7 +1,12 @@
 package hourglass
 
 import (
+  "bufio"
+  "io"
+  "os"
   "time"
+  "errors"
+  "flag"
   "fmt"
   "sort"
   "strconv"
@@ -10,12 +15,16 @@ import (
 
 /* help messages */
 const (
-  startHelp = "Usage: %s start <name> [project] [tag1[, tag2[, ...]]]\n\nStart a new activity"
-  stopHelp = "Usage: %s stop\n\nStop all activities"
-  listHelp = "Usage: %s list [all|week]\n\nList activities"
-  editHelp = "Usage: %s edit <id> <name|project|tags|start|end> [value1[, [value2][, ...]]]\n\nEdit an activity\n\nFor the tags option, each tag should be a separate argument. Acceptable date formats are:\n\t2006-01-02 15:04\n\t2006-01-02 15:04 -0700"
+  startHelp = "Usage: %s start [--last | --from-git | <name|@template>] [project] [tag1[, tag2[, ...]]]\n\nStart a new activity\n\nWhen a template is given, the project and tags are taken from the template unless they are specified. With --from-git, the name is the current git branch and the project is the repository directory. A .hourglass file in the current directory or one of its parents can give a default project and tags, with lines like project: teamx and tag: backend. With the global -strict option, the project must have been added with the project command."
+  stopHelp = "Usage: %s stop [--at <date|time|max|eod|last-seen>]\n\nStop all activities\n\nA time of day given to --at is on the day each activity started. With last-seen, each activity is stopped at the last time anything was started, stopped, paused or resumed within the maximum running time after it started, or when it started if nothing was. The end of the day is set with the global -end-of-day option."
+  listHelp = "Usage: %s list [--budgets] [--user <name> | --all-users] [all|week]\n\nList activities\n\nWith --budgets, the week listing warns about budgets that have been exceeded (see goal). Only your own activities are listed unless another user is given with --user or --all-users is used."
+  editHelp = "Usage: %s edit <id> <name|project|tags|notes|start|end> [value1[, [value2][, ...]]]\n\nEdit an activity\n\nFor the tags option, each tag should be a separate argument. With the global -strict option, the project must have been added with the project command. Acceptable date formats are:\n\t2006-01-02 15:04\n\t2006-01-02 15:04 -0700\n\t2006-01-02T15:04:05-07:00"
   restartHelp = "Usage: %s restart <id>\n\nStart a new activity with all of the same values as another activity"
   deleteHelp = "Usage: %s delete <id>\n\nDelete an activity"
+  statusHelp = "Usage: %s status [--short] [--budgets]\n\nShow running activities\n\nWith --budgets, warn about budgets that have been exceeded (see goal). The exit status is 1 when nothing is running."
+  pauseHelp = "Usage: %s pause\n\nTake a break from all running activities"
+  resumeHelp = "Usage: %s resume\n\nResume all paused activities"
+  fixRunningHelp = "Usage: %s fix-running [max|eod|last-seen|ask]\n\nStop activities that have been running longer than the maximum running time\n\nBy default, activities are stopped after the maximum running time. Use eod to stop them at the end of the day they started instead, or last-seen to stop them at the last time anything was started, stopped, paused or resumed within the maximum running time after they started. With ask, you are asked when to stop each activity, and can answer with any of these, a date or time of day, or skip to leave it running."
 )
 
 /* edit date format */
@@ -23,14 +32,123 @@ const (
   DateFormat = "2006-01-02 15:04"
   DateWithZoneFormat = "2006-01-02 15:04 -0700"
   TimeFormat = "15:04"
+  QueryDateFormat = "2006-01-02"
 )
 
+var ErrNothingRunning = errors.New("nothing is running")
+
+/* running time limits */
+const (
+  DefaultMaxRunning = 10 * time.Hour
+  DefaultEndOfDay = 18 * time.Hour
+)
+
+func runningLimits(maxRunning, endOfDay time.Duration) (time.Duration, time.Duration) {
+  if maxRunning == 0 {
+    maxRunning = DefaultMaxRunning
+  }
+  if endOfDay == 0 {
+    endOfDay = DefaultEndOfDay
+  }
+  return maxRunning, endOfDay
+}
+
 /* syntax error */
 type SyntaxError string
 func (s SyntaxError) Error() string {
   return fmt.Sprint("syntax error: ", string(s))
 }
 
+/* date argument parsing */
+func parseDate(dateString string) (t time.Time, err error) {
+  t, err = time.ParseInLocation(DateFormat, dateString, time.Local)
+  if err != nil {
+    t, err = time.Parse(DateWithZoneFormat, dateString)
+  }
+  if err != nil {
+    t, err = time.Parse(time.RFC3339, dateString)
+  }
+  if err != nil {
+    err = SyntaxError("invalid date")
+  }
+  return
+}
+
+/*
+ * the last time anything was started, stopped, paused or resumed within the
+ * maximum running time after an activity started
+ */
+func lastSeen(db Database, activity *Activity, maxRunning time.Duration) (seen time.Time, err error) {
+  var activities []*Activity
+  activities, err = db.FindAllActivities()
+  if err != nil {
+    return
+  }
+
+  seen = activity.Start
+  limit := activity.Start.Add(maxRunning)
+  see := func(t time.Time) {
+    if t.After(seen) && !t.After(limit) {
+      seen = t
+    }
+  }
+  for _, other := range activities {
+    see(other.Start)
+    see(other.End)
+    for _, pause := range other.Pauses {
+      see(pause.Start)
+      see(pause.End)
+    }
+  }
+  return
+}
+
+/* find the time a running activity should be stopped at */
+func capTime(c Clock, db Database, activity *Activity, at string, maxRunning, endOfDay time.Duration) (end time.Time, err error) {
+  start := c.Local(activity.Start)
+  midnight := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0,
+    start.Location())
+
+  switch at {
+  case "max":
+    end = start.Add(maxRunning)
+  case "eod":
+    end = midnight.Add(endOfDay)
+    if !end.After(start) {
+      /* started after the end of the day */
+      end = start.Add(maxRunning)
+    }
+  case "last-seen":
+    end, err = lastSeen(db, activity, maxRunning)
+    if err != nil {
+      return
+    }
+  default:
+    var t time.Time
+    t, err = time.ParseInLocation(TimeFormat, at, start.Location())
+    if err == nil {
+      end = midnight.Add(time.Duration(t.Hour()) * time.Hour +
+        time.Duration(t.Minute()) * time.Minute)
+    } else {
+      end, err = parseDate(at)
+      if err != nil {
+        return
+      }
+    }
+    if end.Before(start) {
+      err = SyntaxError(fmt.Sprintf("activity %d started after %s",
+        activity.Id, end.Format(DateFormat)))
+      return
+    }
+  }
+
+  /* never stop an activity in the future */
+  if now := c.Now(); end.After(now) {
+    end = now
+  }
+  return
+}
+
 /* command interface */
 type Command interface {
   Run(c Clock, db Database, args ...string) (string, error)
@@ -38,37 +156,113 @@ type Command interface {
 }
 
 /* start */
-type StartCommand struct{}
+type StartCommand struct {
+  Templates *Templates
+  Last bool
+  FromGit bool
+  Strict bool
+  Git GitRunner
+  /* from the closest .hourglass file, if there is one */
+  Defaults *DirConfig
+}
+
+func (cmd *StartCommand) Flags(fs *flag.FlagSet) {
+  fs.BoolVar(&cmd.Last, "last", false,
+    "use the name, project and tags of the most recently started activity")
+  fs.BoolVar(&cmd.FromGit, "from-git", false,
+    "use the current git branch as the name and the repository directory as the project")
+}
+
+func (cmd StartCommand) Run(c Clock, db Database, args ...string) (output string, err error) {
+  var activity *Activity
+  activity, err = cmd.start(c, db, args...)
+  if err == nil {
+    output = fmt.Sprintf("started activity %d", activity.Id)
+  }
+  return
+}
 
-func (StartCommand) Run(c Clock, db Database, args ...string) (output string, err error) {
+/* start and save a new activity */
+func (cmd StartCommand) start(c Clock, db Database, args ...string) (activity *Activity, err error) {
   var name, project string
-  var tags []string
+  var tags, rest []string
 
-  if len(args) == 0 {
+  switch {
+  case cmd.Last:
+    var last *Activity
+    last, err = lastActivity(db)
+    if err != nil {
+      return
+    }
+    name, project, tags = last.Name, last.Project, last.Tags
+    rest = args
+
+  case cmd.FromGit:
+    git := cmd.Git
+    if git == nil {
+      git = DefaultGit
+    }
+    name, project, err = gitActivity(git)
+    if err != nil {
+      return
+    }
+    rest = args
+
+  case len(args) == 0:
     err = SyntaxError("missing name argument")
     return
+
+  case strings.HasPrefix(args[0], "@"):
+    if cmd.Templates == nil {
+      err = fmt.Errorf("templates are not available")
+      return
+    }
+    var template *Template
+    template, err = cmd.Templates.Find(args[0][1:])
+    if err == ErrNotFound {
+      err = fmt.Errorf("template %s doesn't exist", args[0])
+      return
+    } else if err != nil {
+      return
+    }
+    name, project, tags = template.ActivityName, template.Project, template.Tags
+    rest = args[1:]
+
+  default:
+    name = args[0]
+    rest = args[1:]
   }
 
-  for i, val := range args {
-    switch i {
-    case 0:
-      name = val
-    case 1:
-      project = val
-    case 2:
-      tags = args[2:]
-      break
+  /* positional arguments override the template or last activity */
+  if len(rest) > 0 {
+    project = rest[0]
+  }
+  if len(rest) > 1 {
+    tags = rest[1:]
+  }
+
+  /* the directory's defaults fill in whatever is still missing */
+  if cmd.Defaults != nil {
+    if project == "" {
+      project = cmd.Defaults.Project
+    }
+    if len(tags) == 0 {
+      tags = cmd.Defaults.Tags
+    }
+  }
+
+  if cmd.Strict {
+    err = checkProject(db, project)
+    if err != nil {
+      return
     }
   }
 
-  activity := &Activity{
+  activity = &Activity{
     Name: name, Project: project, Tags: tags,
     Start: c.Now(),
   }
   err = db.SaveActivity(activity)
-  if err == nil {
-    output = fmt.Sprintf("started activity %d", activity.Id)
-  }
   return
 }
 
@@ -76,6 +270,24 @@ func (StartCommand) Help() string {
   return startHelp
 }
 
+/* most recently started activity */
+func lastActivity(db Database) (last *Activity, err error) {
+  var activities []*Activity
+  activities, err = db.FindAllActivities()
+  if err != nil {
+    return
+  }
+  for _, activity := range activities {
+    if last == nil || !activity.Start.Before(last.Start) {
+      last = activity
+    }
+  }
+  if last == nil {
+    err = fmt.Errorf("there aren't any activities")
+  }
+  return
+}
+
 /* restart */
 type RestartCommand struct{}
 
@@ -99,7 +311,8 @@ func (RestartCommand) Run(c Clock, db Database, args ...string) (output string,
   activity.Id = 0
   activity.Start = c.Now()
   activity.End = time.Time{}
-  err = db.SaveActivity(activity)
+  activity.Pauses = nil
+  err = saveRestart(db, activity)
   if err == nil {
     output = fmt.Sprintf("restarted activity %d (new id: %d)", id, activity.Id)
   }
@@ -111,41 +324,328 @@ func (RestartCommand) Help() string {
 }
 
 /* stop */
-type StopCommand struct{}
+type StopCommand struct {
+  MaxRunning time.Duration
+  EndOfDay time.Duration
+  At string
+}
+
+func (cmd *StopCommand) Flags(fs *flag.FlagSet) {
+  fs.StringVar(&cmd.At, "at", "",
+    "stop at a date, a time of day, the maximum running time (max), the end of the day (eod) or the last time anything happened (last-seen)")
+}
+
+func (cmd StopCommand) Run(c Clock, db Database, args ...string) (output string, err error) {
+  /* dates given to --at can be split into separate arguments */
+  var at string
+  if cmd.At != "" {
+    at = strings.Join(append([]string{cmd.At}, args...), " ")
+  } else if len(args) > 0 {
+    err = SyntaxError("invalid arguments")
+    return
+  }
 
-func (StopCommand) Run(c Clock, db Database, args ...string) (output string, err error) {
   var activities []*Activity
+  activities, err = db.FindRunningActivities()
+  if err != nil {
+    return
+  }
 
-  end := c.Now()
-  if len(args) == 0 {
-    activities, err = db.FindRunningActivities()
+  /* figure out all end times before saving anything */
+  maxRunning, endOfDay := runningLimits(cmd.MaxRunning, cmd.EndOfDay)
+  ends := make([]time.Time, len(activities))
+  for i, activity := range activities {
+    if at == "" {
+      ends[i] = c.Now()
+    } else {
+      ends[i], err = capTime(c, db, activity, at, maxRunning, endOfDay)
+      if err != nil {
+        return
+      }
+    }
+  }
+
+  for i, activity := range activities {
+    activity.Stop(ends[i])
+    err = db.SaveActivity(activity)
     if err != nil {
       return
     }
-    for i, activity := range activities {
-      activity.End = end
-      err = db.SaveActivity(activity)
-      if err != nil {
-        return
+    if i > 0 {
+      output += "\n"
+    }
+    output += fmt.Sprintf("stopped activity %d", activity.Id)
+    if at != "" {
+      output += fmt.Sprint(" at ", activity.End.Format(DateFormat))
+    }
+  }
+
+  return
+}
+
+func (StopCommand) Help() string {
+  return stopHelp
+}
+
+/* status */
+type StatusCommand struct {
+  Short bool
+  Budgets bool
+}
+
+func (cmd *StatusCommand) Flags(fs *flag.FlagSet) {
+  fs.BoolVar(&cmd.Short, "short", false, "print a single line for shell prompts and status bars")
+  fs.BoolVar(&cmd.Budgets, "budgets", false, "warn about exceeded budgets")
+}
+
+func (cmd StatusCommand) Run(c Clock, db Database, args ...string) (output string, err error) {
+  if len(args) > 0 {
+    err = SyntaxError("too many arguments")
+    return
+  }
+
+  var activities []*Activity
+  activities, err = db.FindRunningActivities()
+  if err != nil {
+    return
+  }
+  if len(activities) == 0 {
+    if !cmd.Short {
+      output = "there aren't any running activities"
+    }
+    err = ErrNothingRunning
+    return
+  }
+
+  for i, activity := range activities {
+    if cmd.Short {
+      /* foo (bar) 01h00m, baz 00h10m paused */
+      if i > 0 {
+        output += ", "
+      }
+      output += activity.Name
+      if activity.Project != "" {
+        output += fmt.Sprintf(" (%s)", activity.Project)
       }
+      output += fmt.Sprint(" ", activity.Duration(c))
+      if activity.IsPaused() {
+        output += " paused"
+      }
+    } else {
       if i > 0 {
         output += "\n"
       }
-      output += fmt.Sprintf("stopped activity %d", activity.Id)
+      project := activity.Project
+      if project == "" {
+        project = "unsorted"
+      }
+      output += fmt.Sprintf("%s activity %d: %s (%s), %s", activity.Status(),
+        activity.Id, activity.Name, project, activity.Duration(c))
     }
   }
 
+  if cmd.Budgets && !cmd.Short {
+    var warnings []string
+    warnings, err = budgetWarnings(c, db)
+    if len(warnings) > 0 {
+      output += "\n" + strings.Join(warnings, "\n")
+    }
+  }
   return
 }
 
-func (StopCommand) Help() string {
-  return stopHelp
+func (StatusCommand) Help() string {
+  return statusHelp
+}
+
+/* pause */
+type PauseCommand struct{}
+
+func (PauseCommand) Run(c Clock, db Database, args ...string) (output string, err error) {
+  var activities []*Activity
+  activities, err = db.FindRunningActivities()
+  if err != nil {
+    return
+  }
+
+  now := c.Now()
+  for _, activity := range activities {
+    if activity.IsPaused() {
+      continue
+    }
+    activity.Pause(now)
+    err = db.SaveActivity(activity)
+    if err != nil {
+      return
+    }
+    if output != "" {
+      output += "\n"
+    }
+    output += fmt.Sprintf("paused activity %d", activity.Id)
+  }
+
+  if output == "" {
+    output = "there aren't any running activities"
+  }
+  return
+}
+
+func (PauseCommand) Help() string {
+  return pauseHelp
+}
+
+/* resume */
+type ResumeCommand struct{}
+
+func (ResumeCommand) Run(c Clock, db Database, args ...string) (output string, err error) {
+  var activities []*Activity
+  activities, err = db.FindRunningActivities()
+  if err != nil {
+    return
+  }
+
+  now := c.Now()
+  for _, activity := range activities {
+    if !activity.IsPaused() {
+      continue
+    }
+    activity.Resume(now)
+    err = db.SaveActivity(activity)
+    if err != nil {
+      return
+    }
+    if output != "" {
+      output += "\n"
+    }
+    output += fmt.Sprintf("resumed activity %d", activity.Id)
+  }
+
+  if output == "" {
+    output = "there aren't any paused activities"
+  }
+  return
+}
+
+func (ResumeCommand) Help() string {
+  return resumeHelp
+}
+
+/* fix-running */
+type FixRunningCommand struct {
+  MaxRunning time.Duration
+  EndOfDay time.Duration
+  /* where questions are asked and answered, standard input and output by default */
+  In io.Reader
+  Out io.Writer
+}
+
+func (cmd FixRunningCommand) Run(c Clock, db Database, args ...string) (output string, err error) {
+  at := "max"
+  if len(args) > 0 {
+    at = args[0]
+    if (at != "max" && at != "eod" && at != "last-seen" && at != "ask") || len(args) > 1 {
+      err = SyntaxError("invalid arguments")
+      return
+    }
+  }
+  var answers *bufio.Reader
+  if at == "ask" {
+    if cmd.In == nil {
+      cmd.In = os.Stdin
+    }
+    if cmd.Out == nil {
+      cmd.Out = os.Stdout
+    }
+    answers = bufio.NewReader(cmd.In)
+  }
+
+  var activities []*Activity
+  activities, err = db.FindRunningActivities()
+  if err != nil {
+    return
+  }
+
+  maxRunning, endOfDay := runningLimits(cmd.MaxRunning, cmd.EndOfDay)
+  for _, activity := range activities {
+    if !activity.IsOverdue(c, maxRunning) {
+      continue
+    }
+    var end time.Time
+    if answers == nil {
+      end, err = capTime(c, db, activity, at, maxRunning, endOfDay)
+    } else {
+      end, err = cmd.ask(c, db, answers, activity, maxRunning, endOfDay)
+    }
+    if err == io.EOF {
+      /* nothing more to go on */
+      err = nil
+      break
+    } else if err != nil {
+      return
+    }
+    if output != "" {
+      output += "\n"
+    }
+    if end.IsZero() {
+      output += fmt.Sprintf("left activity %d running", activity.Id)
+      continue
+    }
+    activity.Stop(end)
+    err = db.SaveActivity(activity)
+    if err != nil {
+      return
+    }
+    output += fmt.Sprintf("stopped activity %d at %s", activity.Id,
+      activity.End.Format(DateFormat))
+  }
+
+  if output == "" {
+    output = fmt.Sprint("no activities have been running longer than ",
+      Duration(maxRunning))
+  }
+  return
+}
+
+/* ask when to stop an activity, with a zero time to skip it */
+func (cmd FixRunningCommand) ask(c Clock, db Database, answers *bufio.Reader, activity *Activity,
+  maxRunning, endOfDay time.Duration) (end time.Time, err error) {
+
+  fmt.Fprintf(cmd.Out, "activity %d (%s) has been running since %s\n", activity.Id, activity.Name,
+    c.Local(activity.Start).Format(DateFormat))
+  for {
+    fmt.Fprint(cmd.Out, "stop at max, eod, last-seen, a date or time, or skip [max]: ")
+    var answer string
+    answer, err = answers.ReadString('\n')
+    answer = strings.TrimSpace(answer)
+    if err == io.EOF && answer != "" {
+      err = nil
+    } else if err != nil {
+      return
+    }
+
+    switch answer {
+    case "":
+      answer = "max"
+    case "skip":
+      return
+    }
+    end, err = capTime(c, db, activity, answer, maxRunning, endOfDay)
+    if _, ok := err.(SyntaxError); !ok {
+      return
+    }
+    fmt.Fprintln(cmd.Out, err)
+  }
+}
+
+func (FixRunningCommand) Help() string {
+  return fixRunningHelp
 }
 
 /* project duration, needed for sorting */
 type projectDuration struct {
   name string
   duration Duration
+  amounts MoneyList
 }
 type projectDurationList struct {
   slice []*projectDuration
@@ -178,12 +678,21 @@ func (pdl *projectDurationList) add(name string, duration Duration) {
     }
   }
   if pd == nil {
-    pdl.slice = append(pdl.slice, &projectDuration{name, duration})
+    pdl.slice = append(pdl.slice, &projectDuration{name: name, duration: duration})
     sort.Sort(pdl)
   } else {
     pd.duration += duration
   }
 }
+/* amounts are added after the project's duration */
+func (pdl *projectDurationList) addAmount(name string, m Money) {
+  for _, pd := range pdl.slice {
+    if pd.name == name {
+      pd.amounts = pd.amounts.Add(m)
+      return
+    }
+  }
+}
 func (pdl *projectDurationList) String() (str string) {
   for i, pd := range pdl.slice {
     if i > 0 {
@@ -196,52 +705,110 @@ func (pdl *projectDurationList) String() (str string) {
       name = pd.name
     }
     str += fmt.Sprint(name, ": ", pd.duration)
+    if len(pd.amounts) > 0 {
+      str += fmt.Sprintf(" (%s)", pd.amounts)
+    }
   }
   return
 }
 
+/* midnight today to midnight tomorrow */
+func dayRange(now time.Time) (lower, upper time.Time) {
+  lower = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+  upper = lower.AddDate(0, 0, 1)
+  return
+}
+
+/* midnight Sunday to midnight Sunday next week */
+func weekRange(now time.Time) (lower, upper time.Time) {
+  /* NOTE: zero and negative days work just fine here */
+  lower = time.Date(now.Year(), now.Month(),
+    now.Day() - int(now.Weekday()), 0, 0, 0, 0, now.Location())
+  upper = time.Date(now.Year(), now.Month(),
+    now.Day() + (7 - int(now.Weekday())), 0, 0, 0, 0, now.Location())
+  return
+}
+
+/* range arguments: today, week, all or two dates; all gives zero times */
+func parseRange(now time.Time, args []string) (lower, upper time.Time, err error) {
+  switch {
+  case len(args) == 0 || len(args) == 1 && args[0] == "all":
+  case len(args) == 1 && args[0] == "today":
+    lower, upper = dayRange(now)
+  case len(args) == 1 && args[0] == "week":
+    lower, upper = weekRange(now)
+  case len(args) == 2:
+    lower, err = time.ParseInLocation(QueryDateFormat, args[0], now.Location())
+    if err == nil {
+      upper, err = time.ParseInLocation(QueryDateFormat, args[1], now.Location())
+    }
+    if err != nil {
+      err = SyntaxError("invalid date in range")
+      return
+    }
+    /* include the whole last day */
+    upper = upper.AddDate(0, 0, 1)
+  default:
+    err = SyntaxError("invalid range")
+  }
+  return
+}
+
+/* activities in a range from parseRange */
+func findActivitiesInRange(db Database, lower, upper time.Time) ([]*Activity, error) {
+  if lower.IsZero() && upper.IsZero() {
+    return db.FindAllActivities()
+  }
+  return db.FindActivitiesBetween(lower, upper)
+}
+
 /* list */
-type ListCommand struct{}
+type ListCommand struct {
+  MaxRunning time.Duration
+  Budgets bool
+  UserOptions
+}
+
+func (cmd *ListCommand) Flags(fs *flag.FlagSet) {
+  fs.BoolVar(&cmd.Budgets, "budgets", false, "warn about exceeded budgets in the week listing")
+  cmd.flags(fs)
+}
 
 func (cmd ListCommand) Run(c Clock, db Database, args ...string) (output string, err error) {
-  if len(args) == 0 {
-    now := c.Now()
+  maxRunning, _ := runningLimits(cmd.MaxRunning, 0)
+  var rates []*Rate
+  rates, err = db.FindRates()
+  if err != nil {
+    return
+  }
 
-    /* midnight today */
-    lower := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
-    /* midnight tomorrow */
-    upper := lower.Add(time.Hour * 24)
+  if len(args) == 0 {
+    lower, upper := dayRange(c.Now())
 
     var activities []*Activity
-    activities, err = db.FindActivitiesBetween(lower, upper)
+    activities, err = cmd.database(db).FindActivitiesBetween(lower, upper)
     if err != nil {
       return
     }
+    activities = cmd.filter(activities)
     if len(activities) == 0 {
       output = "there have been no activities today"
       return
     } else {
-      table := &activityTable{activities, c, tableModeDay}
+      table := &activityTable{activities, c, tableModeDay, maxRunning, rates, cmd.AllUsers}
       output = table.String()
     }
 
   } else if args[0] == "week" {
     now := c.Now()
-
-    /* midnight Sunday */
-    /* NOTE: zero and negative days work just fine here */
-    lower := time.Date(now.Year(), now.Month(),
-      now.Day() - int(now.Weekday()), 0, 0, 0, 0, now.Location())
-
-    /* midnight Sunday next week */
-    upper := time.Date(now.Year(), now.Month(),
-      now.Day() + (7 - int(now.Weekday())), 0, 0, 0, 0, now.Location())
+    lower, upper := weekRange(now)
 
     var activities []*Activity
-    activities, err = db.FindActivitiesBetween(lower, upper)
+    activities, err = cmd.database(db).FindActivitiesBetween(lower, upper)
     if err != nil {
       return
     }
+    activities = cmd.filter(activities)
 
     if len(activities) == 0 {
       output = "there have been no activities this week"
@@ -269,23 +836,33 @@ func (cmd ListCommand) Run(c Clock, db Database, args ...string) (output string,
         for ; i < len(activities) && activities[i].Start.Weekday() == day; i++ {
           upper++
         }
-        table := &activityTable{activities[lower:upper], c, tableModeWeek}
+        table := &activityTable{activities[lower:upper], c, tableModeWeek, maxRunning, rates,
+          cmd.AllUsers}
         output += table.String()
 
         numDays++
       }
     }
+
+    if cmd.Budgets {
+      var warnings []string
+      warnings, err = budgetWarnings(c, db)
+      if len(warnings) > 0 {
+        output += "\n\n" + strings.Join(warnings, "\n")
+      }
+    }
   } else if args[0] == "all" {
     var activities []*Activity
-    activities, err = db.FindAllActivities()
+    activities, err = cmd.database(db).FindAllActivities()
     if err != nil {
       return
     }
+    activities = cmd.filter(activities)
 
     if len(activities) == 0 {
       output = "there aren't any activities"
     } else {
-      table := &activityTable{activities, c, tableModeAll}
+      table := &activityTable{activities, c, tableModeAll, maxRunning, rates, cmd.AllUsers}
       output = table.String()
     }
   }
@@ -308,14 +885,19 @@ type activityTable struct {
   activities []*Activity
   c Clock
   mode tableMode
+  maxRunning time.Duration
+  rates []*Rate
+  /* show who recorded each activity */
+  users bool
 }
 
 func (table *activityTable) header() (output string) {
-  switch table.mode {
-  case tableModeDay, tableModeWeek:
-    output = "| id\t| name\t| project\t| tags\t| state\t| start\t| end\t| duration\t|"
-  case tableModeAll:
-    output = "| date\t| id\t| name\t| project\t| tags\t| state\t| start\t| end\t| duration\t|"
+  output = "| id\t| name\t| project\t| tags\t| state\t| start\t| end\t| duration\t|"
+  if table.users {
+    output = "| user\t" + output
+  }
+  if table.mode == tableModeAll {
+    output = "| date\t" + output
   }
   return
 }
@@ -333,6 +915,9 @@ func (table *activityTable) formatActivity(activity *Activity) (output string) {
   output = fmt.Sprintf("| %d\t| %s\t| %s\t| %s\t| %s\t| %s\t| %s\t| %s\t|",
     activity.Id, activity.Name, activity.Project, activity.TagList(),
     activity.Status(), start, end, duration)
+  if table.users {
+    output = fmt.Sprintf("| %s\t%s", activity.User, output)
+  }
   if table.mode == tableModeAll {
     output = fmt.Sprintf("| %s\t%s", date, output)
   }
@@ -340,22 +925,43 @@ func (table *activityTable) formatActivity(activity *Activity) (output string) {
 }
 
 func (table *activityTable) String() (output string) {
+  var breaks Duration
+  var earned MoneyList
   totals := newProjectDurationList()
   output = table.header()
   for _, activity := range(table.activities) {
     output += fmt.Sprint("\n", table.formatActivity(activity))
     totals.add(activity.Project, activity.Duration(table.c))
+    breaks += activity.BreakTime(table.c)
+    if amount, ok := activityAmount(table.c, table.rates, activity); ok {
+      totals.addAmount(activity.Project, amount)
+      earned = earned.Add(amount)
+    }
   }
   if table.mode != tableModeAll {
     output += fmt.Sprint("\n", totals)
+    if breaks > 0 {
+      output += fmt.Sprint(", breaks: ", breaks)
+    }
+    if len(earned) > 0 {
+      output += fmt.Sprint(", earned: ", earned)
+    }
+  }
+  for _, activity := range(table.activities) {
+    if activity.IsOverdue(table.c, table.maxRunning) {
+      output += fmt.Sprintf("\nwarning: activity %d has been running for more than %s (see fix-running)",
+        activity.Id, Duration(table.maxRunning))
+    }
   }
   return
 }
 
 /* edit */
-type EditCommand struct{}
+type EditCommand struct {
+  Strict bool
+}
 
-func (EditCommand) Run(c Clock, db Database, args ...string) (output string, err error) {
+func (cmd EditCommand) Run(c Clock, db Database, args ...string) (output string, err error) {
   if len(args) > 1 {
     var id int64
     id, err = strconv.ParseInt(args[0], 10, 64)
@@ -370,47 +976,8 @@ func (EditCommand) Run(c Clock, db Database, args ...string) (output string, err
       return
     }
 
-    field := args[1]
-    switch(field) {
-    case "name":
-      if len(args) > 2 {
-        activity.Name = strings.Join(args[2:], " ")
-      } else {
-        err = SyntaxError("name is required")
-        return
-      }
-    case "project":
-      if len(args) > 2 {
-        activity.Project = strings.Join(args[2:], " ")
-      } else {
-        activity.Project = ""
-      }
-    case "tags":
-      activity.Tags = args[2:]
-    case "start", "end":
-      if len(args) > 2 {
-        dateString := strings.Join(args[2:], " ")
-
-        var t time.Time
-        t, err = time.ParseInLocation(DateFormat, dateString, time.Local)
-        if err != nil {
-          t, err = time.Parse(DateWithZoneFormat, dateString)
-        }
-        if err != nil {
-          err = SyntaxError("invalid date")
-          return
-        }
-        if args[1] == "start" {
-          activity.Start = t
-        } else {
-          activity.End = t
-        }
-      } else {
-        err = SyntaxError("date is required")
-        return
-      }
-    default:
-      err = SyntaxError("invalid field name")
+    err = cmd.edit(db, activity, args[1], args[2:])
+    if err != nil {
       return
     }
 
@@ -425,6 +992,45 @@ func (EditCommand) Run(c Clock, db Database, args ...string) (output string, err
   return
 }
 
+/* change one field of an activity without saving it */
+func (cmd EditCommand) edit(db Database, activity *Activity, field string, values []string) (err error) {
+  switch(field) {
+  case "name":
+    if len(values) > 0 {
+      activity.Name = strings.Join(values, " ")
+    } else {
+      err = SyntaxError("name is required")
+    }
+  case "project":
+    activity.Project = strings.Join(values, " ")
+    if cmd.Strict {
+      err = checkProject(db, activity.Project)
+    }
+  case "tags":
+    activity.Tags = values
+  case "notes":
+    activity.Notes = strings.Join(values, " ")
+  case "start", "end":
+    if len(values) > 0 {
+      var t time.Time
+      t, err = parseDate(strings.Join(values, " "))
+      if err != nil {
+        return
+      }
+      if field == "start" {
+        activity.Start = t
+      } else {
+        activity.End = t
+      }
+    } else {
+      err = SyntaxError("date is required")
+    }
+  default:
+    err = SyntaxError("invalid field name")
+  }
+  return
+}
+
 func (EditCommand) Help() string {
   return editHelp
 }
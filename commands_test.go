@@ -1,9 +1,10 @@
 package hourglass
 
 import (
+  "bytes"
+  "strings"
   "testing"
   "time"
-  "sort"
   "os"
   "os/exec"
   "syscall"
@@ -67,89 +68,6 @@ func checkStringsEqual(expected, actual string) (ok bool, diff string, err error
   return
 }
 
-/* activity sorting */
-type activitySlice []*Activity
-func (a activitySlice) Len() int {
-  return len(a)
-}
-func (a activitySlice) Less(i, j int) bool {
-  return a[i].Id < a[j].Id
-}
-func (a activitySlice) Swap(i, j int) {
-  a[i], a[j] = a[j], a[i]
-}
-
-/* fake database */
-type fakeDb struct {
-  activityMap map[int64]*Activity
-}
-func (db *fakeDb) Valid() (bool, error) {
-  return true, nil
-}
-func (db *fakeDb) Version() (int, error) {
-  return 1, nil
-}
-func (db *fakeDb) Migrate() error {
-  return nil
-}
-func (db *fakeDb) SaveActivity(a *Activity) error {
-  if db.activityMap == nil {
-    db.activityMap = make(map[int64]*Activity)
-  }
-  if a.Id == 0 {
-    a.Id = int64(len(db.activityMap)) + 1
-  }
-  db.activityMap[a.Id] = a
-
-  return nil
-}
-func (db *fakeDb) FindActivity(id int64) (*Activity, error) {
-  activity, ok := db.activityMap[id]
-  if !ok {
-    return nil, ErrNotFound
-  }
-  return activity, nil
-}
-func (db *fakeDb) FindAllActivities() ([]*Activity, error) {
-  activities := make(activitySlice, len(db.activityMap))
-  i := 0
-  for _, a := range(db.activityMap) {
-    activities[i] = a
-    i += 1
-  }
-  sort.Sort(activities)
-  return activities, nil
-}
-func (db *fakeDb) FindRunningActivities() ([]*Activity, error) {
-  var activities activitySlice
-  for _, a := range db.activityMap {
-    if a.IsRunning() {
-      activities = append(activities, a)
-    }
-  }
-  sort.Sort(activities)
-  return activities, nil
-}
-func (db *fakeDb) FindActivitiesBetween(lower time.Time, upper time.Time) ([]*Activity, error) {
-  var activities activitySlice
-  for _, a := range db.activityMap {
-    if a.Start.Equal(lower) || a.Start.After(lower) && a.Start.Before(upper) {
-      activities = append(activities, a)
-    }
-  }
-  sort.Sort(activities)
-  return activities, nil
-}
-func (db *fakeDb) DeleteActivity(id int64) (err error) {
-  _, ok := db.activityMap[id]
-  if !ok {
-    err = ErrNotFound
-  } else {
-    delete(db.activityMap, id)
-  }
-  return
-}
-
 /* fake clock */
 type fakeCmdClock struct {
   now time.Time
@@ -163,6 +81,12 @@ func (c fakeCmdClock) Local(t time.Time) time.Time {
 func (c fakeCmdClock) Since(t time.Time) time.Duration {
   return c.now.Sub(t)
 }
+/* fires right away */
+func (c fakeCmdClock) After(d time.Duration) <-chan time.Time {
+  ch := make(chan time.Time, 1)
+  ch <- c.now.Add(d)
+  return ch
+}
 
 /* time helpers */
 func when(year, month, day, hour int) time.Time {
@@ -188,7 +112,7 @@ var startTests = []struct {
 func TestStartCommand_Run(t *testing.T) {
   for i, config := range startTests {
     cmd := StartCommand{}
-    db := &fakeDb{}
+    db := &Memory{}
     c := fakeCmdClock{config.now}
 
     var args []string
@@ -227,12 +151,12 @@ func TestStartCommand_Run(t *testing.T) {
       t.Errorf("test %d: expected error, got nil", i)
     }
 
-    if len(db.activityMap) != 1 {
+    if len(db.activities) != 1 {
       t.Errorf("test %d: activity wasn't saved", i)
       continue
     }
 
-    a := db.activityMap[1]
+    a := db.activities[1]
     if a.Name != config.name {
       t.Errorf("test %d: expected '%s', got '%s'", i, config.name, a.Name)
     }
@@ -255,6 +179,101 @@ func TestStartCommand_Run(t *testing.T) {
   }
 }
 
+func TestStartCommand_Run_WithTemplate(t *testing.T) {
+  f := func (templates *Templates) {
+    templates.Save(&Template{"standup", "Daily standup", "teamx", []string{"meeting"}})
+    cmd := StartCommand{Templates: templates}
+    db := &Memory{}
+    c := fakeCmdClock{when(2013, 4, 26, 9)}
+
+    output, err := cmd.Run(c, db, "@standup")
+    if err != nil {
+      t.Error(err)
+      return
+    }
+    if output != "started activity 1" {
+      t.Errorf("unexpected output: %q", output)
+    }
+    expected := &Activity{Id: 1, Name: "Daily standup", Project: "teamx",
+      Tags: []string{"meeting"}, Start: c.now}
+    if !expected.Equal(db.activities[1]) {
+      t.Errorf("expected %v, got %v", expected, db.activities[1])
+    }
+
+    /* positional arguments override the template */
+    _, err = cmd.Run(c, db, "@standup", "teamy")
+    if err != nil {
+      t.Error(err)
+      return
+    }
+    expected = &Activity{Id: 2, Name: "Daily standup", Project: "teamy",
+      Tags: []string{"meeting"}, Start: c.now}
+    if !expected.Equal(db.activities[2]) {
+      t.Errorf("expected %v, got %v", expected, db.activities[2])
+    }
+
+    _, err = cmd.Run(c, db, "@junk")
+    if err == nil {
+      t.Error("expected error for missing template")
+    }
+  }
+  templatesTestRun(f, t)
+}
+
+func TestStartCommand_Run_WithLast(t *testing.T) {
+  cmd := StartCommand{Last: true}
+  db := &Memory{}
+  c := fakeCmdClock{when(2013, 4, 26, 12)}
+
+  _, err := cmd.Run(c, db)
+  if err == nil {
+    t.Error("expected error with no activities")
+  }
+
+  db.SaveActivity(&Activity{Name: "bar", Project: "baz", Tags: []string{"qux"},
+    Start: when(2013, 4, 26, 10), End: when(2013, 4, 26, 11)})
+  db.SaveActivity(&Activity{Name: "foo", Start: when(2013, 4, 26, 9), End: when(2013, 4, 26, 10)})
+
+  var output string
+  output, err = cmd.Run(c, db)
+  if err != nil {
+    t.Error(err)
+    return
+  }
+  if output != "started activity 3" {
+    t.Errorf("unexpected output: %q", output)
+  }
+  expected := &Activity{Id: 3, Name: "bar", Project: "baz", Tags: []string{"qux"}, Start: c.now}
+  if !expected.Equal(db.activities[3]) {
+    t.Errorf("expected %v, got %v", expected, db.activities[3])
+  }
+}
+
+func TestStartCommand_Run_WithDefaults(t *testing.T) {
+  cmd := StartCommand{Defaults: &DirConfig{Project: "teamx", Tags: []string{"backend"}}}
+  db := &Memory{}
+  c := fakeCmdClock{when(2013, 4, 26, 12)}
+
+  _, err := cmd.Run(c, db, "fix bug")
+  if err != nil {
+    t.Fatal(err)
+  }
+  expected := &Activity{Id: 1, Name: "fix bug", Project: "teamx", Tags: []string{"backend"}, Start: c.now}
+  if !expected.Equal(db.activities[1]) {
+    t.Errorf("expected %v, got %v", expected, db.activities[1])
+  }
+
+  /* anything given on the command line wins */
+  _, err = cmd.Run(c, db, "review", "teamy", "meetings")
+  if err != nil {
+    t.Fatal(err)
+  }
+  expected = &Activity{Id: 2, Name: "review", Project: "teamy", Tags: []string{"meetings"}, Start: c.now}
+  if !expected.Equal(db.activities[2]) {
+    t.Errorf("expected %v, got %v", expected, db.activities[2])
+  }
+}
+
 func TestStartCommand_Help(t *testing.T) {
   cmd := StartCommand{}
   if cmd.Help() == "" {
@@ -282,7 +301,7 @@ func TestRestartCommand_Run(t *testing.T) {
     var err error
 
     cmd := RestartCommand{}
-    db := &fakeDb{}
+    db := &Memory{}
     c := fakeCmdClock{config.now}
 
     if config.activity != nil {
@@ -291,7 +310,7 @@ func TestRestartCommand_Run(t *testing.T) {
         t.Errorf("test %d: %s", testNum, err)
         continue
       }
-      if len(db.activityMap) != 1 {
+      if len(db.activities) != 1 {
         t.Errorf("test %d: activity wasn't saved", testNum)
         continue
       }
@@ -324,12 +343,12 @@ func TestRestartCommand_Run(t *testing.T) {
       t.Errorf("test %d: expected error, got nil", testNum)
     }
 
-    if len(db.activityMap) != 2 {
+    if len(db.activities) != 2 {
       t.Errorf("test %d: activity wasn't saved", testNum)
       continue
     }
 
-    a := db.activityMap[1]
+    a := db.activities[2]
     if a.Name != config.activity.Name {
       t.Errorf("test %d: expected '%s', got '%s'", testNum, config.activity.Name, a.Name)
     }
@@ -380,7 +399,7 @@ var stopTests = []struct {
 func TestStopCommand_Run(t *testing.T) {
   for i, config := range stopTests {
     cmd := StopCommand{}
-    db := &fakeDb{}
+    db := &Memory{}
     c := fakeCmdClock{config.now}
 
     now := c.Now()
@@ -429,6 +448,421 @@ func TestStopCommand_Help(t *testing.T) {
   }
 }
 
+/* stop --at tests */
+var stopAtTests = []struct {
+  now time.Time
+  start time.Time
+  args []string
+  end time.Time
+  output string
+  err bool
+  syntaxErr bool
+}{
+  /* test 0: missing value */
+  {when(2013, 4, 27, 9), when(2013, 4, 26, 9), []string{"--at"}, time.Time{}, "", true, true},
+
+  /* test 1: unknown argument */
+  {when(2013, 4, 27, 9), when(2013, 4, 26, 9), []string{"foo"}, time.Time{}, "", true, true},
+
+  /* test 2: maximum running time */
+  {
+    when(2013, 4, 27, 9), when(2013, 4, 26, 9), []string{"--at", "max"},
+    when(2013, 4, 26, 19), "stopped activity 1 at 2013-04-26 19:00", false, false,
+  },
+
+  /* test 3: end of day */
+  {
+    when(2013, 4, 27, 9), when(2013, 4, 26, 9), []string{"--at", "eod"},
+    when(2013, 4, 26, 18), "stopped activity 1 at 2013-04-26 18:00", false, false,
+  },
+
+  /* test 4: started after the end of day */
+  {
+    when(2013, 4, 27, 9), when(2013, 4, 26, 20), []string{"--at", "eod"},
+    when(2013, 4, 27, 6), "stopped activity 1 at 2013-04-27 06:00", false, false,
+  },
+
+  /* test 5: time of day */
+  {
+    when(2013, 4, 27, 9), when(2013, 4, 26, 9), []string{"-at", "17:00"},
+    when(2013, 4, 26, 17), "stopped activity 1 at 2013-04-26 17:00", false, false,
+  },
+
+  /* test 6: full date */
+  {
+    when(2013, 4, 27, 9), when(2013, 4, 26, 9), []string{"--at", "2013-04-26", "12:00"},
+    when(2013, 4, 26, 12), "stopped activity 1 at 2013-04-26 12:00", false, false,
+  },
+
+  /* test 7: time before start */
+  {when(2013, 4, 27, 9), when(2013, 4, 26, 9), []string{"--at", "08:00"}, time.Time{}, "", true, true},
+
+  /* test 8: never stop in the future */
+  {
+    when(2013, 4, 26, 12), when(2013, 4, 26, 9), []string{"--at", "max"},
+    when(2013, 4, 26, 12), "stopped activity 1 at 2013-04-26 12:00", false, false,
+  },
+}
+
+func TestStopCommand_Run_WithAt(t *testing.T) {
+  for i, config := range stopAtTests {
+    info := &CommandInfo{Name: "stop", Command: &StopCommand{}}
+    db := &Memory{}
+    c := fakeCmdClock{config.now}
+    db.SaveActivity(&Activity{Name: "foo", Start: config.start})
+
+    output, err := info.Run(c, db, config.args...)
+
+    outputOk, diff, checkErr := checkStringsEqual(config.output, output)
+    if !outputOk {
+      if err == nil {
+        t.Errorf("test %d: bad output:\n%s", i, diff)
+      } else {
+        t.Errorf("test %d: output didn't match, but couldn't create diff: %s", i, checkErr)
+      }
+    }
+
+    activity, _ := db.FindActivity(1)
+    if err != nil {
+      if !config.err {
+        t.Errorf("test %d: %s", i, err)
+      } else if config.syntaxErr {
+        _, ok := err.(SyntaxError)
+        if !ok {
+          t.Errorf("test %d: expected error type SyntaxError, got %T", i, err)
+        }
+      }
+      if !activity.IsRunning() {
+        t.Errorf("test %d: expected activity to still be running", i)
+      }
+      continue
+    }
+    if config.err {
+      t.Errorf("test %d: expected error, got nil", i)
+    }
+
+    if !activity.End.Equal(config.end) {
+      t.Errorf("test %d: expected %v, got %v", i, config.end, activity.End)
+    }
+  }
+}
+
+func TestStopCommand_Run_AtLastSeen(t *testing.T) {
+  info := &CommandInfo{Name: "stop", Command: &StopCommand{}}
+  db := &Memory{}
+  c := fakeCmdClock{when(2013, 4, 27, 9)}
+  db.SaveActivity(&Activity{Name: "foo", Start: when(2013, 4, 26, 9)})
+  db.SaveActivity(&Activity{Name: "bar", Start: when(2013, 4, 26, 10), End: when(2013, 4, 26, 11),
+    Pauses: []Pause{Pause{when(2013, 4, 26, 10), when(2013, 4, 26, 10)}}})
+  db.SaveActivity(&Activity{Name: "baz", Start: when(2013, 4, 26, 13), End: when(2013, 4, 26, 14)})
+  /* the next morning is after the maximum running time */
+  db.SaveActivity(&Activity{Name: "qux", Start: when(2013, 4, 27, 8), End: when(2013, 4, 27, 8)})
+
+  output, err := info.Run(c, db, "--at", "last-seen")
+  if err != nil {
+    t.Fatal(err)
+  }
+  expected := "stopped activity 1 at 2013-04-26 14:00"
+  if output != expected {
+    t.Errorf("expected %q, got %q", expected, output)
+  }
+}
+
+/* status command tests */
+var statusTests = []struct {
+  activities []*Activity
+  short bool
+  output string
+  err error
+}{
+  /* test 0: nothing running */
+  {nil, false, "there aren't any running activities", ErrNothingRunning},
+
+  /* test 1: nothing running, short */
+  {
+    []*Activity{&Activity{Name: "foo", Start: when(2013, 4, 26, 8), End: when(2013, 4, 26, 9)}},
+    true, "", ErrNothingRunning,
+  },
+
+  /* test 2: running activities */
+  {
+    []*Activity{
+      &Activity{Name: "foo", Project: "bar", Start: when(2013, 4, 26, 9)},
+      &Activity{Name: "baz", Start: when(2013, 4, 26, 10), Pauses: []Pause{Pause{Start: when(2013, 4, 26, 11)}}},
+      &Activity{Name: "qux", Start: when(2013, 4, 26, 8), End: when(2013, 4, 26, 9)},
+    },
+    false,
+    "running activity 1: foo (bar), 03h00m\npaused activity 2: baz (unsorted), 01h00m",
+    nil,
+  },
+
+  /* test 3: running activities, short */
+  {
+    []*Activity{
+      &Activity{Name: "foo", Project: "bar", Start: when(2013, 4, 26, 9)},
+      &Activity{Name: "baz", Start: when(2013, 4, 26, 10), Pauses: []Pause{Pause{Start: when(2013, 4, 26, 11)}}},
+    },
+    true,
+    "foo (bar) 03h00m, baz 01h00m paused",
+    nil,
+  },
+}
+
+func TestStatusCommand_Run(t *testing.T) {
+  for i, config := range statusTests {
+    cmd := StatusCommand{Short: config.short}
+    db := &Memory{}
+    c := fakeCmdClock{when(2013, 4, 26, 12)}
+    for _, activity := range config.activities {
+      db.SaveActivity(activity)
+    }
+
+    output, err := cmd.Run(c, db)
+    if err != config.err {
+      t.Errorf("test %d: expected error %v, got %v", i, config.err, err)
+    }
+    if output != config.output {
+      t.Errorf("test %d: expected %q, got %q", i, config.output, output)
+    }
+  }
+}
+
+func TestStatusCommand_Help(t *testing.T) {
+  cmd := StatusCommand{}
+  if cmd.Help() == "" {
+    t.Error("no help available")
+  }
+}
+
+/* pause command tests */
+var pauseTests = []struct {
+  activities []*Activity
+  output string
+  paused []bool
+}{
+  /* test 0: nothing running */
+  {nil, "there aren't any running activities", nil},
+
+  /* test 1: running and already paused activities */
+  {
+    []*Activity{
+      &Activity{Name: "foo", Start: when(2013, 4, 26, 9)},
+      &Activity{Name: "bar", Start: when(2013, 4, 26, 9), Pauses: []Pause{Pause{Start: when(2013, 4, 26, 10)}}},
+      &Activity{Name: "baz", Start: when(2013, 4, 26, 8), End: when(2013, 4, 26, 9)},
+    },
+    "paused activity 1",
+    []bool{true, true, false},
+  },
+}
+
+func TestPauseCommand_Run(t *testing.T) {
+  for i, config := range pauseTests {
+    cmd := PauseCommand{}
+    db := &Memory{}
+    c := fakeCmdClock{when(2013, 4, 26, 11)}
+    for _, activity := range config.activities {
+      db.SaveActivity(activity)
+    }
+
+    output, err := cmd.Run(c, db)
+    if err != nil {
+      t.Errorf("test %d: %s", i, err)
+      continue
+    }
+    if output != config.output {
+      t.Errorf("test %d: expected %q, got %q", i, config.output, output)
+    }
+    for j, paused := range config.paused {
+      activity, _ := db.FindActivity(int64(j + 1))
+      if activity.IsPaused() != paused {
+        t.Errorf("test %d: expected %t, got %t", i, paused, activity.IsPaused())
+      }
+    }
+  }
+}
+
+func TestPauseCommand_Help(t *testing.T) {
+  cmd := PauseCommand{}
+  if cmd.Help() == "" {
+    t.Error("no help available")
+  }
+}
+
+/* resume command tests */
+func TestResumeCommand_Run(t *testing.T) {
+  cmd := ResumeCommand{}
+  db := &Memory{}
+  c := fakeCmdClock{when(2013, 4, 26, 11)}
+
+  output, err := cmd.Run(c, db)
+  if err != nil {
+    t.Error(err)
+  } else if output != "there aren't any paused activities" {
+    t.Errorf("unexpected output: %q", output)
+  }
+
+  db.SaveActivity(&Activity{Name: "foo", Start: when(2013, 4, 26, 9)})
+  db.SaveActivity(&Activity{Name: "bar", Start: when(2013, 4, 26, 9),
+    Pauses: []Pause{Pause{Start: when(2013, 4, 26, 10)}}})
+
+  output, err = cmd.Run(c, db)
+  if err != nil {
+    t.Error(err)
+    return
+  }
+  if output != "resumed activity 2" {
+    t.Errorf("unexpected output: %q", output)
+  }
+
+  activity, _ := db.FindActivity(2)
+  if activity.IsPaused() {
+    t.Error("expected activity to be resumed")
+  }
+  if !activity.Pauses[0].End.Equal(c.now) {
+    t.Errorf("expected %v, got %v", c.now, activity.Pauses[0].End)
+  }
+  if activity.Duration(c) != Duration(time.Hour) {
+    t.Errorf("expected %v, got %v", Duration(time.Hour), activity.Duration(c))
+  }
+}
+
+func TestResumeCommand_Help(t *testing.T) {
+  cmd := ResumeCommand{}
+  if cmd.Help() == "" {
+    t.Error("no help available")
+  }
+}
+
+/* fix-running command tests */
+var fixRunningTests = []struct {
+  now time.Time
+  starts []time.Time
+  args []string
+  ends []time.Time
+  output string
+  err bool
+}{
+  /* test 0: nothing to fix */
+  {
+    when(2013, 4, 26, 12), []time.Time{when(2013, 4, 26, 9)}, nil,
+    []time.Time{time.Time{}},
+    "no activities have been running longer than 10h00m", false,
+  },
+
+  /* test 1: only overdue activities are stopped */
+  {
+    when(2013, 4, 27, 9), []time.Time{when(2013, 4, 26, 9), when(2013, 4, 27, 8)}, nil,
+    []time.Time{when(2013, 4, 26, 19), time.Time{}},
+    "stopped activity 1 at 2013-04-26 19:00", false,
+  },
+
+  /* test 2: end of day */
+  {
+    when(2013, 4, 27, 9), []time.Time{when(2013, 4, 26, 9), when(2013, 4, 25, 10)}, []string{"eod"},
+    []time.Time{when(2013, 4, 26, 18), when(2013, 4, 25, 18)},
+    "stopped activity 1 at 2013-04-26 18:00\nstopped activity 2 at 2013-04-25 18:00", false,
+  },
+
+  /* test 3: bad argument */
+  {when(2013, 4, 27, 9), nil, []string{"foo"}, nil, "", true},
+
+  /* test 4: last seen, when the other activity started or nothing since */
+  {
+    when(2013, 4, 27, 9), []time.Time{when(2013, 4, 26, 9), when(2013, 4, 26, 12)}, []string{"last-seen"},
+    []time.Time{when(2013, 4, 26, 12), when(2013, 4, 26, 12)},
+    "stopped activity 1 at 2013-04-26 12:00\nstopped activity 2 at 2013-04-26 12:00", false,
+  },
+}
+
+func TestFixRunningCommand_Run(t *testing.T) {
+  for i, config := range fixRunningTests {
+    cmd := FixRunningCommand{}
+    db := &Memory{}
+    c := fakeCmdClock{config.now}
+    for _, start := range config.starts {
+      db.SaveActivity(&Activity{Name: "foo", Start: start})
+    }
+
+    output, err := cmd.Run(c, db, config.args...)
+
+    outputOk, diff, checkErr := checkStringsEqual(config.output, output)
+    if !outputOk {
+      if err == nil {
+        t.Errorf("test %d: bad output:\n%s", i, diff)
+      } else {
+        t.Errorf("test %d: output didn't match, but couldn't create diff: %s", i, checkErr)
+      }
+    }
+
+    if err != nil {
+      if !config.err {
+        t.Errorf("test %d: %s", i, err)
+      }
+      continue
+    }
+    if config.err {
+      t.Errorf("test %d: expected error, got nil", i)
+    }
+
+    for j, end := range config.ends {
+      activity, _ := db.FindActivity(int64(j + 1))
+      if !activity.End.Equal(end) {
+        t.Errorf("test %d: expected %v, got %v", i, end, activity.End)
+      }
+    }
+  }
+}
+
+func TestFixRunningCommand_Run_Ask(t *testing.T) {
+  db := &Memory{}
+  c := fakeCmdClock{when(2013, 4, 28, 9)}
+  for day := 24; day <= 27; day++ {
+    db.SaveActivity(&Activity{Name: "foo", Start: when(2013, 4, day, 9)})
+  }
+  out := new(bytes.Buffer)
+  cmd := FixRunningCommand{In: strings.NewReader("\njunk\n12:30\nskip\neod\n"), Out: out}
+
+  output, err := cmd.Run(c, db, "ask")
+  if err != nil {
+    t.Fatal(err)
+  }
+  expected := "stopped activity 1 at 2013-04-24 19:00\n" +
+    "stopped activity 2 at 2013-04-25 12:30\n" +
+    "left activity 3 running\n" +
+    "stopped activity 4 at 2013-04-27 18:00"
+  if output != expected {
+    t.Errorf("expected %q, got %q", expected, output)
+  }
+  if n := strings.Count(out.String(), "stop at"); n != 5 {
+    t.Errorf("expected 5 questions, got %d:\n%s", n, out.String())
+  }
+  if !strings.Contains(out.String(), "activity 2 (foo) has been running since 2013-04-25 09:00\n") ||
+    !strings.Contains(out.String(), "syntax error") {
+    t.Errorf("unexpected questions:\n%s", out.String())
+  }
+
+  /* running out of answers leaves the rest running */
+  db = &Memory{}
+  db.SaveActivity(&Activity{Name: "foo", Start: when(2013, 4, 26, 9)})
+  db.SaveActivity(&Activity{Name: "bar", Start: when(2013, 4, 26, 10)})
+  cmd = FixRunningCommand{In: strings.NewReader("13:00"), Out: out}
+  output, err = cmd.Run(c, db, "ask")
+  if err != nil || output != "stopped activity 1 at 2013-04-26 13:00" {
+    t.Errorf("expected only activity 1 to be stopped, got %q, %v", output, err)
+  }
+  activity, _ := db.FindActivity(2)
+  if !activity.IsRunning() {
+    t.Error("expected activity 2 to still be running")
+  }
+}
+
+func TestFixRunningCommand_Help(t *testing.T) {
+  cmd := FixRunningCommand{}
+  if cmd.Help() == "" {
+    t.Error("no help available")
+  }
+}
+
 var listTests = []struct {
   now time.Time
   activities []*Activity
@@ -526,6 +960,37 @@ var listTests = []struct {
     false,
   },
 
+  /* test 5: flagging forgotten activities */
+  {
+    when(2013, 4, 26, 22),
+    []*Activity{
+      &Activity{Name: "foo", Start: when(2013, 4, 26, 8)},
+    },
+    nil,
+    "| id\t| name\t| project\t| tags\t| state\t| start\t| end\t| duration\t|\n" +
+    "| 1\t| foo\t| \t| \t| running\t| 08:00\t| \t| 14h00m\t|\n" +
+    "unsorted: 14h00m\n" +
+    "warning: activity 1 has been running for more than 10h00m (see fix-running)",
+    false,
+  },
+
+  /* test 6: showing break time */
+  {
+    when(2013, 4, 26, 22),
+    []*Activity{
+      &Activity{Name: "foo", Start: when(2013, 4, 26, 14), End: when(2013, 4, 26, 17),
+        Pauses: []Pause{Pause{when(2013, 4, 26, 15), when(2013, 4, 26, 16)}}},
+      &Activity{Name: "bar", Start: when(2013, 4, 26, 20),
+        Pauses: []Pause{Pause{Start: when(2013, 4, 26, 21)}}},
+    },
+    nil,
+    "| id\t| name\t| project\t| tags\t| state\t| start\t| end\t| duration\t|\n" +
+    "| 1\t| foo\t| \t| \t| stopped\t| 14:00\t| 17:00\t| 02h00m\t|\n" +
+    "| 2\t| bar\t| \t| \t| paused\t| 20:00\t| \t| 01h00m\t|\n" +
+    "unsorted: 03h00m, breaks: 02h00m",
+    false,
+  },
+
   /* all argument with no activities */
   {when(2013, 4, 26, 22), nil, []string{"all"}, "there aren't any activities", false},
 }
@@ -533,7 +998,7 @@ var listTests = []struct {
 func TestListCommand_Run(t *testing.T) {
   for i, config := range listTests {
     cmd := ListCommand{}
-    db := &fakeDb{}
+    db := &Memory{}
     c := fakeCmdClock{config.now}
 
     for _, activity := range config.activities {
@@ -739,7 +1204,7 @@ func TestEditCommand_Run(t *testing.T) {
     var err error
 
     cmd := EditCommand{}
-    db := &fakeDb{}
+    db := &Memory{}
     c := fakeCmdClock{time.Now()}
 
     if config.activityBefore != nil {
@@ -814,7 +1279,7 @@ func TestDeleteCommand_Run(t *testing.T) {
     var err error
 
     cmd := DeleteCommand{}
-    db := &fakeDb{}
+    db := &Memory{}
     c := fakeCmdClock{time.Now()}
 
     if config.activity != nil {
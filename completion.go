@@ -0,0 +1,346 @@
+package hourglass
+
+import (
+  "flag"
+  "fmt"
+  "sort"
+  "strconv"
+  "strings"
+)
+
+/* help messages */
+const (
+  completionHelp = "Usage: %s completion <bash|zsh|fish>\n\nPrint a shell completion script\n\nFor example, add this to your ~/.bashrc:\n\n\teval \"$(hourglass completion bash)\""
+  completeHelp = "Usage: %s __complete [word1[, word2[, ...]]]\n\nPrint completions for the last word"
+)
+
+/* completion scripts, formatted with the program name */
+const (
+  bashCompletion = `_%[1]s() {
+  local IFS=$'\n'
+  COMPREPLY=($(%[1]s __complete "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null))
+}
+complete -o default -F _%[1]s %[1]s`
+
+  zshCompletion = `#compdef %[1]s
+_%[1]s() {
+  local -a candidates
+  candidates=("${(@f)$(%[1]s __complete "${(@)words[2,CURRENT]}" 2>/dev/null)}")
+  compadd -a candidates
+}
+compdef _%[1]s %[1]s`
+
+  fishCompletion = `function __%[1]s_complete
+  set -l words (commandline -opc) (commandline -ct)
+  %[1]s __complete $words[2..-1] 2>/dev/null
+end
+complete -c %[1]s -f -a '(__%[1]s_complete)'`
+)
+
+/* number of activity ids to suggest */
+const recentIdCount = 10
+
+var editFields = []string{"name", "project", "tags", "notes", "start", "end"}
+
+/* completion */
+type CompletionCommand struct {
+  Program string
+}
+
+func (cmd CompletionCommand) Run(c Clock, db Database, args ...string) (output string, err error) {
+  if len(args) != 1 {
+    err = SyntaxError("missing shell argument")
+    return
+  }
+
+  program := cmd.Program
+  if program == "" {
+    program = "hourglass"
+  }
+
+  switch args[0] {
+  case "bash":
+    output = fmt.Sprintf(bashCompletion, program)
+  case "zsh":
+    output = fmt.Sprintf(zshCompletion, program)
+  case "fish":
+    output = fmt.Sprintf(fishCompletion, program)
+  default:
+    err = SyntaxError("unsupported shell")
+  }
+  return
+}
+
+func (CompletionCommand) Help() string {
+  return completionHelp
+}
+
+/* __complete, called by the completion scripts */
+type CompleteCommand struct {
+  Registry *Registry
+  Templates *Templates
+  /* the global options, to know which of them take a value */
+  Globals *flag.FlagSet
+}
+
+func (cmd CompleteCommand) Run(c Clock, db Database, args ...string) (output string, err error) {
+  /* skip global options and their values */
+  for len(args) > 1 && strings.HasPrefix(args[0], "-") {
+    name := strings.TrimLeft(args[0], "-")
+    args = args[1:]
+    if cmd.Globals == nil || strings.Contains(name, "=") || !takesValue(cmd.Globals.Lookup(name)) {
+      continue
+    }
+    if len(args) == 1 {
+      /* the value itself, like a file name, is left to the shell */
+      return
+    }
+    args = args[1:]
+  }
+
+  current := ""
+  if len(args) > 0 {
+    current = args[len(args) - 1]
+    args = args[:len(args) - 1]
+  }
+
+  var candidates []string
+  if len(args) == 0 {
+    candidates = cmd.Registry.Names()
+  } else if info := cmd.Registry.Lookup(args[0]); info == nil {
+    return
+  } else if strings.HasPrefix(current, "-") {
+    info.FlagSet().VisitAll(func(f *flag.Flag) {
+      candidates = append(candidates, "--" + f.Name)
+    })
+  } else {
+    candidates = cmd.candidates(db, info.Name, args[1:])
+  }
+
+  for _, candidate := range candidates {
+    if strings.HasPrefix(candidate, current) {
+      if output != "" {
+        output += "\n"
+      }
+      output += candidate
+    }
+  }
+  return
+}
+
+func (CompleteCommand) Help() string {
+  return completeHelp
+}
+
+/* whether an option needs a value, unlike -csv */
+func takesValue(f *flag.Flag) bool {
+  if f == nil {
+    return false
+  }
+  b, ok := f.Value.(interface{ IsBoolFlag() bool })
+  return !ok || !b.IsBoolFlag()
+}
+
+/* suggestions for the word after the command name and its arguments */
+func (cmd CompleteCommand) candidates(db Database, command string, args []string) []string {
+  switch command {
+  case "help":
+    if len(args) == 0 {
+      return cmd.Registry.Names()
+    }
+  case "list":
+    if len(args) == 0 {
+      return []string{"all", "week"}
+    }
+  case "restart", "delete":
+    if len(args) == 0 {
+      return recentIds(db)
+    }
+  case "edit":
+    switch {
+    case len(args) == 0:
+      return recentIds(db)
+    case len(args) == 1:
+      return editFields
+    case args[1] == "project" && len(args) == 2:
+      projects, _ := knownNames(db)
+      return projects
+    case args[1] == "tags":
+      _, tags := knownNames(db)
+      return tags
+    }
+  case "start":
+    if len(args) > 0 && (args[0] == "--last" || args[0] == "-last") {
+      /* --last takes the place of the name */
+      args[0] = ""
+    }
+    if len(args) == 0 {
+      var candidates []string
+      for _, name := range cmd.templateNames() {
+        candidates = append(candidates, "@" + name)
+      }
+      return candidates
+    }
+    projects, tags := knownNames(db)
+    if len(args) == 1 {
+      return projects
+    }
+    return tags
+  case "stop":
+    if len(args) == 1 && (args[0] == "--at" || args[0] == "-at") {
+      return []string{"max", "eod", "last-seen"}
+    }
+  case "fix-running":
+    if len(args) == 0 {
+      return []string{"max", "eod", "last-seen", "ask"}
+    }
+  case "template":
+    if len(args) == 0 {
+      return []string{"add", "list", "delete"}
+    } else if len(args) == 1 && args[0] == "delete" {
+      return cmd.templateNames()
+    }
+  case "project":
+    if len(args) == 0 {
+      return []string{"add", "list", "rename", "archive"}
+    } else if len(args) == 1 && (args[0] == "rename" || args[0] == "archive") {
+      projects, _ := knownNames(db)
+      return projects
+    } else if len(args) == 1 && args[0] == "list" {
+      return []string{"all"}
+    }
+  case "tags":
+    if len(args) == 0 {
+      return []string{"list", "rename", "delete"}
+    } else if len(args) == 1 && (args[0] == "rename" || args[0] == "delete") {
+      _, tags := knownNames(db)
+      return tags
+    }
+  case "goal":
+    if len(args) == 0 {
+      return []string{"set", "list", "delete"}
+    } else if len(args) == 1 && (args[0] == "set" || args[0] == "delete") {
+      projects, _ := knownNames(db)
+      return projects
+    } else if len(args) == 2 && args[0] == "set" {
+      return []string{"8h/day", "40h/week", "160h/month"}
+    } else if len(args) == 3 && args[0] == "set" {
+      return []string{"cap"}
+    }
+  case "git-hook":
+    if len(args) > 0 && (args[len(args) - 1] == "--mode" || args[len(args) - 1] == "-mode") {
+      return []string{"notes", "tag"}
+    }
+    return []string{"install", "uninstall"}
+  case "rate":
+    if len(args) == 0 {
+      return []string{"set", "list", "delete"}
+    } else if len(args) == 1 && (args[0] == "set" || args[0] == "delete") {
+      projects, _ := knownNames(db)
+      return projects
+    }
+  case "timesheet":
+    if len(args) > 0 {
+      switch args[len(args) - 1] {
+      case "--mode", "-mode":
+        return []string{"up", "down", "nearest"}
+      case "--per", "-per":
+        return []string{"entry", "day"}
+      case "--format", "-format":
+        return []string{"text", "csv"}
+      case "--round", "-round":
+        return []string{"6m", "15m"}
+      }
+    }
+    return []string{"today", "week", "all"}
+  case "export":
+    if len(args) > 0 && (args[len(args) - 1] == "--format" || args[len(args) - 1] == "-format") {
+      return []string{"ics"}
+    }
+    return []string{"today", "week", "all"}
+  case "import":
+    if len(args) == 0 {
+      return []string{"ics"}
+    } else if len(args) == 2 {
+      return []string{"today", "week", "all"}
+    }
+  case "completion":
+    if len(args) == 0 {
+      return []string{"bash", "zsh", "fish"}
+    }
+  }
+  return nil
+}
+
+func (cmd CompleteCommand) templateNames() (names []string) {
+  if cmd.Templates == nil {
+    return
+  }
+  templates, err := cmd.Templates.FindAll()
+  if err != nil {
+    return
+  }
+  for _, template := range templates {
+    names = append(names, template.Name)
+  }
+  return
+}
+
+/* ids of the most recent activities, newest first */
+func recentIds(db Database) (ids []string) {
+  activities, err := db.FindAllActivities()
+  if err != nil {
+    return
+  }
+  sort.Sort(sort.Reverse(activitiesById(activities)))
+  for i := 0; i < len(activities) && i < recentIdCount; i++ {
+    ids = append(ids, strconv.FormatInt(activities[i].Id, 10))
+  }
+  return
+}
+
+type activitiesById []*Activity
+func (a activitiesById) Len() int {
+  return len(a)
+}
+func (a activitiesById) Less(i, j int) bool {
+  return a[i].Id < a[j].Id
+}
+func (a activitiesById) Swap(i, j int) {
+  a[i], a[j] = a[j], a[i]
+}
+
+/* sorted project and tag names that have been used before */
+func knownNames(db Database) (projects []string, tags []string) {
+  activities, err := db.FindAllActivities()
+  if err != nil {
+    return
+  }
+
+  /* registered projects are suggested even when unused, archived ones never are */
+  projectSet := make(map[string]bool)
+  tagSet := make(map[string]bool)
+  if registered, err := db.FindProjects(); err == nil {
+    for _, project := range registered {
+      projectSet[project.Name] = true
+      if !project.Archived {
+        projects = append(projects, project.Name)
+      }
+    }
+  }
+  for _, activity := range activities {
+    if activity.Project != "" && !projectSet[activity.Project] {
+      projectSet[activity.Project] = true
+      projects = append(projects, activity.Project)
+    }
+    for _, tag := range activity.Tags {
+      if !tagSet[tag] {
+        tagSet[tag] = true
+        tags = append(tags, tag)
+      }
+    }
+  }
+  sort.Strings(projects)
+  sort.Strings(tags)
+  return
+}
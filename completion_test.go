@@ -0,0 +1,200 @@
+package hourglass
+
+import (
+  "flag"
+  "testing"
+  "strings"
+)
+
+/* completion command tests */
+func TestCompletionCommand_Run(t *testing.T) {
+  cmd := CompletionCommand{"hg"}
+  db := &Memory{}
+  c := fakeCmdClock{when(2013, 4, 26, 9)}
+
+  for _, shell := range []string{"bash", "zsh", "fish"} {
+    output, err := cmd.Run(c, db, shell)
+    if err != nil {
+      t.Errorf("%s: %s", shell, err)
+      continue
+    }
+    if !strings.Contains(output, "hg __complete") {
+      t.Errorf("%s: script doesn't call hg __complete:\n%s", shell, output)
+    }
+  }
+
+  _, err := cmd.Run(c, db, "tcsh")
+  if _, ok := err.(SyntaxError); !ok {
+    t.Errorf("expected error type SyntaxError, got %T", err)
+  }
+  _, err = cmd.Run(c, db)
+  if _, ok := err.(SyntaxError); !ok {
+    t.Errorf("expected error type SyntaxError, got %T", err)
+  }
+}
+
+func TestCompletionCommand_Help(t *testing.T) {
+  cmd := CompletionCommand{}
+  if cmd.Help() == "" {
+    t.Error("no help available")
+  }
+}
+
+/* __complete command tests */
+var completeTests = []struct {
+  args []string
+  output string
+}{
+  /* test 0: command names */
+  {[]string{""}, "list\nstart\nstop\nedit\ndelete\ntemplate\ncompletion\nhelp"},
+
+  /* test 1: partial command name */
+  {[]string{"st"}, "start\nstop"},
+
+  /* test 2: global options are skipped */
+  {[]string{"-csv", "st"}, "start\nstop"},
+
+  /* test 3: no arguments at all */
+  {nil, "list\nstart\nstop\nedit\ndelete\ntemplate\ncompletion\nhelp"},
+
+  /* test 4: list arguments */
+  {[]string{"list", ""}, "all\nweek"},
+
+  /* test 5: ids, newest first */
+  {[]string{"edit", ""}, "3\n2\n1"},
+
+  /* test 6: edit field names */
+  {[]string{"edit", "1", "p"}, "project"},
+
+  /* test 7: edit projects */
+  {[]string{"edit", "1", "project", ""}, "bar\nbaz"},
+
+  /* test 8: edit tags */
+  {[]string{"edit", "1", "tags", "one", ""}, "one\ntwo"},
+
+  /* test 9: start projects */
+  {[]string{"start", "foo", "b"}, "bar\nbaz"},
+
+  /* test 10: start tags */
+  {[]string{"start", "foo", "bar", "t"}, "two"},
+
+  /* test 11: start options */
+  {[]string{"start", "-"}, "--from-git\n--last"},
+
+  /* test 12: projects after --last */
+  {[]string{"start", "--last", "b"}, "bar\nbaz"},
+
+  /* test 13: stop --at values */
+  {[]string{"stop", "--at", "m"}, "max"},
+
+  /* test 14: aliases */
+  {[]string{"ls", "w"}, "week"},
+
+  /* test 15: nothing to suggest */
+  {[]string{"delete", "1", ""}, ""},
+
+  /* test 16: unknown command */
+  {[]string{"blargh", ""}, ""},
+
+  /* test 17: shells */
+  {[]string{"completion", "z"}, "zsh"},
+}
+
+func completeTestRegistry() *Registry {
+  r := &Registry{}
+  r.Register(&CommandInfo{Name: "list", Aliases: []string{"ls"}, Command: &ListCommand{}})
+  r.Register(&CommandInfo{Name: "start", Command: &StartCommand{}})
+  r.Register(&CommandInfo{Name: "stop", Command: &StopCommand{}})
+  r.Register(&CommandInfo{Name: "edit", Command: &EditCommand{}})
+  r.Register(&CommandInfo{Name: "delete", Command: &DeleteCommand{}})
+  r.Register(&CommandInfo{Name: "template", Command: &TemplateCommand{}})
+  r.Register(&CommandInfo{Name: "completion", Command: &CompletionCommand{}})
+  r.Register(&CommandInfo{Name: "__complete", Hidden: true, Command: &CompleteCommand{}})
+  r.Register(&CommandInfo{Name: "help", Command: &HelpCommand{}})
+  return r
+}
+
+func TestCompleteCommand_Run(t *testing.T) {
+  db := &Memory{}
+  db.SaveActivity(&Activity{Name: "foo", Project: "baz", Tags: []string{"one", "two"}})
+  db.SaveActivity(&Activity{Name: "foo", Project: "bar", Tags: []string{"one"}})
+  db.SaveActivity(&Activity{Name: "foo"})
+  c := fakeCmdClock{when(2013, 4, 26, 9)}
+  cmd := CompleteCommand{Registry: completeTestRegistry()}
+
+  for i, config := range completeTests {
+    output, err := cmd.Run(c, db, config.args...)
+    if err != nil {
+      t.Errorf("test %d: %s", i, err)
+      continue
+    }
+    if output != config.output {
+      t.Errorf("test %d: expected %q, got %q", i, config.output, output)
+    }
+  }
+}
+
+var completeGlobalsTests = []struct {
+  args []string
+  output string
+}{
+  /* test 0: values of global options are skipped */
+  {[]string{"-csv", "-db", "times.csv", "st"}, "start\nstop"},
+
+  /* test 1: values given with = */
+  {[]string{"--db=times.csv", "-max-running", "8h", "st"}, "start\nstop"},
+
+  /* test 2: command arguments */
+  {[]string{"-db", "times.csv", "list", "w"}, "week"},
+
+  /* test 3: file names are left to the shell */
+  {[]string{"-csv", "-db", "ti"}, ""},
+}
+
+func TestCompleteCommand_Run_WithGlobals(t *testing.T) {
+  globals := flag.NewFlagSet("hourglass", flag.ContinueOnError)
+  globals.Bool("csv", false, "")
+  globals.String("db", "", "")
+  globals.Duration("max-running", 0, "")
+  cmd := CompleteCommand{Registry: completeTestRegistry(), Globals: globals}
+  c := fakeCmdClock{when(2013, 4, 26, 9)}
+
+  for i, config := range completeGlobalsTests {
+    output, err := cmd.Run(c, &Memory{}, config.args...)
+    if err != nil {
+      t.Errorf("test %d: %s", i, err)
+    } else if output != config.output {
+      t.Errorf("test %d: expected %q, got %q", i, config.output, output)
+    }
+  }
+}
+
+func TestCompleteCommand_Run_WithTemplates(t *testing.T) {
+  f := func (templates *Templates) {
+    templates.Save(&Template{Name: "standup", ActivityName: "Daily standup"})
+    cmd := CompleteCommand{Registry: completeTestRegistry(), Templates: templates}
+    c := fakeCmdClock{when(2013, 4, 26, 9)}
+
+    output, err := cmd.Run(c, &Memory{}, "start", "@")
+    if err != nil {
+      t.Error(err)
+    } else if output != "@standup" {
+      t.Errorf("expected %q, got %q", "@standup", output)
+    }
+
+    output, err = cmd.Run(c, &Memory{}, "template", "delete", "")
+    if err != nil {
+      t.Error(err)
+    } else if output != "standup" {
+      t.Errorf("expected %q, got %q", "standup", output)
+    }
+  }
+  templatesTestRun(f, t)
+}
+
+func TestCompleteCommand_Help(t *testing.T) {
+  cmd := CompleteCommand{}
+  if cmd.Help() == "" {
+    t.Error("no help available")
+  }
+}
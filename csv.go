@@ -6,16 +6,29 @@ import (
   "io"
   "io/ioutil"
   "bufio"
+  "path/filepath"
   "regexp"
+  "sort"
   "strconv"
   "sync"
   "errors"
   "fmt"
   "time"
   "bytes"
+  "strings"
 )
 
-const CsvVersion = 1
+const CsvVersion = 5
+
+/* header row for each version */
+var csvHeaders = [][]string{
+  nil,
+  []string{"id", "name", "project", "tags", "start", "end"},
+  []string{"id", "name", "project", "tags", "start", "end", "pauses"},
+  []string{"id", "name", "project", "tags", "start", "end", "pauses"},
+  []string{"id", "name", "project", "tags", "start", "end", "pauses", "notes"},
+  []string{"id", "name", "project", "tags", "start", "end", "pauses", "notes", "user"},
+}
 
 var ErrBadFrontMatter = errors.New("invalid front matter")
 
@@ -48,9 +61,29 @@ func (db *Csv) Migrate() (err error) {
     case 0:
       err = db.writeFrontMatter(1, 0)
       if err == nil {
-        err = db.appendRecord([]string{"id", "name", "project", "tags",
-          "start", "end"})
+        err = db.appendRecord(csvHeaders[1])
       }
+    case 1:
+      /* add pauses column */
+      err = db.migrateRecords(2, func(record []string) []string {
+        return append(record, "")
+      })
+    case 2:
+      /* tags were joined with commas, which tags could contain */
+      err = db.migrateRecords(3, func(record []string) []string {
+        record[3] = encodeTags(splitTagList(record[3]))
+        return record
+      })
+    case 3:
+      /* add notes column */
+      err = db.migrateRecords(4, func(record []string) []string {
+        return append(record, "")
+      })
+    case 4:
+      /* add user column */
+      err = db.migrateRecords(5, func(record []string) []string {
+        return append(record, "")
+      })
     }
     if err != nil {
       return
@@ -145,13 +178,14 @@ func (db *Csv) seekToHeader(f *os.File) (pos int64, err error) {
 }
 
 func (db *Csv) seekToData(f *os.File) (pos int64, err error) {
-  /* Header is: id,name,project,tags,start,end */
   pos, err = db.seekToHeader(f)
   if err != nil {
     return
   }
 
-  pos, err = f.Seek(31, 1)
+  /* Header is the column names joined by commas */
+  header := strings.Join(csvHeaders[db.version], ",") + "\n"
+  pos, err = f.Seek(int64(len(header)), 1)
   return
 }
 
@@ -236,6 +270,70 @@ func (db *Csv) appendRecord(record []string) (err error) {
   return
 }
 
+/* rewrite every record in the file for a new version */
+func (db *Csv) migrateRecords(version int, f func([]string) []string) (err error) {
+  var records [][]string
+  records, err = db.readRecords()
+  if err != nil {
+    return
+  }
+
+  var data []byte
+  data, err = db.formatRecords(version, records, f)
+  if err != nil {
+    return
+  }
+
+  /* write a new file and move it into place, so it's all or nothing */
+  tempName := db.Filename + ".tmp"
+  err = ioutil.WriteFile(tempName, data, 0644)
+  if err != nil {
+    return
+  }
+  db.Mutex.Lock()
+  defer db.Mutex.Unlock()
+  err = os.Rename(tempName, db.Filename)
+  return
+}
+
+/* the whole file with the given records, changed by f */
+func (db *Csv) formatRecords(version int, records [][]string, f func([]string) []string) (data []byte, err error) {
+  buf := new(bytes.Buffer)
+  fmt.Fprintf(buf, "# version: %03d, last-id: %019d\n", version, db.lastId)
+  w := csv.NewWriter(buf)
+  w.Write(csvHeaders[version])
+  for _, record := range records {
+    w.Write(f(record))
+  }
+  w.Flush()
+  err = w.Error()
+  if err == nil {
+    data = buf.Bytes()
+  }
+  return
+}
+
+func (db *Csv) readRecords() (records [][]string, err error) {
+  db.Mutex.RLock()
+  defer db.Mutex.RUnlock()
+
+  var f *os.File
+  f, err = os.Open(db.Filename)
+  if err != nil {
+    return
+  }
+  defer f.Close()
+
+  _, err = db.seekToData(f)
+  if err != nil {
+    return
+  }
+
+  r := csv.NewReader(f)
+  records, err = r.ReadAll()
+  return
+}
+
 func (db *Csv) writeBytes(pos int64, data []byte) (err error) {
   db.Mutex.Lock()
   defer db.Mutex.Unlock()
@@ -277,13 +375,26 @@ func (db *Csv) readAll(pos int64) (data []byte, err error) {
 }
 
 func (db *Csv) activityToRecord(activity *Activity) (record []string) {
-  record = make([]string, 6)
+  record = make([]string, 9)
   record[0] = strconv.FormatInt(activity.Id, 10)
   record[1] = activity.Name
   record[2] = activity.Project
-  record[3] = activity.TagList()
+  record[3] = encodeTags(activity.Tags)
   record[4] = activity.Start.Format(time.RFC3339Nano)
   record[5] = activity.End.Format(time.RFC3339Nano)
+
+  /* pauses are formatted like start/end;start/end */
+  pauses := make([]string, len(activity.Pauses))
+  for i, pause := range activity.Pauses {
+    pauses[i] = pause.Start.Format(time.RFC3339Nano) + "/" +
+      pause.End.Format(time.RFC3339Nano)
+  }
+  record[6] = strings.Join(pauses, ";")
+  /* records are one line each, so notes are quoted */
+  if activity.Notes != "" {
+    record[7] = strconv.Quote(activity.Notes)
+  }
+  record[8] = activity.User
   return
 }
 
@@ -296,13 +407,50 @@ func (db *Csv) recordToActivity(record []string) (activity *Activity, err error)
 
   activity.Name = record[1]
   activity.Project = record[2]
-  activity.SetTagList(record[3])
+  if len(record) > 8 {
+    activity.User = record[8]
+  }
+  activity.Tags, err = decodeTags(record[3])
+  if err != nil {
+    return
+  }
 
-  activity.Start, err = time.Parse(time.RFC3339Nano, record[4])
+  activity.Start, err = parseCsvTime(record[4])
   if err != nil {
     return
   }
-  activity.End, err = time.Parse(time.RFC3339Nano, record[5])
+  activity.End, err = parseCsvTime(record[5])
+  if err == nil && len(record) > 7 && record[7] != "" {
+    activity.Notes, err = strconv.Unquote(record[7])
+  }
+  if err != nil || len(record) < 7 || record[6] == "" {
+    return
+  }
+
+  for _, pauseString := range strings.Split(record[6], ";") {
+    times := strings.SplitN(pauseString, "/", 2)
+    if len(times) != 2 {
+      err = fmt.Errorf("invalid pause for activity %d: %s", activity.Id, pauseString)
+      return
+    }
+    var pause Pause
+    pause.Start, err = parseCsvTime(times[0])
+    if err != nil {
+      return
+    }
+    pause.End, err = parseCsvTime(times[1])
+    if err != nil {
+      return
+    }
+    activity.Pauses = append(activity.Pauses, pause)
+  }
+  return
+}
+
+/* times are read back in the local zone, whatever zone they were saved in */
+func parseCsvTime(value string) (t time.Time, err error) {
+  t, err = time.Parse(time.RFC3339Nano, value)
+  t = localTime(t)
   return
 }
 
@@ -326,7 +474,10 @@ func (db *Csv) updateActivity(activity *Activity) (err error) {
   var pos int64
   var line []byte
   pos, line, err = db.findActivityLine(activity.Id)
-  if err != nil {
+  if err == io.EOF {
+    err = ErrNotFound
+    return
+  } else if err != nil {
     return
   }
 
@@ -415,39 +566,365 @@ func (db *Csv) findActivityLine(id int64) (pos int64, line []byte, err error) {
 }
 
 func (db *Csv) findActivities(filter func(*Activity) bool) (activities []*Activity, err error) {
-  db.Mutex.RLock()
-  defer db.Mutex.RUnlock()
+  var records [][]string
+  records, err = db.readRecords()
+  if err != nil {
+    return
+  }
 
-  var f *os.File
-  f, err = os.Open(db.Filename)
+  activities = make([]*Activity, 0, len(records))
+  for _, record := range records {
+    var activity *Activity
+    activity, err = db.recordToActivity(record)
+    if err != nil {
+      return
+    }
+    if filter == nil || filter(activity) {
+      activities = append(activities, activity)
+    }
+  }
+  return
+}
+
+/* sidecar file name, like .hourglass-rates.csv for .hourglass.csv */
+func (db *Csv) sidecar(name string) csvTable {
+  ext := filepath.Ext(db.Filename)
+  filename := strings.TrimSuffix(db.Filename, ext) + "-" + name + ext
+  return csvTable{Filename: filename, Mutex: &db.Mutex}
+}
+
+/* rates are kept in a sidecar file, sorted by project and tag */
+func (db *Csv) SaveRate(r *Rate) (err error) {
+  table := db.sidecar("rates")
+  var records [][]string
+  records, err = table.readAll()
   if err != nil {
     return
   }
-  defer f.Close()
 
-  _, err = db.seekToData(f)
+  record := []string{r.Project, r.Tag, strconv.FormatInt(r.Hourly.Amount, 10),
+    r.Hourly.Currency, strconv.FormatBool(r.Billable)}
+  replaced := false
+  for i, existing := range records {
+    if existing[0] == r.Project && existing[1] == r.Tag {
+      records[i] = record
+      replaced = true
+    }
+  }
+  if !replaced {
+    records = append(records, record)
+  }
+  sort.Slice(records, func(i, j int) bool {
+    if records[i][0] != records[j][0] {
+      return records[i][0] < records[j][0]
+    }
+    return records[i][1] < records[j][1]
+  })
+  err = table.writeAll(records)
+  return
+}
+
+func (db *Csv) FindRates() (rates []*Rate, err error) {
+  var records [][]string
+  records, err = db.sidecar("rates").readAll()
   if err != nil {
     return
   }
 
-  r := csv.NewReader(f)
+  for _, record := range records {
+    if len(record) != 5 {
+      err = fmt.Errorf("invalid rate record: %v", record)
+      return
+    }
+    rate := &Rate{Project: record[0], Tag: record[1]}
+    rate.Hourly.Currency = record[3]
+    rate.Hourly.Amount, err = strconv.ParseInt(record[2], 10, 64)
+    if err == nil {
+      rate.Billable, err = strconv.ParseBool(record[4])
+    }
+    if err != nil {
+      return
+    }
+    rates = append(rates, rate)
+  }
+  return
+}
 
+func (db *Csv) DeleteRate(project, tag string) (err error) {
+  table := db.sidecar("rates")
   var records [][]string
-  records, err = r.ReadAll()
+  records, err = table.readAll()
+  if err != nil {
+    return
+  }
+
+  for i, record := range records {
+    if record[0] == project && record[1] == tag {
+      err = table.writeAll(append(records[:i], records[i + 1:]...))
+      return
+    }
+  }
+  return ErrNotFound
+}
+
+/* projects are kept in a sidecar file, sorted by name */
+func (db *Csv) SaveProject(p *Project) (err error) {
+  table := db.sidecar("projects")
+  var records [][]string
+  records, err = table.readAll()
+  if err != nil {
+    return
+  }
+
+  record := []string{p.Name, p.Description, strconv.FormatBool(p.Archived)}
+  replaced := false
+  for i, existing := range records {
+    if existing[0] == p.Name {
+      records[i] = record
+      replaced = true
+    }
+  }
+  if !replaced {
+    records = append(records, record)
+    sort.Slice(records, func(i, j int) bool { return records[i][0] < records[j][0] })
+  }
+  err = table.writeAll(records)
+  return
+}
+
+func (db *Csv) FindProjects() (projects []*Project, err error) {
+  var records [][]string
+  records, err = db.sidecar("projects").readAll()
   if err != nil {
     return
   }
 
-  activities = make([]*Activity, 0, len(records))
   for _, record := range records {
-    var activity *Activity
-    activity, err = db.recordToActivity(record)
+    if len(record) != 3 {
+      err = fmt.Errorf("invalid project record: %v", record)
+      return
+    }
+    project := &Project{Name: record[0], Description: record[1]}
+    project.Archived, err = strconv.ParseBool(record[2])
     if err != nil {
       return
     }
-    if filter == nil || filter(activity) {
-      activities = append(activities, activity)
+    projects = append(projects, project)
+  }
+  return
+}
+
+/*
+ * The activities and the project, rate and goal sidecars are each written to
+ * a temporary file before any of them are moved into place, so a failure
+ * leaves everything as it was.
+ */
+func (db *Csv) RenameProject(old, new string) (err error) {
+  found := false
+  rename := func(record []string, i int) []string {
+    if record[i] == old {
+      record[i] = new
+      found = true
     }
+    return record
+  }
+
+  var records [][]string
+  records, err = db.readRecords()
+  if err != nil {
+    return
+  }
+  var data []byte
+  data, err = db.formatRecords(db.version, records, func(record []string) []string {
+    return rename(record, 2)
+  })
+  if err != nil {
+    return
+  }
+  files := make(map[string][]byte)
+  if found {
+    files[db.Filename] = data
+  }
+
+  for _, name := range []string{"projects", "rates", "goals"} {
+    table := db.sidecar(name)
+    records, err = table.readAll()
+    if err != nil {
+      return
+    }
+    found = false
+    for _, record := range records {
+      if name == "projects" && record[0] == new {
+        err = ErrProjectExists
+        return
+      }
+      rename(record, 0)
+    }
+    if found {
+      sort.SliceStable(records, func(i, j int) bool { return records[i][0] < records[j][0] })
+      files[table.Filename], err = formatTable(records)
+      if err != nil {
+        return
+      }
+    }
+  }
+  if len(files) == 0 {
+    err = ErrNotFound
+    return
+  }
+
+  var written []string
+  for filename, data := range files {
+    err = ioutil.WriteFile(filename + ".tmp", data, 0644)
+    if err != nil {
+      break
+    }
+    written = append(written, filename)
+  }
+  if err != nil {
+    for _, filename := range written {
+      os.Remove(filename + ".tmp")
+    }
+    return
+  }
+
+  db.Mutex.Lock()
+  defer db.Mutex.Unlock()
+  for _, filename := range written {
+    err = os.Rename(filename + ".tmp", filename)
+    if err != nil {
+      return
+    }
+  }
+  return
+}
+
+/* goals are kept in a sidecar file like rates */
+func (db *Csv) SaveGoal(g *Goal) (err error) {
+  table := db.sidecar("goals")
+  var records [][]string
+  records, err = table.readAll()
+  if err != nil {
+    return
+  }
+
+  record := []string{g.Project, g.Tag, strconv.FormatInt(int64(g.Target), 10), g.Period,
+    strconv.FormatBool(g.Cap)}
+  replaced := false
+  for i, existing := range records {
+    if existing[0] == g.Project && existing[1] == g.Tag {
+      records[i] = record
+      replaced = true
+    }
+  }
+  if !replaced {
+    records = append(records, record)
+  }
+  sort.Slice(records, func(i, j int) bool {
+    if records[i][0] != records[j][0] {
+      return records[i][0] < records[j][0]
+    }
+    return records[i][1] < records[j][1]
+  })
+  err = table.writeAll(records)
+  return
+}
+
+func (db *Csv) FindGoals() (goals []*Goal, err error) {
+  var records [][]string
+  records, err = db.sidecar("goals").readAll()
+  if err != nil {
+    return
+  }
+
+  for _, record := range records {
+    if len(record) != 5 {
+      err = fmt.Errorf("invalid goal record: %v", record)
+      return
+    }
+    goal := &Goal{Project: record[0], Tag: record[1], Period: record[3]}
+    var target int64
+    target, err = strconv.ParseInt(record[2], 10, 64)
+    if err == nil {
+      goal.Cap, err = strconv.ParseBool(record[4])
+    }
+    if err != nil {
+      return
+    }
+    goal.Target = Duration(target)
+    goals = append(goals, goal)
+  }
+  return
+}
+
+func (db *Csv) DeleteGoal(project, tag string) (err error) {
+  table := db.sidecar("goals")
+  var records [][]string
+  records, err = table.readAll()
+  if err != nil {
+    return
+  }
+
+  for i, record := range records {
+    if record[0] == project && record[1] == tag {
+      err = table.writeAll(append(records[:i], records[i + 1:]...))
+      return
+    }
+  }
+  return ErrNotFound
+}
+
+/* small csv files stored next to the database */
+type csvTable struct {
+  Filename string
+  Mutex *sync.RWMutex
+}
+
+func (t csvTable) readAll() (records [][]string, err error) {
+  if t.Mutex != nil {
+    t.Mutex.RLock()
+    defer t.Mutex.RUnlock()
+  }
+
+  var f *os.File
+  f, err = os.Open(t.Filename)
+  if err != nil {
+    if os.IsNotExist(err) {
+      /* nothing saved yet */
+      err = nil
+    }
+    return
+  }
+  defer f.Close()
+
+  r := csv.NewReader(f)
+  r.FieldsPerRecord = -1
+  records, err = r.ReadAll()
+  return
+}
+
+func formatTable(records [][]string) (data []byte, err error) {
+  buf := new(bytes.Buffer)
+  w := csv.NewWriter(buf)
+  w.WriteAll(records)
+  err = w.Error()
+  if err == nil {
+    data = buf.Bytes()
+  }
+  return
+}
+
+func (t csvTable) writeAll(records [][]string) (err error) {
+  var data []byte
+  data, err = formatTable(records)
+  if err != nil {
+    return
+  }
+
+  if t.Mutex != nil {
+    t.Mutex.Lock()
+    defer t.Mutex.Unlock()
   }
+  err = ioutil.WriteFile(t.Filename, data, 0644)
   return
 }
@@ -86,6 +86,86 @@ func TestCsv_SaveActivity(t *testing.T) {
   csvTestRun(f, t)
 }
 
+func TestCsv_SaveActivity_WithPauses(t *testing.T) {
+  f := func (db *Csv) {
+    activity := &Activity{Name: "foo", Project: "bar"}
+    activity.Start = time.Now().Add(-time.Hour)
+    activity.Pauses = []Pause{
+      Pause{activity.Start.Add(10 * time.Minute), activity.Start.Add(20 * time.Minute)},
+      Pause{Start: activity.Start.Add(30 * time.Minute)},
+    }
+
+    err := db.SaveActivity(activity)
+    if err != nil {
+      t.Error(err)
+      return
+    }
+
+    var foundActivity *Activity
+    foundActivity, err = db.FindActivity(activity.Id)
+    if err != nil {
+      t.Error(err)
+      return
+    }
+    if !activity.Equal(foundActivity) {
+      t.Error("expected:\n", activity, "\ngot:\n", foundActivity)
+    }
+  }
+  csvTestRun(f, t)
+}
+
+func TestCsv_Migrate_FromVersion1(t *testing.T) {
+  csvFile, err := ioutil.TempFile("", "hourglass")
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer os.Remove(csvFile.Name())
+
+  data := "# version: 001, last-id: 0000000000000000001\n" +
+    "id,name,project,tags,start,end\n" +
+    "1,foo,bar,\"baz, qux\",2013-04-26T14:00:00Z,2013-04-26T15:00:00Z\n"
+  _, err = csvFile.Write([]byte(data))
+  csvFile.Close()
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  var db *Csv
+  db, err = NewCsv(csvFile.Name())
+  if err != nil {
+    t.Fatal(err)
+  }
+  err = db.Migrate()
+  if err != nil {
+    t.Fatal(err)
+  }
+  if db.version != CsvVersion {
+    t.Errorf("expected version to be %d, but was %d", CsvVersion, db.version)
+  }
+
+  var activity *Activity
+  activity, err = db.FindActivity(1)
+  if err != nil {
+    t.Fatal(err)
+  }
+  expected := &Activity{Id: 1, Name: "foo", Project: "bar", Tags: []string{"baz", "qux"},
+    Start: time.Date(2013, 4, 26, 14, 0, 0, 0, time.UTC),
+    End: time.Date(2013, 4, 26, 15, 0, 0, 0, time.UTC)}
+  if !expected.Equal(activity) {
+    t.Error("expected:\n", expected, "\ngot:\n", activity)
+  }
+
+  /* new records go after the migrated ones */
+  activity = &Activity{Name: "new"}
+  err = db.SaveActivity(activity)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if activity.Id != 2 {
+    t.Errorf("expected id 2, got %d", activity.Id)
+  }
+}
+
 var updateTests = []struct {
   numAfter int
   original *Activity
@@ -144,147 +224,6 @@ func TestCsv_SaveActivity_WithExistingActivity(t *testing.T) {
   }
 }
 
-func TestCsv_FindAllActivities(t *testing.T) {
-  f := func (db *Csv) {
-    activity_1 := &Activity{Name: "foo", Project: "bar"}
-    activity_1.End = time.Now()
-    activity_1.Start = activity_1.End.Add(-time.Hour)
-    err := db.SaveActivity(activity_1)
-    if err != nil {
-      t.Error(err)
-      return
-    }
-
-    activity_2 := &Activity{Name: "baz", Project: "blargh"}
-    activity_2.End = time.Now()
-    activity_2.Start = activity_2.End.Add(-time.Hour)
-    err = db.SaveActivity(activity_2)
-    if err != nil {
-      t.Error(err)
-      return
-    }
-
-    var activities []*Activity
-    activities, err = db.FindAllActivities()
-    if err != nil {
-      t.Error(err)
-      return
-    }
-
-    if len(activities) != 2 {
-      t.Error("expected to find 2 activity, but found", len(activities))
-      return
-    }
-
-    if !activity_1.Equal(activities[0]) {
-      t.Error("expected:\n", activity_1, "\ngot:\n", activities[0])
-    }
-
-    if !activity_2.Equal(activities[1]) {
-      t.Error("expected:\n", activity_2, "\ngot:\n", activities[1])
-    }
-  }
-  csvTestRun(f, t)
-}
-
-func TestCsv_FindRunningActivities(t *testing.T) {
-  f := func (db *Csv) {
-    activity_1 := &Activity{Name: "foo", Project: "bar"}
-    activity_1.End = time.Now()
-    activity_1.Start = activity_1.End.Add(-time.Hour)
-
-    activity_2 := &Activity{Name: "baz", Start: time.Now()}
-
-    err := db.SaveActivity(activity_1)
-    if err != nil {
-      t.Error(err)
-    }
-    err = db.SaveActivity(activity_2)
-    if err != nil {
-      t.Error(err)
-    }
-
-    var activities []*Activity
-    activities, err = db.FindRunningActivities()
-    if err != nil {
-      t.Error(err)
-      return
-    }
-
-    if len(activities) != 1 {
-      t.Error("expected to find 1 activity, but found", len(activities))
-      return
-    }
-
-    if !activity_2.Equal(activities[0]) {
-      t.Error("expected:\n", activity_2, "\ngot:\n", activities[0])
-    }
-  }
-  csvTestRun(f, t)
-}
-
-func TestCsv_FindActivitiesBetween(t *testing.T) {
-  f := func (db *Csv) {
-    now := time.Now()
-
-    activity_1 := &Activity{Name: "foo", Project: "bar"}
-    activity_1.End = now.Add(-(time.Hour * 24))
-    activity_1.Start = activity_1.End.Add(-time.Hour)
-
-    activity_2 := &Activity{Name: "baz", Start: now}
-
-    err := db.SaveActivity(activity_1)
-    if err != nil {
-      t.Error(err)
-    }
-    err = db.SaveActivity(activity_2)
-    if err != nil {
-      t.Error(err)
-    }
-
-    var activities []*Activity
-    activities, err = db.FindActivitiesBetween(activity_1.Start,
-      activity_1.Start.Add(time.Hour))
-    if err != nil {
-      t.Error(err)
-      return
-    }
-
-    if len(activities) != 1 {
-      t.Error("expected to find 1 activity, but found", len(activities))
-      return
-    }
-
-    if !activity_1.Equal(activities[0]) {
-      t.Error("expected:\n", activity_1, "\ngot:\n", activities[0])
-    }
-  }
-  csvTestRun(f, t)
-}
-
-func TestCsv_DeleteActivity(t *testing.T) {
-  f := func(db *Csv) {
-    var err error
-    activity := &Activity{Name: "foo"}
-    err = db.SaveActivity(activity)
-    if err != nil {
-      t.Error(err)
-      return
-    }
-
-    err = db.DeleteActivity(activity.Id)
-    if err != nil {
-      t.Error(err)
-    }
-
-    _, err = db.FindActivity(activity.Id)
-    if err != ErrNotFound {
-      t.Errorf("expected ErrNotFound, got %v", err)
-    }
-  }
-  csvTestRun(f, t)
-}
-
 func TestCsv_DeleteActivity_FromMiddle(t *testing.T) {
   f := func(db *Csv) {
     var err error
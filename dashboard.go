@@ -0,0 +1,211 @@
+package hourglass
+
+import (
+  "html/template"
+  "net/http"
+  "strconv"
+  "strings"
+  "time"
+)
+
+/* everything is embedded so the binary can serve the page on its own */
+const dashboardHTML = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>hourglass</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+h2 { border-bottom: 1px solid #ccc; }
+table { border-collapse: collapse; }
+td, th { padding: 0.2em 0.8em; text-align: left; }
+form { display: inline; }
+.error { color: #b00; }
+.paused { color: #888; }
+.day { margin: 0.3em 0; }
+.label { display: inline-block; width: 8em; }
+.track { display: inline-block; position: relative; width: 40em; height: 1.2em; background: #eee; vertical-align: middle; }
+.bar { position: absolute; top: 0; height: 100%; background: #4a90d9; }
+.bar.running { background: #5cb85c; }
+</style>
+</head>
+<body>
+<h1>hourglass</h1>
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+
+<h2>Running</h2>
+{{if .Running}}
+<table>
+{{range .Running}}<tr{{if eq .Status "paused"}} class="paused"{{end}}>
+<td>{{.Id}}</td><td>{{.Name}}</td><td>{{if .Project}}{{.Project}}{{else}}unsorted{{end}}</td>
+<td>{{.Duration}}</td><td>{{.Status}}</td>
+</tr>
+{{end}}</table>
+<form method="post" action="/ui/stop"><button type="submit">Stop</button></form>
+{{else}}
+<p>Nothing is running.</p>
+{{end}}
+<form method="post" action="/ui/start">
+<input name="name" placeholder="name">
+<input name="project" placeholder="project">
+<input name="tags" placeholder="tags">
+<button type="submit">Start</button>
+</form>
+
+<h2>Timeline</h2>
+<p>
+{{if eq .Range "week"}}<a href="/">today</a> | week{{else}}today | <a href="/?range=week">week</a>{{end}}
+</p>
+{{range .Days}}<div class="day">
+<span class="label">{{.Label}}</span><span class="track">{{range .Bars}}<span class="bar{{if .Running}} running{{end}}" style="left: {{.Left}}%; width: {{.Width}}%" title="{{.Title}}"></span>{{end}}</span>
+</div>
+{{end}}
+
+<h2>Projects</h2>
+<table>
+{{range .Projects}}<tr><td>{{if .Project}}{{.Project}}{{else}}unsorted{{end}}</td><td>{{.Duration}}</td><td>{{.Earned}}</td></tr>
+{{end}}<tr><th>total</th><th>{{.Total}}</th><th>{{.Earned}}</th></tr>
+</table>
+</body>
+</html>
+`
+
+var dashboardTemplate = template.Must(template.New("dashboard").Parse(dashboardHTML))
+
+/* template data */
+type dashboardData struct {
+  Error string
+  Running []*activityJSON
+  Range string
+  Days []*dashboardDay
+  Projects []projectJSON
+  Total string
+  Earned string
+}
+
+type dashboardDay struct {
+  Label string
+  Bars []dashboardBar
+}
+
+/* position and width are percentages of the day */
+type dashboardBar struct {
+  Title string
+  Left string
+  Width string
+  Running bool
+}
+
+func newDashboardDay(c Clock, lower, upper time.Time, activities []*Activity) *dashboardDay {
+  day := &dashboardDay{Label: lower.Format("Mon Jan 02")}
+  length := upper.Sub(lower)
+  for _, activity := range activities {
+    start := activity.Start
+    end := activity.End
+    if end.IsZero() {
+      end = c.Now()
+    }
+    if !start.Before(upper) || !end.After(lower) {
+      continue
+    }
+    if start.Before(lower) {
+      start = lower
+    }
+    if end.After(upper) {
+      end = upper
+    }
+
+    title := activity.Name
+    if activity.Project != "" {
+      title += " (" + activity.Project + ")"
+    }
+    title += " " + activity.Duration(c).String()
+    day.Bars = append(day.Bars, dashboardBar{
+      Title: title,
+      Left: percent(start.Sub(lower), length),
+      Width: percent(end.Sub(start), length),
+      Running: activity.IsRunning(),
+    })
+  }
+  return day
+}
+
+func percent(d, total time.Duration) string {
+  return strconv.FormatFloat(100 * float64(d) / float64(total), 'f', 2, 64)
+}
+
+func (s *Server) dashboard(w http.ResponseWriter, r *http.Request, status int, message string) {
+  data := &dashboardData{Error: message, Range: r.URL.Query().Get("range")}
+  if data.Range != "week" {
+    data.Range = "today"
+  }
+
+  running, err := s.Database.FindRunningActivities()
+  if err != nil {
+    http.Error(w, err.Error(), http.StatusInternalServerError)
+    return
+  }
+  for _, activity := range running {
+    data.Running = append(data.Running, newActivityJSON(s.Clock, activity))
+  }
+
+  var lower, upper time.Time
+  if data.Range == "week" {
+    lower, upper = weekRange(s.Clock.Now())
+  } else {
+    lower, upper = dayRange(s.Clock.Now())
+  }
+  activities, err := s.Database.FindActivitiesBetween(lower, upper)
+  if err != nil {
+    http.Error(w, err.Error(), http.StatusInternalServerError)
+    return
+  }
+  for day := lower; day.Before(upper); day = day.AddDate(0, 0, 1) {
+    data.Days = append(data.Days, newDashboardDay(s.Clock, day, day.AddDate(0, 0, 1), activities))
+  }
+
+  rates, err := s.Database.FindRates()
+  if err != nil {
+    http.Error(w, err.Error(), http.StatusInternalServerError)
+    return
+  }
+  var total Duration
+  var earned MoneyList
+  data.Projects, total, earned = projectTotals(s.Clock, rates, activities)
+  data.Total = total.String()
+  data.Earned = earned.String()
+
+  w.Header().Set("Content-Type", "text/html; charset=utf-8")
+  w.WriteHeader(status)
+  dashboardTemplate.Execute(w, data)
+}
+
+/* form handlers redirect back to the dashboard */
+func (s *Server) uiStart(w http.ResponseWriter, r *http.Request) {
+  name := strings.TrimSpace(r.FormValue("name"))
+  if name == "" {
+    s.dashboard(w, r, http.StatusBadRequest, "name is required")
+    return
+  }
+
+  args := []string{name}
+  project := strings.TrimSpace(r.FormValue("project"))
+  tags := strings.Fields(r.FormValue("tags"))
+  if project != "" || len(tags) > 0 {
+    args = append(append(args, project), tags...)
+  }
+  s.uiCommand(w, r, StartCommand{Templates: s.Templates, Strict: s.Strict}, args...)
+}
+
+func (s *Server) uiStop(w http.ResponseWriter, r *http.Request) {
+  s.uiCommand(w, r, StopCommand{MaxRunning: s.MaxRunning, EndOfDay: s.EndOfDay})
+}
+
+func (s *Server) uiCommand(w http.ResponseWriter, r *http.Request, cmd Command, args ...string) {
+  _, err := cmd.Run(s.Clock, s.Database, args...)
+  if err != nil {
+    s.dashboard(w, r, commandErrorStatus(err), err.Error())
+    return
+  }
+  http.Redirect(w, r, "/", http.StatusSeeOther)
+}
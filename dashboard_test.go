@@ -0,0 +1,130 @@
+package hourglass
+
+import (
+  "testing"
+  "net/http"
+  "net/http/httptest"
+  "net/url"
+  "strings"
+)
+
+func serverTestForm(s *Server, path string, form url.Values) *httptest.ResponseRecorder {
+  req := httptest.NewRequest("POST", path, strings.NewReader(form.Encode()))
+  req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+  w := httptest.NewRecorder()
+  s.ServeHTTP(w, req)
+  return w
+}
+
+func TestServer_Dashboard(t *testing.T) {
+  s, _ := newTestServer(
+    &Activity{Name: "foo", Project: "bar", Start: when(2013, 4, 26, 6), End: when(2013, 4, 26, 9)},
+    &Activity{Name: "<baz>", Start: when(2013, 4, 26, 9)},
+    &Activity{Name: "qux", Start: when(2013, 4, 22, 9), End: when(2013, 4, 22, 10)},
+  )
+
+  w := serverTestRequest(s, "GET", "/", "")
+  if w.Code != http.StatusOK {
+    t.Errorf("expected status 200, got %d", w.Code)
+    return
+  }
+  body := w.Body.String()
+  for _, expected := range []string{
+    "&lt;baz&gt;", "03h00m", "Fri Apr 26",
+    `style="left: 25.00%; width: 12.50%"`, /* foo from 6 to 9 */
+    `class="bar running" style="left: 37.50%; width: 12.50%"`, /* baz from 9 to noon */
+    "<td>bar</td><td>03h00m</td>", "<td>unsorted</td><td>03h00m</td>",
+    "<th>06h00m</th>",
+  } {
+    if !strings.Contains(body, expected) {
+      t.Errorf("expected dashboard to contain %q", expected)
+    }
+  }
+  if strings.Contains(body, "Mon Apr 22") {
+    t.Error("expected only today in timeline")
+  }
+
+  w = serverTestRequest(s, "GET", "/?range=week", "")
+  body = w.Body.String()
+  for _, expected := range []string{"Sun Apr 21", "Mon Apr 22", "Sat Apr 27", "<th>07h00m</th>"} {
+    if !strings.Contains(body, expected) {
+      t.Errorf("expected weekly dashboard to contain %q", expected)
+    }
+  }
+}
+
+func TestServer_DashboardStartAndStop(t *testing.T) {
+  s, db := newTestServer()
+
+  form := url.Values{"name": {"foo"}, "project": {"bar"}, "tags": {"baz qux"}}
+  w := serverTestForm(s, "/ui/start", form)
+  if w.Code != http.StatusSeeOther {
+    t.Errorf("expected status 303, got %d", w.Code)
+  }
+  expected := &Activity{Id: 1, Name: "foo", Project: "bar", Tags: []string{"baz", "qux"},
+    Start: when(2013, 4, 26, 12)}
+  if !expected.Equal(db.activities[1]) {
+    t.Errorf("expected %v, got %v", expected, db.activities[1])
+  }
+
+  w = serverTestForm(s, "/ui/stop", nil)
+  if w.Code != http.StatusSeeOther {
+    t.Errorf("expected status 303, got %d", w.Code)
+  }
+  if db.activities[1].IsRunning() {
+    t.Error("expected activity to be stopped")
+  }
+
+  w = serverTestForm(s, "/ui/start", nil)
+  if w.Code != http.StatusBadRequest {
+    t.Errorf("expected status 400, got %d", w.Code)
+  } else if !strings.Contains(w.Body.String(), "name is required") {
+    t.Error("expected error message on dashboard")
+  }
+
+  w = serverTestRequest(s, "GET", "/ui/start", "")
+  if w.Code != http.StatusMethodNotAllowed {
+    t.Errorf("expected status 405, got %d", w.Code)
+  }
+}
+
+func TestServer_DashboardCrossSite(t *testing.T) {
+  s, db := newTestServer(&Activity{Name: "foo", Start: when(2013, 4, 26, 9)})
+
+  /* browsers say where a form was posted from */
+  for i, headers := range []map[string]string{
+    {"Origin": "http://evil.example"},
+    {"Origin": "null"},
+    {"Sec-Fetch-Site": "cross-site"},
+    {"Sec-Fetch-Site": "same-site", "Origin": "http://other.example.com"},
+  } {
+    for _, path := range []string{"/ui/start", "/ui/stop"} {
+      form := url.Values{"name": {"bar"}}
+      req := httptest.NewRequest("POST", "http://example.com" + path, strings.NewReader(form.Encode()))
+      req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+      for key, value := range headers {
+        req.Header.Set(key, value)
+      }
+      w := httptest.NewRecorder()
+      s.ServeHTTP(w, req)
+      if w.Code != http.StatusForbidden {
+        t.Errorf("test %d: %s: expected status 403, got %d", i, path, w.Code)
+      }
+    }
+  }
+  if len(db.activities) != 1 || !db.activities[1].IsRunning() {
+    t.Errorf("activities were changed: %v", db.activities)
+  }
+
+  /* posted from the dashboard itself */
+  req := httptest.NewRequest("POST", "http://example.com/ui/stop", nil)
+  req.Header.Set("Origin", "http://example.com")
+  req.Header.Set("Sec-Fetch-Site", "same-origin")
+  w := httptest.NewRecorder()
+  s.ServeHTTP(w, req)
+  if w.Code != http.StatusSeeOther {
+    t.Errorf("expected status 303, got %d", w.Code)
+  } else if db.activities[1].IsRunning() {
+    t.Error("expected activity to be stopped")
+  }
+}
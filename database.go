@@ -7,6 +7,7 @@ import (
 )
 
 var ErrNotFound = errors.New("record not found")
+var ErrProjectExists = errors.New("project already exists")
 
 /* error helper */
 type DatabaseErrors struct {
@@ -33,4 +34,36 @@ type Database interface {
   FindRunningActivities() ([]*Activity, error)
   FindActivitiesBetween(time.Time, time.Time) ([]*Activity, error)
   DeleteActivity(id int64) error
+  SaveRate(*Rate) error
+  FindRates() ([]*Rate, error)
+  DeleteRate(project, tag string) error
+  SaveProject(*Project) error
+  FindProjects() ([]*Project, error)
+  RenameProject(old, new string) error
+  SaveGoal(*Goal) error
+  FindGoals() ([]*Goal, error)
+  DeleteGoal(project, tag string) error
+}
+
+/* backends whose storage grows with every change, like Jsonl */
+type Compacter interface {
+  Compact() (before, after int, err error)
+}
+
+/* databases that tell restarted activities apart from new ones, like HookDatabase */
+type Restarter interface {
+  RestartActivity(*Activity) error
+}
+
+/* save a restarted activity, as a restart if the database cares */
+func saveRestart(db Database, a *Activity) error {
+  if restarter, ok := db.(Restarter); ok {
+    return restarter.RestartActivity(a)
+  }
+  return db.SaveActivity(a)
+}
+
+/* databases that add behaviour to another database */
+type DatabaseWrapper interface {
+  Unwrap() Database
 }
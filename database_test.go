@@ -0,0 +1,73 @@
+package hourglass_test
+
+import (
+  "database/sql"
+  "io/ioutil"
+  "os"
+  "path/filepath"
+  "testing"
+  sqlite "github.com/mattn/go-sqlite3"
+  "hourglass"
+  "hourglass/hourglasstest"
+)
+
+/* the backends that come with hourglass pass the suite for other backends */
+func TestMemory_Suite(t *testing.T) {
+  hourglasstest.RunDatabaseSuite(t, func(t *testing.T) hourglass.Database {
+    return &hourglass.Memory{}
+  })
+}
+
+/* each database gets its own directory, for the sidecar files */
+func tempDir(t *testing.T) string {
+  dir, err := ioutil.TempDir("", "hourglass")
+  if err != nil {
+    t.Fatal(err)
+  }
+  t.Cleanup(func() { os.RemoveAll(dir) })
+  return dir
+}
+
+func TestCsv_Suite(t *testing.T) {
+  hourglasstest.RunDatabaseSuite(t, func(t *testing.T) hourglass.Database {
+    db, err := hourglass.NewCsv(filepath.Join(tempDir(t), "hourglass.csv"))
+    if err == nil {
+      err = db.Migrate()
+    }
+    if err != nil {
+      t.Fatal(err)
+    }
+    return db
+  })
+}
+
+func TestJsonl_Suite(t *testing.T) {
+  hourglasstest.RunDatabaseSuite(t, func(t *testing.T) hourglass.Database {
+    db := &hourglass.Jsonl{Filename: filepath.Join(tempDir(t), "hourglass.jsonl")}
+    err := db.Migrate()
+    if err != nil {
+      t.Fatal(err)
+    }
+    return db
+  })
+}
+
+func TestSql_Suite(t *testing.T) {
+  /* the package's own tests might have registered the driver already */
+  registered := false
+  for _, name := range sql.Drivers() {
+    registered = registered || name == "sqlite"
+  }
+  if !registered {
+    sql.Register("sqlite", &sqlite.SQLiteDriver{})
+  }
+  hourglasstest.RunDatabaseSuite(t, func(t *testing.T) hourglass.Database {
+    db := &hourglass.Sql{DriverName: "sqlite",
+      DataSourceName: filepath.Join(tempDir(t), "hourglass.db")}
+    err := db.Migrate()
+    if err != nil {
+      t.Fatal(err)
+    }
+    return db
+  })
+}
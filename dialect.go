@@ -0,0 +1,103 @@
+package hourglass
+
+import (
+  "strconv"
+  "strings"
+)
+
+/*
+ * Differences between the databases the Sql backend can use. Queries are
+ * written for SQLite, with ? placeholders and identifiers that are reserved
+ * words in double quotes, and rewritten for the others.
+ */
+type SqlDialect struct {
+  Name string
+  /* placeholder for the nth argument, counting from 1 */
+  Placeholder func(n int) string
+  /* quote for identifiers */
+  Quote string
+  /* new ids come from INSERT ... RETURNING id instead of LastInsertId */
+  Returning bool
+
+  /* column types */
+  Serial string
+  Integer string
+  Timestamp string
+  /* text that can be indexed */
+  Key string
+  /* text column that can be added to a table with rows */
+  DefaultText string
+}
+
+func questionPlaceholder(n int) string {
+  return "?"
+}
+
+func dollarPlaceholder(n int) string {
+  return "$" + strconv.Itoa(n)
+}
+
+var SqliteDialect = &SqlDialect{
+  Name: "sqlite",
+  Placeholder: questionPlaceholder,
+  Quote: `"`,
+  Serial: "INTEGER PRIMARY KEY",
+  Integer: "INTEGER",
+  Timestamp: "TIMESTAMP",
+  Key: "TEXT",
+  DefaultText: "TEXT NOT NULL DEFAULT ''",
+}
+
+var PostgresDialect = &SqlDialect{
+  Name: "postgres",
+  Placeholder: dollarPlaceholder,
+  Quote: `"`,
+  Returning: true,
+  Serial: "BIGSERIAL PRIMARY KEY",
+  Integer: "BIGINT",
+  Timestamp: "TIMESTAMP",
+  Key: "TEXT",
+  DefaultText: "TEXT NOT NULL DEFAULT ''",
+}
+
+/* the data source name needs parseTime=true for timestamps to be read back */
+var MysqlDialect = &SqlDialect{
+  Name: "mysql",
+  Placeholder: questionPlaceholder,
+  Quote: "`",
+  Serial: "BIGINT PRIMARY KEY AUTO_INCREMENT",
+  Integer: "BIGINT",
+  Timestamp: "DATETIME(6)",
+  Key: "VARCHAR(255)",
+  /* TEXT columns can't have defaults, but existing rows get an empty string anyway */
+  DefaultText: "TEXT NOT NULL",
+}
+
+/* the dialect for a database/sql driver name, SQLite if it isn't known */
+func DialectFor(driverName string) *SqlDialect {
+  switch driverName {
+  case "postgres", "pgx":
+    return PostgresDialect
+  case "mysql":
+    return MysqlDialect
+  }
+  return SqliteDialect
+}
+
+/* rewrite a query's placeholders and quotes for the dialect */
+func (d *SqlDialect) rebind(query string) string {
+  var b strings.Builder
+  n := 0
+  for _, r := range query {
+    switch r {
+    case '?':
+      n++
+      b.WriteString(d.Placeholder(n))
+    case '"':
+      b.WriteString(d.Quote)
+    default:
+      b.WriteRune(r)
+    }
+  }
+  return b.String()
+}
@@ -0,0 +1,234 @@
+package hourglass
+
+import (
+  "database/sql"
+  "database/sql/driver"
+  "errors"
+  "io"
+  "strings"
+  "sync"
+  "testing"
+  "time"
+)
+
+/*
+ * database/sql driver that records queries instead of running them, so the
+ * queries for databases that aren't around can be checked. Queries return no
+ * rows, except for ids from INSERT ... RETURNING id. Like MySQL in strict
+ * mode, it refuses zero dates.
+ *
+ * Only the SQL is checked, not how PostgreSQL or MySQL run it. Untested are
+ * MySQL counting unchanged rows as unaffected (SaveProject and
+ * RenameProject), its sql_mode, the time zones and precision of stored
+ * times, and the column types in either database.
+ */
+type recordingDriver struct {
+  mutex sync.Mutex
+  queries map[string][]string
+}
+
+var recorder = &recordingDriver{queries: make(map[string][]string)}
+var registerRecorder sync.Once
+
+func recordedQueries(name string) []string {
+  registerRecorder.Do(func() {
+    sql.Register("recorder", recorder)
+  })
+  recorder.mutex.Lock()
+  defer recorder.mutex.Unlock()
+  return recorder.queries[name]
+}
+
+func (d *recordingDriver) record(name, query string) {
+  d.mutex.Lock()
+  defer d.mutex.Unlock()
+  d.queries[name] = append(d.queries[name], query)
+}
+
+func (d *recordingDriver) Open(name string) (driver.Conn, error) {
+  return &recordingConn{d, name}, nil
+}
+
+type recordingConn struct {
+  driver *recordingDriver
+  name string
+}
+
+func (c *recordingConn) Prepare(query string) (driver.Stmt, error) {
+  return &recordingStmt{c, query}, nil
+}
+func (c *recordingConn) Close() error {
+  return nil
+}
+func (c *recordingConn) Begin() (driver.Tx, error) {
+  return recordingTx{}, nil
+}
+
+type recordingTx struct{}
+
+func (recordingTx) Commit() error {
+  return nil
+}
+func (recordingTx) Rollback() error {
+  return nil
+}
+
+type recordingStmt struct {
+  conn *recordingConn
+  query string
+}
+
+func (s *recordingStmt) Close() error {
+  return nil
+}
+func (s *recordingStmt) NumInput() int {
+  return -1
+}
+func (s *recordingStmt) Exec(args []driver.Value) (driver.Result, error) {
+  s.conn.driver.record(s.conn.name, s.query)
+  for _, arg := range args {
+    if t, ok := arg.(time.Time); ok && t.IsZero() {
+      return nil, errors.New("invalid zero date")
+    }
+  }
+  return recordingResult{}, nil
+}
+func (s *recordingStmt) Query(args []driver.Value) (driver.Rows, error) {
+  s.conn.driver.record(s.conn.name, s.query)
+  rows := &recordingRows{}
+  if strings.Contains(s.query, "RETURNING id") {
+    rows.ids = []int64{1}
+  }
+  return rows, nil
+}
+
+type recordingResult struct{}
+
+func (recordingResult) LastInsertId() (int64, error) {
+  return 1, nil
+}
+func (recordingResult) RowsAffected() (int64, error) {
+  return 1, nil
+}
+
+type recordingRows struct {
+  ids []int64
+}
+
+func (r *recordingRows) Columns() []string {
+  return []string{"id"}
+}
+func (r *recordingRows) Close() error {
+  return nil
+}
+func (r *recordingRows) Next(dest []driver.Value) error {
+  if len(r.ids) == 0 {
+    return io.EOF
+  }
+  dest[0], r.ids = r.ids[0], r.ids[1:]
+  return nil
+}
+
+func TestDialectFor(t *testing.T) {
+  for driverName, expected := range map[string]*SqlDialect{
+    "sqlite": SqliteDialect, "sqlite3": SqliteDialect, "postgres": PostgresDialect,
+    "pgx": PostgresDialect, "mysql": MysqlDialect,
+  } {
+    if dialect := DialectFor(driverName); dialect != expected {
+      t.Errorf("%s: expected %s, got %s", driverName, expected.Name, dialect.Name)
+    }
+  }
+}
+
+var rebindTests = []struct {
+  dialect *SqlDialect
+  query string
+  expected string
+}{
+  {SqliteDialect, `SELECT id FROM activities WHERE "end" = ? AND start < ?`,
+    `SELECT id FROM activities WHERE "end" = ? AND start < ?`},
+  {PostgresDialect, `SELECT id FROM activities WHERE "end" = ? AND start < ?`,
+    `SELECT id FROM activities WHERE "end" = $1 AND start < $2`},
+  {MysqlDialect, `SELECT id FROM activities WHERE "end" = ? AND start < ?`,
+    "SELECT id FROM activities WHERE `end` = ? AND start < ?"},
+}
+
+func TestSqlDialect_Rebind(t *testing.T) {
+  for i, config := range rebindTests {
+    if query := config.dialect.rebind(config.query); query != config.expected {
+      t.Errorf("test %d: expected %q, got %q", i, config.expected, query)
+    }
+  }
+}
+
+var sqlDialectTests = []struct {
+  dialect *SqlDialect
+  contains []string
+  excludes []string
+}{
+  {
+    SqliteDialect,
+    []string{"id INTEGER PRIMARY KEY", `"end" TIMESTAMP`, `WHERE "end" IS NULL`,
+      "ADD COLUMN owner TEXT NOT NULL DEFAULT ''"},
+    []string{"RETURNING", "$1"},
+  },
+  {
+    PostgresDialect,
+    []string{"id BIGSERIAL PRIMARY KEY", "target BIGINT", `"end" TIMESTAMP`,
+      "VALUES($1, $2, $3, $4, $5, $6, $7)", "RETURNING id", `WHERE "end" IS NULL`, `WHERE "end" < $1`},
+    []string{"?", "`"},
+  },
+  {
+    MysqlDialect,
+    []string{"id BIGINT PRIMARY KEY AUTO_INCREMENT", "`end` DATETIME(6)", "name VARCHAR(255) UNIQUE",
+      "WHERE `end` IS NULL", "ADD COLUMN notes TEXT NOT NULL"},
+    []string{"RETURNING", `"`, "DEFAULT ''"},
+  },
+}
+
+func TestSql_Dialects(t *testing.T) {
+  for _, config := range sqlDialectTests {
+    /* also registers the driver */
+    name := "dialect-" + config.dialect.Name
+    recordedQueries(name)
+    db := &Sql{"recorder", name, nil, config.dialect}
+
+    err := db.Migrate()
+    if err != nil {
+      t.Errorf("%s: %s", config.dialect.Name, err)
+      continue
+    }
+    /* running, so without an end */
+    activity := &Activity{Name: "foo", Start: time.Now(), Pauses: []Pause{Pause{Start: time.Now()}}}
+    err = db.SaveActivity(activity)
+    if err != nil {
+      t.Errorf("%s: %s", config.dialect.Name, err)
+      continue
+    }
+    if activity.Id != 1 {
+      t.Errorf("%s: expected id 1, got %d", config.dialect.Name, activity.Id)
+    }
+    _, err = db.FindRunningActivities()
+    if err != nil {
+      t.Errorf("%s: %s", config.dialect.Name, err)
+      continue
+    }
+    err = db.SaveProject(&Project{Name: "foo"})
+    if err != nil {
+      t.Errorf("%s: %s", config.dialect.Name, err)
+      continue
+    }
+
+    queries := strings.Join(recordedQueries(name), "\n")
+    for _, s := range config.contains {
+      if !strings.Contains(queries, s) {
+        t.Errorf("%s: expected queries to contain %q:\n%s", config.dialect.Name, s, queries)
+      }
+    }
+    for _, s := range config.excludes {
+      if strings.Contains(queries, s) {
+        t.Errorf("%s: expected queries not to contain %q:\n%s", config.dialect.Name, s, queries)
+      }
+    }
+  }
+}
@@ -0,0 +1,96 @@
+package hourglass
+
+import (
+  "bufio"
+  "fmt"
+  "io"
+  "os"
+  "path/filepath"
+  "strings"
+)
+
+/* name of the file that sets defaults for a directory and everything under it */
+const DirConfigName = ".hourglass"
+
+/*
+ * Defaults for activities started in a directory, read from lines like:
+ *
+ *   project: teamx
+ *   tag: backend
+ *   tag: api
+ *
+ * Blank lines and lines starting with # are skipped.
+ */
+type DirConfig struct {
+  Filename string
+  Project string
+  Tags []string
+}
+
+func ParseDirConfig(r io.Reader) (config *DirConfig, err error) {
+  config = &DirConfig{}
+  scanner := bufio.NewScanner(r)
+  for n := 1; scanner.Scan(); n++ {
+    line := strings.TrimSpace(scanner.Text())
+    if line == "" || strings.HasPrefix(line, "#") {
+      continue
+    }
+    colon := strings.Index(line, ":")
+    if colon < 0 {
+      err = fmt.Errorf("line %d: expected a key and value like project: teamx", n)
+      return
+    }
+    key, value := strings.TrimSpace(line[:colon]), strings.TrimSpace(line[colon + 1:])
+    switch key {
+    case "project":
+      config.Project = value
+    case "tag":
+      if value != "" {
+        config.Tags = append(config.Tags, value)
+      }
+    default:
+      err = fmt.Errorf("line %d: invalid key: %s", n, key)
+      return
+    }
+  }
+  err = scanner.Err()
+  return
+}
+
+/* the closest config file in dir or its parents, or nil if there isn't one */
+func FindDirConfig(dir string) (config *DirConfig, err error) {
+  dir, err = filepath.Abs(dir)
+  if err != nil {
+    return
+  }
+  for {
+    filename := filepath.Join(dir, DirConfigName)
+    info, statErr := os.Stat(filename)
+    if statErr == nil && info.Mode().IsRegular() {
+      return readDirConfig(filename)
+    }
+
+    parent := filepath.Dir(dir)
+    if parent == dir {
+      return nil, nil
+    }
+    dir = parent
+  }
+}
+
+func readDirConfig(filename string) (config *DirConfig, err error) {
+  var f *os.File
+  f, err = os.Open(filename)
+  if err != nil {
+    return
+  }
+  defer f.Close()
+
+  config, err = ParseDirConfig(f)
+  if err != nil {
+    err = fmt.Errorf("%s: %s", filename, err)
+    return
+  }
+  config.Filename = filename
+  return
+}
@@ -0,0 +1,83 @@
+package hourglass
+
+import (
+  "io/ioutil"
+  "os"
+  "path/filepath"
+  "reflect"
+  "strings"
+  "testing"
+)
+
+var parseDirConfigTests = []struct {
+  input string
+  config *DirConfig
+  err bool
+}{
+  {
+    "# team x\n\nproject: teamx\ntag:backend\n  tag: api  \n",
+    &DirConfig{Project: "teamx", Tags: []string{"backend", "api"}},
+    false,
+  },
+  {"", &DirConfig{}, false},
+  {"project teamx", nil, true},
+  {"name: foo", nil, true},
+}
+
+func TestParseDirConfig(t *testing.T) {
+  for i, config := range parseDirConfigTests {
+    result, err := ParseDirConfig(strings.NewReader(config.input))
+    if err != nil {
+      if !config.err {
+        t.Errorf("test %d: %s", i, err)
+      }
+      continue
+    } else if config.err {
+      t.Errorf("test %d: expected error, got nil", i)
+      continue
+    }
+    if !reflect.DeepEqual(result, config.config) {
+      t.Errorf("test %d: expected %+v, got %+v", i, config.config, result)
+    }
+  }
+}
+
+func TestFindDirConfig(t *testing.T) {
+  dir, err := ioutil.TempDir("", "hourglass")
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer os.RemoveAll(dir)
+
+  nested := filepath.Join(dir, "teamx", "src", "api")
+  err = os.MkdirAll(nested, 0755)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  /* the file is two levels up from where the search starts */
+  filename := filepath.Join(dir, "teamx", DirConfigName)
+  err = ioutil.WriteFile(filename, []byte("project: teamx\ntag: api\n"), 0644)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  config, err := FindDirConfig(nested)
+  if err != nil {
+    t.Fatal(err)
+  }
+  expected := &DirConfig{Filename: filename, Project: "teamx", Tags: []string{"api"}}
+  if !reflect.DeepEqual(config, expected) {
+    t.Errorf("expected %+v, got %+v", expected, config)
+  }
+
+  /* a bad file is an error rather than being ignored */
+  err = ioutil.WriteFile(filepath.Join(nested, DirConfigName), []byte("owner: me\n"), 0644)
+  if err != nil {
+    t.Fatal(err)
+  }
+  _, err = FindDirConfig(nested)
+  if err == nil {
+    t.Error("expected error, got nil")
+  }
+}
@@ -0,0 +1,223 @@
+package hourglass
+
+import (
+  "flag"
+  "fmt"
+  "io/ioutil"
+  "os"
+  "os/exec"
+  "path/filepath"
+  "strings"
+)
+
+/* help messages */
+const (
+  gitHookHelp = "Usage: %s git-hook <install|uninstall> [--mode notes|tag]\n\nRecord commits against running activities\n\nInstalls a post-commit hook in the current git repository. With the notes mode, the commit hash and message are added to the notes of each running activity. With the tag mode, running activities are tagged with the branch instead. The hook uses the same global options, like -csv and -db, that it was installed with."
+  gitCommitHelp = "Usage: %s git-commit [--mode notes|tag]\n\nRecord the last commit against running activities\n\nThis is run by the hook that git-hook installs."
+)
+
+/* git commit recording modes */
+const (
+  GitModeNotes = "notes"
+  GitModeTag = "tag"
+)
+
+/* marks hooks that hourglass installed, so they can be replaced */
+const gitHookMarker = "# installed by hourglass"
+
+/* runs git with some arguments, returning trimmed output */
+type GitRunner func(args ...string) (string, error)
+
+/* git in the current directory */
+func DefaultGit(args ...string) (output string, err error) {
+  var out []byte
+  out, err = exec.Command("git", args...).Output()
+  if exitErr, ok := err.(*exec.ExitError); ok {
+    err = fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(string(exitErr.Stderr)))
+  }
+  output = strings.TrimSpace(string(out))
+  return
+}
+
+func gitBranch(git GitRunner) (branch string, err error) {
+  branch, err = git("rev-parse", "--abbrev-ref", "HEAD")
+  if err == nil && branch == "HEAD" {
+    err = fmt.Errorf("not on a branch")
+  }
+  return
+}
+
+/* activity name and project from the branch and repository directory */
+func gitActivity(git GitRunner) (name, project string, err error) {
+  name, err = gitBranch(git)
+  if err != nil {
+    return
+  }
+  var top string
+  top, err = git("rev-parse", "--show-toplevel")
+  if err == nil {
+    project = filepath.Base(top)
+  }
+  return
+}
+
+func validGitMode(mode string) error {
+  if mode != GitModeNotes && mode != GitModeTag {
+    return SyntaxError(fmt.Sprint("invalid mode: ", mode))
+  }
+  return nil
+}
+
+/* git-hook */
+type GitHookCommand struct {
+  /* path to this program, for the hook to run */
+  Program string
+  /* global options like -csv and -db, so the hook uses the same database */
+  GlobalArgs []string
+  Mode string
+  Git GitRunner
+}
+
+func (cmd *GitHookCommand) Flags(fs *flag.FlagSet) {
+  fs.StringVar(&cmd.Mode, "mode", GitModeNotes, "what to record, notes or tag")
+}
+
+func (cmd GitHookCommand) Run(c Clock, db Database, args ...string) (output string, err error) {
+  if len(args) != 1 {
+    err = SyntaxError("expected install or uninstall")
+    return
+  }
+  if cmd.Mode == "" {
+    cmd.Mode = GitModeNotes
+  }
+  err = validGitMode(cmd.Mode)
+  if err != nil {
+    return
+  }
+  if cmd.Git == nil {
+    cmd.Git = DefaultGit
+  }
+
+  var hooksDir string
+  hooksDir, err = cmd.Git("rev-parse", "--path-format=absolute", "--git-path", "hooks")
+  if err != nil {
+    return
+  }
+  filename := filepath.Join(hooksDir, "post-commit")
+
+  /* don't clobber someone else's hook */
+  existing, readErr := ioutil.ReadFile(filename)
+  if readErr == nil && !strings.Contains(string(existing), gitHookMarker) {
+    err = fmt.Errorf("%s already exists and wasn't installed by hourglass", filename)
+    return
+  }
+
+  switch args[0] {
+  case "install":
+    command := []string{shellQuote(cmd.Program)}
+    for _, arg := range cmd.GlobalArgs {
+      command = append(command, shellQuote(arg))
+    }
+    script := fmt.Sprintf("#!/bin/sh\n%s\nexec %s git-commit --mode %s\n", gitHookMarker,
+      strings.Join(command, " "), cmd.Mode)
+    err = os.MkdirAll(hooksDir, 0755)
+    if err == nil {
+      err = ioutil.WriteFile(filename, []byte(script), 0755)
+    }
+    if err == nil {
+      output = fmt.Sprintf("installed %s", filename)
+    }
+
+  case "uninstall":
+    if readErr != nil {
+      err = fmt.Errorf("there isn't a hook to uninstall")
+      return
+    }
+    err = os.Remove(filename)
+    if err == nil {
+      output = fmt.Sprintf("removed %s", filename)
+    }
+
+  default:
+    err = SyntaxError(fmt.Sprint("invalid subcommand: ", args[0]))
+  }
+  return
+}
+
+func (GitHookCommand) Help() string {
+  return gitHookHelp
+}
+
+/* single quotes for sh */
+func shellQuote(s string) string {
+  return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+/* git-commit */
+type GitCommitCommand struct {
+  Mode string
+  Git GitRunner
+}
+
+func (cmd *GitCommitCommand) Flags(fs *flag.FlagSet) {
+  fs.StringVar(&cmd.Mode, "mode", GitModeNotes, "what to record, notes or tag")
+}
+
+func (cmd GitCommitCommand) Run(c Clock, db Database, args ...string) (output string, err error) {
+  if len(args) > 0 {
+    err = SyntaxError("too many arguments")
+    return
+  }
+  if cmd.Mode == "" {
+    cmd.Mode = GitModeNotes
+  }
+  err = validGitMode(cmd.Mode)
+  if err != nil {
+    return
+  }
+  if cmd.Git == nil {
+    cmd.Git = DefaultGit
+  }
+
+  var activities []*Activity
+  activities, err = db.FindRunningActivities()
+  if err != nil {
+    return
+  }
+  if len(activities) == 0 {
+    /* committing without a timer isn't a problem */
+    output = "there aren't any running activities"
+    return
+  }
+
+  var branch, commit string
+  branch, err = cmd.Git("rev-parse", "--abbrev-ref", "HEAD")
+  if err != nil {
+    return
+  }
+  commit, err = cmd.Git("log", "-1", "--format=%h %s")
+  if err != nil {
+    return
+  }
+
+  for _, activity := range activities {
+    if cmd.Mode == GitModeTag {
+      if branch == "HEAD" || containsTag(activity.Tags, branch) {
+        continue
+      }
+      activity.Tags = append(activity.Tags, branch)
+    } else {
+      activity.AddNote(fmt.Sprintf("commit %s (%s)", commit, branch))
+    }
+    err = db.SaveActivity(activity)
+    if err != nil {
+      return
+    }
+  }
+  output = fmt.Sprintf("recorded commit on %d activities", len(activities))
+  return
+}
+
+func (GitCommitCommand) Help() string {
+  return gitCommitHelp
+}
@@ -0,0 +1,182 @@
+package hourglass
+
+import (
+  "io/ioutil"
+  "os"
+  "os/exec"
+  "path/filepath"
+  "strings"
+  "testing"
+)
+
+/* run f with a new git repository on the feature/login branch */
+func gitTestRun(f func(dir string, git GitRunner), t *testing.T) {
+  if _, err := exec.LookPath("git"); err != nil {
+    t.Skip("git isn't available")
+  }
+  dir, err := ioutil.TempDir("", "hourglass")
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer os.RemoveAll(dir)
+  dir = filepath.Join(dir, "webapp")
+
+  git := func(args ...string) (string, error) {
+    return DefaultGit(append([]string{"-C", dir}, args...)...)
+  }
+  setup := [][]string{
+    {"init", "-q", dir},
+    {"-C", dir, "checkout", "-q", "-b", "feature/login"},
+    {"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com",
+      "commit", "-q", "--allow-empty", "-m", "Add login form"},
+  }
+  for _, args := range setup {
+    _, err = DefaultGit(args...)
+    if err != nil {
+      t.Fatal(err)
+    }
+  }
+  f(dir, git)
+}
+
+func TestStartCommand_Run_FromGit(t *testing.T) {
+  f := func(dir string, git GitRunner) {
+    db := &Memory{}
+    c := fakeCmdClock{when(2013, 4, 26, 9)}
+    cmd := StartCommand{FromGit: true, Git: git}
+
+    _, err := cmd.Run(c, db)
+    if err != nil {
+      t.Fatal(err)
+    }
+    activity, _ := db.FindActivity(1)
+    if activity.Name != "feature/login" || activity.Project != "webapp" {
+      t.Errorf("expected feature/login (webapp), got %s (%s)", activity.Name, activity.Project)
+    }
+
+    /* the project and tags can still be given */
+    _, err = cmd.Run(c, db, "other", "review")
+    if err != nil {
+      t.Fatal(err)
+    }
+    activity, _ = db.FindActivity(2)
+    if activity.Name != "feature/login" || activity.Project != "other" ||
+      len(activity.Tags) != 1 || activity.Tags[0] != "review" {
+      t.Errorf("unexpected activity: %v", activity)
+    }
+  }
+  gitTestRun(f, t)
+}
+
+func TestGitHookCommand_Run(t *testing.T) {
+  f := func(dir string, git GitRunner) {
+    cmd := GitHookCommand{Program: "/usr/local/bin/hour glass", Mode: GitModeTag, Git: git}
+    c := fakeCmdClock{when(2013, 4, 26, 9)}
+    filename := filepath.Join(dir, ".git", "hooks", "post-commit")
+
+    output, err := cmd.Run(c, nil, "install")
+    if err != nil {
+      t.Fatal(err)
+    }
+    if output != "installed " + filename {
+      t.Errorf("unexpected output: %q", output)
+    }
+    data, _ := ioutil.ReadFile(filename)
+    if !strings.Contains(string(data), "exec '/usr/local/bin/hour glass' git-commit --mode tag\n") {
+      t.Errorf("unexpected hook:\n%s", data)
+    }
+
+    /* reinstalling replaces the hook */
+    _, err = cmd.Run(c, nil, "install")
+    if err != nil {
+      t.Error(err)
+    }
+    _, err = cmd.Run(c, nil, "uninstall")
+    if err != nil {
+      t.Error(err)
+    }
+    if _, statErr := os.Stat(filename); !os.IsNotExist(statErr) {
+      t.Error("expected hook to be removed")
+    }
+
+    /* other hooks are left alone */
+    ioutil.WriteFile(filename, []byte("#!/bin/sh\nmake lint\n"), 0755)
+    _, err = cmd.Run(c, nil, "install")
+    if err == nil {
+      t.Error("expected error replacing someone else's hook")
+    }
+
+    /* the hook uses the same database */
+    os.Remove(filename)
+    cmd.GlobalArgs = []string{"-csv", "-db", "/home/me/time sheets.csv"}
+    _, err = cmd.Run(c, nil, "install")
+    if err != nil {
+      t.Fatal(err)
+    }
+    data, _ = ioutil.ReadFile(filename)
+    expected := "exec '/usr/local/bin/hour glass' '-csv' '-db' '/home/me/time sheets.csv' git-commit --mode tag\n"
+    if !strings.Contains(string(data), expected) {
+      t.Errorf("unexpected hook:\n%s", data)
+    }
+
+    _, err = GitHookCommand{Mode: "junk", Git: git}.Run(c, nil, "install")
+    if _, ok := err.(SyntaxError); !ok {
+      t.Errorf("expected error type SyntaxError, got %T", err)
+    }
+  }
+  gitTestRun(f, t)
+}
+
+func TestGitCommitCommand_Run(t *testing.T) {
+  f := func(dir string, git GitRunner) {
+    db := &Memory{}
+    db.SaveActivity(&Activity{Name: "foo", Start: when(2013, 4, 26, 8), End: when(2013, 4, 26, 9)})
+    db.SaveActivity(&Activity{Name: "bar", Start: when(2013, 4, 26, 9)})
+    c := fakeCmdClock{when(2013, 4, 26, 10)}
+    hash, _ := git("log", "-1", "--format=%h")
+
+    output, err := GitCommitCommand{Git: git}.Run(c, db)
+    if err != nil {
+      t.Fatal(err)
+    }
+    if output != "recorded commit on 1 activities" {
+      t.Errorf("unexpected output: %q", output)
+    }
+    activity, _ := db.FindActivity(2)
+    expected := "commit " + hash + " Add login form (feature/login)"
+    if activity.Notes != expected {
+      t.Errorf("expected notes %q, got %q", expected, activity.Notes)
+    }
+    stopped, _ := db.FindActivity(1)
+    if stopped.Notes != "" {
+      t.Errorf("expected stopped activity to be left alone, got %q", stopped.Notes)
+    }
+
+    /* tagging only adds the branch once */
+    for i := 0; i < 2; i++ {
+      _, err = GitCommitCommand{Mode: GitModeTag, Git: git}.Run(c, db)
+      if err != nil {
+        t.Fatal(err)
+      }
+    }
+    activity, _ = db.FindActivity(2)
+    if len(activity.Tags) != 1 || activity.Tags[0] != "feature/login" {
+      t.Errorf("expected branch tag, got %q", activity.Tags)
+    }
+  }
+  gitTestRun(f, t)
+}
+
+func TestGitHookCommand_Help(t *testing.T) {
+  cmd := GitHookCommand{}
+  if cmd.Help() == "" {
+    t.Error("no help available")
+  }
+}
+
+func TestGitCommitCommand_Help(t *testing.T) {
+  cmd := GitCommitCommand{}
+  if cmd.Help() == "" {
+    t.Error("no help available")
+  }
+}
@@ -0,0 +1,284 @@
+package hourglass
+
+import (
+  "fmt"
+  "strings"
+  "time"
+)
+
+/* help messages */
+const (
+  goalHelp = "Usage: %s goal <set|list|delete> [arguments]\n\nManage time goals and budgets\n\n\tset <project>[:<tag>] <duration>/<day|week|month> [cap]\n\tlist\n\tdelete <project>[:<tag>]\n\nA goal is a target to reach, like 20h/week. With cap, it's a budget that shouldn't be exceeded instead. Targets work like rates: :meetings is the meetings tag in any project."
+  goalsHelp = "Usage: %s goals\n\nShow progress towards goals and budgets for the current day, week or month"
+)
+
+/* goal periods */
+const (
+  PeriodDay = "day"
+  PeriodWeek = "week"
+  PeriodMonth = "month"
+)
+
+/* width of progress bars, in characters */
+const goalBarWidth = 20
+
+/* time to spend on a project or tag, or not to exceed if it's a cap */
+type Goal struct {
+  Project string
+  Tag string
+  Target Duration
+  Period string
+  Cap bool
+}
+
+func (g *Goal) Name() string {
+  if g.Tag == "" {
+    return g.Project
+  }
+  return g.Project + ":" + g.Tag
+}
+
+/* like 20h00m/week */
+func (g *Goal) Spec() string {
+  return fmt.Sprintf("%s/%s", g.Target, g.Period)
+}
+
+func (g *Goal) Matches(activity *Activity) bool {
+  if g.Project != "" && g.Project != activity.Project {
+    return false
+  }
+  return g.Tag == "" || containsTag(activity.Tags, g.Tag)
+}
+
+/* parse a goal like 20h/week */
+func parseGoalSpec(spec string) (target Duration, period string, err error) {
+  slash := strings.LastIndex(spec, "/")
+  if slash < 0 {
+    err = SyntaxError("expected a goal like 20h/week")
+    return
+  }
+  period = spec[slash + 1:]
+  if period != PeriodDay && period != PeriodWeek && period != PeriodMonth {
+    err = SyntaxError(fmt.Sprint("invalid period: ", period))
+    return
+  }
+  var d time.Duration
+  d, err = time.ParseDuration(spec[:slash])
+  if err != nil || d <= 0 {
+    err = SyntaxError(fmt.Sprint("invalid duration: ", spec[:slash]))
+    return
+  }
+  target = Duration(d)
+  return
+}
+
+/* the first of the month to the first of next month */
+func monthRange(now time.Time) (lower, upper time.Time) {
+  lower = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+  upper = lower.AddDate(0, 1, 0)
+  return
+}
+
+func periodRange(period string, now time.Time) (lower, upper time.Time) {
+  switch period {
+  case PeriodDay:
+    return dayRange(now)
+  case PeriodMonth:
+    return monthRange(now)
+  }
+  return weekRange(now)
+}
+
+/* time spent towards a goal in its current period */
+type goalProgress struct {
+  goal *Goal
+  spent Duration
+}
+
+func (p goalProgress) Exceeded() bool {
+  return p.spent > p.goal.Target
+}
+
+/* [#########-----------] 45% */
+func (p goalProgress) Bar() string {
+  percent := int(int64(p.spent) * 100 / int64(p.goal.Target))
+  filled := percent * goalBarWidth / 100
+  if filled > goalBarWidth {
+    filled = goalBarWidth
+  }
+  return fmt.Sprintf("[%s%s] %d%%", strings.Repeat("#", filled),
+    strings.Repeat("-", goalBarWidth - filled), percent)
+}
+
+func (p goalProgress) Remaining() string {
+  if p.Exceeded() {
+    return fmt.Sprintf("%s over", p.spent - p.goal.Target)
+  }
+  return fmt.Sprintf("%s left", p.goal.Target - p.spent)
+}
+
+/* progress for each goal, loading each period's activities once */
+func findGoalProgress(c Clock, db Database, goals []*Goal) (progress []goalProgress, err error) {
+  now := c.Now()
+  periods := make(map[string][]*Activity)
+  for _, goal := range goals {
+    activities, ok := periods[goal.Period]
+    if !ok {
+      lower, upper := periodRange(goal.Period, now)
+      activities, err = db.FindActivitiesBetween(lower, upper)
+      if err != nil {
+        return
+      }
+      periods[goal.Period] = activities
+    }
+
+    p := goalProgress{goal: goal}
+    for _, activity := range activities {
+      if goal.Matches(activity) {
+        p.spent += activity.Duration(c)
+      }
+    }
+    progress = append(progress, p)
+  }
+  return
+}
+
+/* one line for each exceeded budget */
+func budgetWarnings(c Clock, db Database) (warnings []string, err error) {
+  var goals []*Goal
+  goals, err = db.FindGoals()
+  if err != nil {
+    return
+  }
+  var caps []*Goal
+  for _, goal := range goals {
+    if goal.Cap {
+      caps = append(caps, goal)
+    }
+  }
+
+  var progress []goalProgress
+  progress, err = findGoalProgress(c, db, caps)
+  if err != nil {
+    return
+  }
+  for _, p := range progress {
+    if p.Exceeded() {
+      warnings = append(warnings, fmt.Sprintf("warning: %s is %s its budget of %s",
+        p.goal.Name(), p.Remaining(), p.goal.Spec()))
+    }
+  }
+  return
+}
+
+/* goal */
+type GoalCommand struct{}
+
+func (GoalCommand) Run(c Clock, db Database, args ...string) (output string, err error) {
+  if len(args) == 0 {
+    err = SyntaxError("missing subcommand")
+    return
+  }
+
+  switch args[0] {
+  case "set":
+    if len(args) < 3 || len(args) > 4 || len(args) == 4 && args[3] != "cap" {
+      err = SyntaxError("expected a target and a goal like 20h/week")
+      return
+    }
+    goal := &Goal{Cap: len(args) == 4}
+    goal.Project, goal.Tag = parseRateTarget(args[1])
+    if goal.Project == "" && goal.Tag == "" {
+      err = SyntaxError("missing project or tag")
+      return
+    }
+    goal.Target, goal.Period, err = parseGoalSpec(args[2])
+    if err != nil {
+      return
+    }
+    err = db.SaveGoal(goal)
+    if err == nil {
+      output = fmt.Sprintf("saved goal for %s", args[1])
+    }
+
+  case "list":
+    var goals []*Goal
+    goals, err = db.FindGoals()
+    if err != nil {
+      return
+    }
+    if len(goals) == 0 {
+      output = "there aren't any goals"
+      return
+    }
+    output = "| goal\t| target\t| kind\t|"
+    for _, goal := range goals {
+      kind := "target"
+      if goal.Cap {
+        kind = "cap"
+      }
+      output += fmt.Sprintf("\n| %s\t| %s\t| %s\t|", goal.Name(), goal.Spec(), kind)
+    }
+
+  case "delete":
+    if len(args) != 2 {
+      err = SyntaxError("missing target")
+      return
+    }
+    project, tag := parseRateTarget(args[1])
+    err = db.DeleteGoal(project, tag)
+    if err == ErrNotFound {
+      err = fmt.Errorf("there isn't a goal for %s", args[1])
+    } else if err == nil {
+      output = fmt.Sprintf("deleted goal for %s", args[1])
+    }
+
+  default:
+    err = SyntaxError(fmt.Sprint("invalid subcommand: ", args[0]))
+  }
+  return
+}
+
+func (GoalCommand) Help() string {
+  return goalHelp
+}
+
+/* goals */
+type GoalsCommand struct{}
+
+func (GoalsCommand) Run(c Clock, db Database, args ...string) (output string, err error) {
+  if len(args) > 0 {
+    err = SyntaxError("too many arguments")
+    return
+  }
+
+  var goals []*Goal
+  goals, err = db.FindGoals()
+  if err != nil {
+    return
+  }
+  if len(goals) == 0 {
+    output = "there aren't any goals"
+    return
+  }
+
+  var progress []goalProgress
+  progress, err = findGoalProgress(c, db, goals)
+  if err != nil {
+    return
+  }
+  output = "| goal\t| target\t| progress\t| spent\t| remaining\t|"
+  for _, p := range progress {
+    spec := p.goal.Spec()
+    if p.goal.Cap {
+      spec += " cap"
+    }
+    output += fmt.Sprintf("\n| %s\t| %s\t| %s\t| %s\t| %s\t|", p.goal.Name(), spec,
+      p.Bar(), p.spent, p.Remaining())
+  }
+  return
+}
+
+func (GoalsCommand) Help() string {
+  return goalsHelp
+}
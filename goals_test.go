@@ -0,0 +1,202 @@
+package hourglass
+
+import (
+  "strings"
+  "testing"
+  "time"
+)
+
+var goalSpecTests = []struct {
+  spec string
+  target Duration
+  period string
+  err bool
+}{
+  {"20h/week", Duration(20 * time.Hour), PeriodWeek, false},
+  {"1h30m/day", Duration(90 * time.Minute), PeriodDay, false},
+  {"160h/month", Duration(160 * time.Hour), PeriodMonth, false},
+  {"20h", 0, "", true},
+  {"20h/year", 0, "", true},
+  {"junk/week", 0, "", true},
+  {"0h/week", 0, "", true},
+}
+
+func TestParseGoalSpec(t *testing.T) {
+  for i, config := range goalSpecTests {
+    target, period, err := parseGoalSpec(config.spec)
+    if err != nil {
+      if !config.err {
+        t.Errorf("test %d: %s", i, err)
+      } else if _, ok := err.(SyntaxError); !ok {
+        t.Errorf("test %d: expected error type SyntaxError, got %T", i, err)
+      }
+      continue
+    } else if config.err {
+      t.Errorf("test %d: expected error, got nil", i)
+      continue
+    }
+    if target != config.target || period != config.period {
+      t.Errorf("test %d: expected %s/%s, got %s/%s", i, config.target, config.period,
+        target, period)
+    }
+  }
+}
+
+var goalCommandTests = []struct {
+  args []string
+  output string
+  err bool
+}{
+  {[]string{"set", "teamx", "20h/week"}, "saved goal for teamx", false},
+  {[]string{"set", ":meetings", "1h/day", "cap"}, "saved goal for :meetings", false},
+  {[]string{"set", "teamy", "10h/week", "junk"}, "", true},
+  {[]string{"set", "teamy", "10h"}, "", true},
+  {[]string{"set", ":", "10h/week"}, "", true},
+  {
+    []string{"list"},
+    "| goal\t| target\t| kind\t|\n" +
+    "| :meetings\t| 01h00m/day\t| cap\t|\n" +
+    "| teamx\t| 20h00m/week\t| target\t|",
+    false,
+  },
+  {[]string{"delete", "teamx"}, "deleted goal for teamx", false},
+  {[]string{"delete", "teamx"}, "", true},
+  {[]string{"junk"}, "", true},
+  {nil, "", true},
+}
+
+func TestGoalCommand_Run(t *testing.T) {
+  db := &Memory{}
+  c := fakeCmdClock{when(2013, 4, 26, 12)}
+  for i, config := range goalCommandTests {
+    output, err := GoalCommand{}.Run(c, db, config.args...)
+    if err != nil {
+      if !config.err {
+        t.Errorf("test %d: %s", i, err)
+      }
+      continue
+    } else if config.err {
+      t.Errorf("test %d: expected error, got nil", i)
+      continue
+    }
+    if output != config.output {
+      t.Errorf("test %d: expected %q, got %q", i, config.output, output)
+    }
+  }
+}
+
+func goalsTestDb() *Memory {
+  db := &Memory{}
+  db.SaveActivity(&Activity{Name: "a", Project: "teamx",
+    Start: when(2013, 4, 2, 8), End: when(2013, 4, 2, 12)})
+  db.SaveActivity(&Activity{Name: "b", Project: "teamx",
+    Start: when(2013, 4, 22, 9), End: when(2013, 4, 22, 17)})
+  db.SaveActivity(&Activity{Name: "c", Project: "teamx",
+    Start: when(2013, 4, 26, 8), End: when(2013, 4, 26, 10)})
+  db.SaveActivity(&Activity{Name: "d", Project: "teamy", Tags: []string{"meetings"},
+    Start: when(2013, 4, 26, 10)})
+  db.SaveGoal(&Goal{"teamx", "", Duration(20 * time.Hour), PeriodWeek, false})
+  db.SaveGoal(&Goal{"teamx", "", Duration(40 * time.Hour), PeriodMonth, false})
+  db.SaveGoal(&Goal{"", "meetings", Duration(time.Hour), PeriodDay, true})
+  return db
+}
+
+func TestGoalsCommand_Run(t *testing.T) {
+  db := goalsTestDb()
+  c := fakeCmdClock{when(2013, 4, 26, 12)}
+
+  output, err := GoalsCommand{}.Run(c, db)
+  if err != nil {
+    t.Error(err)
+    return
+  }
+  /* the month goal replaced the week goal for teamx */
+  expected := "| goal\t| target\t| progress\t| spent\t| remaining\t|\n" +
+    "| :meetings\t| 01h00m/day cap\t| [####################] 200%\t| 02h00m\t| 01h00m over\t|\n" +
+    "| teamx\t| 40h00m/month\t| [#######-------------] 35%\t| 14h00m\t| 26h00m left\t|"
+  outputOk, diff, checkErr := checkStringsEqual(expected, output)
+  if !outputOk {
+    if checkErr == nil {
+      t.Errorf("bad output:\n%s", diff)
+    } else {
+      t.Errorf("output didn't match, but couldn't create diff: %s", checkErr)
+    }
+  }
+
+  output, err = GoalsCommand{}.Run(c, &Memory{})
+  if err != nil || output != "there aren't any goals" {
+    t.Errorf("expected no goals, got %q, %v", output, err)
+  }
+  _, err = GoalsCommand{}.Run(c, db, "junk")
+  if _, ok := err.(SyntaxError); !ok {
+    t.Errorf("expected error type SyntaxError, got %T", err)
+  }
+}
+
+func TestGoalsCommand_Run_WeekTarget(t *testing.T) {
+  db := goalsTestDb()
+  db.goals = nil
+  db.SaveGoal(&Goal{"teamx", "", Duration(20 * time.Hour), PeriodWeek, false})
+  c := fakeCmdClock{when(2013, 4, 26, 12)}
+
+  output, err := GoalsCommand{}.Run(c, db)
+  if err != nil {
+    t.Error(err)
+    return
+  }
+  expected := "| goal\t| target\t| progress\t| spent\t| remaining\t|\n" +
+    "| teamx\t| 20h00m/week\t| [##########----------] 50%\t| 10h00m\t| 10h00m left\t|"
+  if output != expected {
+    t.Errorf("expected %q, got %q", expected, output)
+  }
+}
+
+func TestBudgetWarnings(t *testing.T) {
+  db := goalsTestDb()
+  c := fakeCmdClock{when(2013, 4, 26, 12)}
+  warning := "warning: :meetings is 01h00m over its budget of 01h00m/day"
+
+  output, err := StatusCommand{Budgets: true}.Run(c, db)
+  if err != nil {
+    t.Error(err)
+  } else if !strings.HasSuffix(output, "\n" + warning) {
+    t.Errorf("expected status to end with a warning, got %q", output)
+  }
+
+  output, err = StatusCommand{}.Run(c, db)
+  if err != nil {
+    t.Error(err)
+  } else if strings.Contains(output, "warning") {
+    t.Errorf("expected no warning without --budgets, got %q", output)
+  }
+
+  output, err = ListCommand{Budgets: true}.Run(c, db, "week")
+  if err != nil {
+    t.Error(err)
+  } else if !strings.HasSuffix(output, "\n\n" + warning) {
+    t.Errorf("expected week list to end with a warning, got %q", output)
+  }
+
+  /* targets aren't budgets */
+  db.DeleteGoal("", "meetings")
+  output, err = ListCommand{Budgets: true}.Run(c, db, "week")
+  if err != nil {
+    t.Error(err)
+  } else if strings.Contains(output, "warning") {
+    t.Errorf("expected no warnings, got %q", output)
+  }
+}
+
+func TestGoalCommand_Help(t *testing.T) {
+  cmd := GoalCommand{}
+  if cmd.Help() == "" {
+    t.Error("no help available")
+  }
+}
+
+func TestGoalsCommand_Help(t *testing.T) {
+  cmd := GoalsCommand{}
+  if cmd.Help() == "" {
+    t.Error("no help available")
+  }
+}
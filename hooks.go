@@ -0,0 +1,195 @@
+package hourglass
+
+import (
+  "bufio"
+  "bytes"
+  "context"
+  "encoding/json"
+  "fmt"
+  "io"
+  "os"
+  "os/exec"
+  "strconv"
+  "strings"
+  "time"
+)
+
+/* hook events */
+const (
+  HookStart = "start"
+  HookStop = "stop"
+  HookEdit = "edit"
+  HookDelete = "delete"
+  HookRestart = "restart"
+  /* matches every event */
+  HookAll = "*"
+)
+
+const DefaultHookTimeout = 10 * time.Second
+
+/* shell command run after an event */
+type Hook struct {
+  Event string
+  Command string
+}
+
+/*
+ * Hooks are read one per line, the event followed by the command, like:
+ *
+ *   stop curl -s -d @- https://example.com/timers
+ *
+ * Blank lines and lines starting with # are skipped.
+ */
+func ParseHooks(r io.Reader) (hooks []Hook, err error) {
+  scanner := bufio.NewScanner(r)
+  for n := 1; scanner.Scan(); n++ {
+    line := strings.TrimSpace(scanner.Text())
+    if line == "" || strings.HasPrefix(line, "#") {
+      continue
+    }
+    fields := strings.SplitN(line, " ", 2)
+    if len(fields) != 2 || strings.TrimSpace(fields[1]) == "" {
+      err = fmt.Errorf("line %d: expected an event and a command", n)
+      return
+    }
+    switch fields[0] {
+    case HookStart, HookStop, HookEdit, HookDelete, HookRestart, HookAll:
+    default:
+      err = fmt.Errorf("line %d: invalid event: %s", n, fields[0])
+      return
+    }
+    hooks = append(hooks, Hook{fields[0], strings.TrimSpace(fields[1])})
+  }
+  err = scanner.Err()
+  return
+}
+
+/* hooks from a file, which doesn't have to exist */
+func LoadHooks(filename string) (hooks []Hook, err error) {
+  var f *os.File
+  f, err = os.Open(filename)
+  if os.IsNotExist(err) {
+    return nil, nil
+  } else if err != nil {
+    return
+  }
+  defer f.Close()
+
+  hooks, err = ParseHooks(f)
+  if err != nil {
+    err = fmt.Errorf("%s: %s", filename, err)
+  }
+  return
+}
+
+/*
+ * Database that runs hooks after activities are saved or deleted, so every
+ * command and backend gets them. New activities are starts, or restarts
+ * when they come from RestartActivity, stopping a running activity is a stop
+ * and any other change is an edit.
+ */
+type HookDatabase struct {
+  Database
+  Clock Clock
+  Hooks []Hook
+  Timeout time.Duration
+  /* hook failures are reported here instead of failing the command */
+  Errors io.Writer
+}
+
+func (db *HookDatabase) Unwrap() Database {
+  return db.Database
+}
+
+func (db *HookDatabase) SaveActivity(a *Activity) (err error) {
+  event := HookEdit
+  if a.Id == 0 {
+    event = HookStart
+  } else if !a.IsRunning() {
+    previous, findErr := db.Database.FindActivity(a.Id)
+    if findErr == nil && previous.IsRunning() {
+      event = HookStop
+    }
+  }
+
+  err = db.Database.SaveActivity(a)
+  if err == nil {
+    db.run(event, a)
+  }
+  return
+}
+
+func (db *HookDatabase) RestartActivity(a *Activity) (err error) {
+  err = saveRestart(db.Database, a)
+  if err == nil {
+    db.run(HookRestart, a)
+  }
+  return
+}
+
+func (db *HookDatabase) DeleteActivity(id int64) (err error) {
+  activity, findErr := db.Database.FindActivity(id)
+  err = db.Database.DeleteActivity(id)
+  if err == nil && findErr == nil {
+    db.run(HookDelete, activity)
+  }
+  return
+}
+
+/* run each matching hook in turn */
+func (db *HookDatabase) run(event string, a *Activity) {
+  input, _ := json.Marshal(newActivityJSON(db.Clock, a))
+  env := append(os.Environ(), hookEnv(db.Clock, event, a)...)
+  for _, hook := range db.Hooks {
+    if hook.Event != event && hook.Event != HookAll {
+      continue
+    }
+    err := db.runHook(hook, input, env)
+    if err != nil && db.Errors != nil {
+      fmt.Fprintf(db.Errors, "%s hook failed: %s\n", event, err)
+    }
+  }
+}
+
+func (db *HookDatabase) runHook(hook Hook, input []byte, env []string) (err error) {
+  timeout := db.Timeout
+  if timeout <= 0 {
+    timeout = DefaultHookTimeout
+  }
+  ctx, cancel := context.WithTimeout(context.Background(), timeout)
+  defer cancel()
+
+  cmd := exec.CommandContext(ctx, "sh", "-c", hook.Command)
+  cmd.Stdin = bytes.NewReader(input)
+  cmd.Env = env
+  /* don't wait on anything the hook left running */
+  cmd.WaitDelay = time.Second
+  var out []byte
+  out, err = cmd.CombinedOutput()
+  if ctx.Err() == context.DeadlineExceeded {
+    err = fmt.Errorf("%s: timed out after %s", hook.Command, timeout)
+  } else if err != nil {
+    err = fmt.Errorf("%s: %s: %s", hook.Command, err, strings.TrimSpace(string(out)))
+  }
+  return
+}
+
+/* environment variables describing the event */
+func hookEnv(c Clock, event string, a *Activity) []string {
+  end := ""
+  if !a.End.IsZero() {
+    end = a.End.Format(time.RFC3339)
+  }
+  return []string{
+    "HOURGLASS_EVENT=" + event,
+    "HOURGLASS_ID=" + strconv.FormatInt(a.Id, 10),
+    "HOURGLASS_NAME=" + a.Name,
+    "HOURGLASS_PROJECT=" + a.Project,
+    "HOURGLASS_USER=" + a.User,
+    "HOURGLASS_TAGS=" + a.TagList(),
+    "HOURGLASS_STATUS=" + a.Status(),
+    "HOURGLASS_START=" + a.Start.Format(time.RFC3339),
+    "HOURGLASS_END=" + end,
+    "HOURGLASS_DURATION=" + a.Duration(c).String(),
+  }
+}
@@ -0,0 +1,171 @@
+package hourglass
+
+import (
+  "bytes"
+  "encoding/json"
+  "io/ioutil"
+  "net/http"
+  "os"
+  "path/filepath"
+  "strings"
+  "testing"
+  "time"
+)
+
+var parseHooksTests = []struct {
+  input string
+  hooks []Hook
+  err bool
+}{
+  {
+    "# post to chat\n\nstop curl -d @- http://example.com\n* logger  hourglass changed\n",
+    []Hook{{"stop", "curl -d @- http://example.com"}, {"*", "logger  hourglass changed"}},
+    false,
+  },
+  {"", nil, false},
+  {"stop", nil, true},
+  {"pause echo", nil, true},
+}
+
+func TestParseHooks(t *testing.T) {
+  for i, config := range parseHooksTests {
+    hooks, err := ParseHooks(strings.NewReader(config.input))
+    if err != nil {
+      if !config.err {
+        t.Errorf("test %d: %s", i, err)
+      }
+      continue
+    } else if config.err {
+      t.Errorf("test %d: expected error, got nil", i)
+      continue
+    }
+    if len(hooks) != len(config.hooks) {
+      t.Errorf("test %d: expected %v, got %v", i, config.hooks, hooks)
+      continue
+    }
+    for j, hook := range config.hooks {
+      if hooks[j] != hook {
+        t.Errorf("test %d: expected %v, got %v", i, hook, hooks[j])
+      }
+    }
+  }
+}
+
+func TestLoadHooks_WithMissingFile(t *testing.T) {
+  hooks, err := LoadHooks(filepath.Join(os.TempDir(), "hourglass-no-such-hooks"))
+  if err != nil || hooks != nil {
+    t.Errorf("expected no hooks, got %v, %v", hooks, err)
+  }
+}
+
+func TestHookDatabase(t *testing.T) {
+  dir, err := ioutil.TempDir("", "hourglass")
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer os.RemoveAll(dir)
+  log := filepath.Join(dir, "log")
+  last := filepath.Join(dir, "last.json")
+
+  errors := new(bytes.Buffer)
+  c := fakeCmdClock{when(2013, 4, 26, 12)}
+  db := &HookDatabase{Database: &Memory{}, Clock: c, Errors: errors, Hooks: []Hook{
+    {HookAll, `echo "$HOURGLASS_EVENT $HOURGLASS_ID $HOURGLASS_NAME $HOURGLASS_STATUS" >> ` + log},
+    {HookStop, "cat > " + last},
+    {HookDelete, "exit 3"},
+  }}
+
+  steps := []struct {
+    cmd Command
+    args []string
+  }{
+    {StartCommand{}, []string{"foo", "bar"}},
+    {PauseCommand{}, nil},
+    {StopCommand{}, nil},
+    {EditCommand{}, []string{"1", "name", "baz"}},
+    {RestartCommand{}, []string{"1"}},
+    {DeleteCommand{}, []string{"1"}},
+  }
+  for i, step := range steps {
+    _, err = step.cmd.Run(c, db, step.args...)
+    if err != nil {
+      t.Fatalf("step %d: %s", i, err)
+    }
+  }
+
+  data, _ := ioutil.ReadFile(log)
+  expected := "start 1 foo running\n" +
+    "edit 1 foo paused\n" +
+    "stop 1 foo stopped\n" +
+    "edit 1 baz stopped\n" +
+    "restart 2 baz running\n" +
+    "delete 1 baz stopped\n"
+  if string(data) != expected {
+    t.Errorf("expected hooks to log:\n%s\ngot:\n%s", expected, data)
+  }
+
+  var activity activityJSON
+  data, _ = ioutil.ReadFile(last)
+  err = json.Unmarshal(data, &activity)
+  if err != nil {
+    t.Error(err)
+  } else if activity.Id != 1 || activity.Project != "bar" || activity.Status != "stopped" {
+    t.Errorf("unexpected activity on stdin: %s", data)
+  }
+
+  /* failures are reported, but the command still works */
+  if !strings.HasPrefix(errors.String(), "delete hook failed: exit 3: ") {
+    t.Errorf("expected failure to be reported, got %q", errors.String())
+  }
+}
+
+func TestHookDatabase_Restart(t *testing.T) {
+  dir, err := ioutil.TempDir("", "hourglass")
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer os.RemoveAll(dir)
+  log := filepath.Join(dir, "log")
+
+  fake := &Memory{}
+  fake.SaveActivity(&Activity{Name: "foo", Start: when(2013, 4, 26, 8), End: when(2013, 4, 26, 9)})
+  c := fakeCmdClock{when(2013, 4, 26, 12)}
+  db := &HookDatabase{Database: fake, Clock: c,
+    Hooks: []Hook{{HookAll, `echo "$HOURGLASS_EVENT $HOURGLASS_ID" >> ` + log}}}
+
+  /* restarting through the server, which records what is saved */
+  s := &Server{Clock: c, Database: db}
+  w := serverTestRequest(s, "POST", "/activities/1/restart", "")
+  if w.Code != http.StatusCreated {
+    t.Fatalf("expected status 201, got %d: %s", w.Code, w.Body)
+  }
+
+  /* and from the tui */
+  tui := &Tui{Clock: c, Database: db}
+  tui.Refresh()
+  tui.HandleKey('r')
+
+  data, _ := ioutil.ReadFile(log)
+  if string(data) != "restart 2\nrestart 3\n" {
+    t.Errorf("expected restart hooks, got %q", data)
+  }
+}
+
+func TestHookDatabase_Timeout(t *testing.T) {
+  errors := new(bytes.Buffer)
+  c := fakeCmdClock{when(2013, 4, 26, 12)}
+  db := &HookDatabase{Database: &Memory{}, Clock: c, Errors: errors,
+    Timeout: 50 * time.Millisecond, Hooks: []Hook{{HookStart, "sleep 5"}}}
+
+  started := time.Now()
+  err := db.SaveActivity(&Activity{Name: "foo", Start: c.Now()})
+  if err != nil {
+    t.Fatal(err)
+  }
+  if time.Since(started) > 3 * time.Second {
+    t.Error("hook wasn't stopped after the timeout")
+  }
+  if !strings.Contains(errors.String(), "timed out after 50ms") {
+    t.Errorf("expected timeout to be reported, got %q", errors.String())
+  }
+}
@@ -6,6 +6,9 @@ import (
   "os"
   "os/user"
   "path"
+  "path/filepath"
+  "strings"
+  "time"
   "database/sql"
   "text/tabwriter"
   sqlite "github.com/mattn/go-sqlite3"
@@ -16,126 +19,322 @@ const Usage = `hourglass is a tool for time tracking.
 
 Usage:
 
-	%s [global-opts] command [arguments]
+	%[1]s [global-opts] command [arguments]
 
 Global options:
 
 	-sql	Use SQLite backend (default)
 	-csv	Use CSV backend
+	-jsonl	Use JSON lines backend, an event log that can be synced (see compact)
+	-db	Database file, which can be shared by several users (default ~/.hourglass.db, ~/.hourglass.csv or ~/.hourglass.jsonl)
+	-max-running	Maximum time an activity should run (default 10h)
+	-end-of-day	Time of day that stop --at eod and fix-running eod use (default 18h)
+	-strict	Only allow projects added with the project command
+	-hooks	File of commands to run when activities change (default ~/.hourglass-hooks)
+	-hook-timeout	Maximum time each hook can run (default 10s)
 
-Commands:
+%[2]s
 
-	list	List activities
-	start	Start an activity
-	stop	Stop an activity
-	edit	Edit an activity
-	delete	Delete an activity
-	restart	Restart an activity
-
-Use "%s help [command]" for more information about a command.
+Use "%[1]s help [command]" for more information about a command.
 `
 
-func printUsage() {
-  fmt.Fprintf(os.Stderr, Usage, os.Args[0], os.Args[0])
+func newRegistry(dbFile, username string, templates *hourglass.Templates, maxRunning, endOfDay time.Duration,
+  strict bool, defaults *hourglass.DirConfig, globalArgs []string) *hourglass.Registry {
+  r := &hourglass.Registry{}
+  /* the git hook runs this program by its full path */
+  program, programErr := os.Executable()
+  if programErr != nil {
+    program = os.Args[0]
+  }
+  r.Register(&hourglass.CommandInfo{
+    Name: "list", Aliases: []string{"ls"}, Summary: "List activities",
+    Command: &hourglass.ListCommand{MaxRunning: maxRunning,
+      UserOptions: hourglass.UserOptions{User: username}},
+  })
+  r.Register(&hourglass.CommandInfo{
+    Name: "start", Summary: "Start an activity",
+    Command: &hourglass.StartCommand{Templates: templates, Strict: strict, Defaults: defaults},
+  })
+  r.Register(&hourglass.CommandInfo{
+    Name: "pomodoro", Summary: "Work in timed sessions with breaks",
+    Command: &hourglass.PomodoroCommand{Templates: templates, Strict: strict, Defaults: defaults},
+  })
+  r.Register(&hourglass.CommandInfo{
+    Name: "stop", Summary: "Stop an activity",
+    Command: &hourglass.StopCommand{MaxRunning: maxRunning, EndOfDay: endOfDay},
+  })
+  r.Register(&hourglass.CommandInfo{
+    Name: "edit", Summary: "Edit an activity",
+    Command: &hourglass.EditCommand{Strict: strict},
+  })
+  r.Register(&hourglass.CommandInfo{
+    Name: "delete", Aliases: []string{"rm"}, Summary: "Delete an activity",
+    Command: &hourglass.DeleteCommand{},
+  })
+  r.Register(&hourglass.CommandInfo{
+    Name: "restart", Summary: "Restart an activity",
+    Command: &hourglass.RestartCommand{},
+  })
+  r.Register(&hourglass.CommandInfo{
+    Name: "status", Summary: "Show running activities",
+    Command: &hourglass.StatusCommand{},
+  })
+  r.Register(&hourglass.CommandInfo{
+    Name: "pause", Summary: "Take a break from running activities",
+    Command: &hourglass.PauseCommand{},
+  })
+  r.Register(&hourglass.CommandInfo{
+    Name: "resume", Summary: "Resume paused activities",
+    Command: &hourglass.ResumeCommand{},
+  })
+  r.Register(&hourglass.CommandInfo{
+    Name: "fix-running", Summary: "Stop forgotten activities",
+    Command: &hourglass.FixRunningCommand{MaxRunning: maxRunning, EndOfDay: endOfDay},
+  })
+  r.Register(&hourglass.CommandInfo{
+    Name: "template", Summary: "Manage activity templates",
+    Command: &hourglass.TemplateCommand{Templates: templates},
+  })
+  r.Register(&hourglass.CommandInfo{
+    Name: "project", Summary: "Manage projects",
+    Command: &hourglass.ProjectCommand{},
+  })
+  r.Register(&hourglass.CommandInfo{
+    Name: "tags", Summary: "Manage tags",
+    Command: &hourglass.TagsCommand{},
+  })
+  r.Register(&hourglass.CommandInfo{
+    Name: "goal", Summary: "Manage time goals and budgets",
+    Command: &hourglass.GoalCommand{},
+  })
+  r.Register(&hourglass.CommandInfo{
+    Name: "goals", Summary: "Show progress towards goals",
+    Command: &hourglass.GoalsCommand{},
+  })
+  r.Register(&hourglass.CommandInfo{
+    Name: "rate", Summary: "Manage billing rates",
+    Command: &hourglass.RateCommand{},
+  })
+  r.Register(&hourglass.CommandInfo{
+    Name: "timesheet", Summary: "Summarize time for billing",
+    Command: &hourglass.TimesheetCommand{UserOptions: hourglass.UserOptions{User: username}},
+  })
+  r.Register(&hourglass.CommandInfo{
+    Name: "export", Summary: "Export activities to a calendar",
+    Command: &hourglass.ExportCommand{},
+  })
+  r.Register(&hourglass.CommandInfo{
+    Name: "import", Summary: "Import activities from a calendar",
+    Command: &hourglass.ImportCommand{Uids: sidecarFile(dbFile, "imported")},
+  })
+  r.Register(&hourglass.CommandInfo{
+    Name: "compact", Summary: "Shrink the -jsonl event log",
+    Command: &hourglass.CompactCommand{},
+  })
+  r.Register(&hourglass.CommandInfo{
+    Name: "remind", Summary: "Send reminders once",
+    Command: &hourglass.RemindCommand{ReminderOptions: hourglass.ReminderOptions{MaxRunning: maxRunning}},
+  })
+  r.Register(&hourglass.CommandInfo{
+    Name: "watch", Summary: "Keep sending reminders until interrupted",
+    Command: &hourglass.WatchCommand{ReminderOptions: hourglass.ReminderOptions{MaxRunning: maxRunning}},
+  })
+  r.Register(&hourglass.CommandInfo{
+    Name: "git-hook", Summary: "Record git commits against running activities",
+    NoDatabase: true,
+    Command: &hourglass.GitHookCommand{Program: program, GlobalArgs: globalArgs},
+  })
+  r.Register(&hourglass.CommandInfo{
+    Name: "git-commit", Hidden: true,
+    Command: &hourglass.GitCommitCommand{},
+  })
+  r.Register(&hourglass.CommandInfo{
+    Name: "tui", Summary: "Interactive terminal view",
+    Command: &hourglass.TuiCommand{Templates: templates, MaxRunning: maxRunning, Strict: strict},
+  })
+  r.Register(&hourglass.CommandInfo{
+    Name: "serve", Summary: "Serve a web dashboard and JSON API",
+    Command: &hourglass.ServeCommand{Templates: templates, Strict: strict,
+      MaxRunning: maxRunning, EndOfDay: endOfDay},
+  })
+  r.Register(&hourglass.CommandInfo{
+    Name: "completion", Summary: "Print a shell completion script",
+    NoDatabase: true,
+    Command: &hourglass.CompletionCommand{Program: path.Base(os.Args[0])},
+  })
+  r.Register(&hourglass.CommandInfo{
+    Name: "__complete", Hidden: true,
+    Command: &hourglass.CompleteCommand{Registry: r, Templates: templates,
+      Globals: flag.CommandLine},
+  })
+  r.Register(&hourglass.CommandInfo{
+    Name: "help", Summary: "Show help for a command",
+    NoDatabase: true,
+    Command: &hourglass.HelpCommand{Registry: r, Program: os.Args[0], Usage: Usage},
+  })
+  return r
+}
+
+/* the global options that were given, with absolute file names, for the git hook */
+func globalArgs() (args []string) {
+  flag.Visit(func(f *flag.Flag) {
+    value := f.Value.String()
+    if (f.Name == "db" || f.Name == "hooks") && value != "" {
+      if abs, absErr := filepath.Abs(value); absErr == nil {
+        value = abs
+      }
+    }
+    args = append(args, fmt.Sprintf("-%s=%s", f.Name, value))
+  })
+  return
+}
+
+/* a csv file next to the database, like ~/.hourglass-templates.csv for ~/.hourglass.db */
+func sidecarFile(dbFile, name string) string {
+  return strings.TrimSuffix(dbFile, filepath.Ext(dbFile)) + "-" + name + ".csv"
+}
+
+func printUsage(r *hourglass.Registry) {
+  fmt.Fprintf(os.Stderr, Usage, os.Args[0], r.Usage())
 }
 
 func main() {
   sqlFlag := flag.Bool("sql", false, "Use SQLite backend")
   csvFlag := flag.Bool("csv", false, "Use CSV backend")
+  jsonlFlag := flag.Bool("jsonl", false, "Use JSON lines backend")
+  maxRunningFlag := flag.Duration("max-running", hourglass.DefaultMaxRunning,
+    "Maximum time an activity should run")
+  endOfDayFlag := flag.Duration("end-of-day", hourglass.DefaultEndOfDay,
+    "Time of day that stop --at eod and fix-running eod use")
+  strictFlag := flag.Bool("strict", false, "Only allow projects added with the project command")
+  hooksFlag := flag.String("hooks", "", "File of commands to run when activities change")
+  dbFlag := flag.String("db", "", "Database file, which can be shared by several users")
+  hookTimeoutFlag := flag.Duration("hook-timeout", hourglass.DefaultHookTimeout,
+    "Maximum time each hook can run")
   flag.Parse()
 
-  if len(flag.Args()) < 1 {
-    printUsage()
+  currentUser, userErr := user.Current()
+  if userErr != nil {
+    fmt.Fprintln(os.Stderr, userErr)
     os.Exit(1)
   }
+  dbFile := *dbFlag
+  if dbFile == "" {
+    switch {
+    case *jsonlFlag:
+      dbFile = path.Join(currentUser.HomeDir, ".hourglass.jsonl")
+    case *csvFlag:
+      dbFile = path.Join(currentUser.HomeDir, ".hourglass.csv")
+    default:
+      dbFile = path.Join(currentUser.HomeDir, ".hourglass.db")
+    }
+  }
+  templates := &hourglass.Templates{Filename: sidecarFile(dbFile, "templates")}
+  /* defaults for the current directory */
+  var dirConfig *hourglass.DirConfig
+  if cwd, cwdErr := os.Getwd(); cwdErr == nil {
+    var dirConfigErr error
+    dirConfig, dirConfigErr = hourglass.FindDirConfig(cwd)
+    if dirConfigErr != nil {
+      fmt.Fprintln(os.Stderr, dirConfigErr)
+      os.Exit(1)
+    }
+  }
+  registry := newRegistry(dbFile, currentUser.Username, templates, *maxRunningFlag,
+    *endOfDayFlag, *strictFlag, dirConfig, globalArgs())
 
-  if *sqlFlag && *csvFlag {
-    fmt.Fprint(os.Stderr, "Error: -sql and -csv are mutually exclusive options\n")
-    printUsage()
+  if len(flag.Args()) < 1 {
+    printUsage(registry)
     os.Exit(1)
   }
 
-  help := false
-  commandName := flag.Arg(0)
-  if commandName == "help" {
-    if len(flag.Args()) != 2 {
-      fmt.Fprint(os.Stderr, "The help command requires one argument.\n")
-      os.Exit(1)
+  backends := 0
+  for _, backendFlag := range []bool{*sqlFlag, *csvFlag, *jsonlFlag} {
+    if backendFlag {
+      backends++
     }
-    help = true
-    commandName = flag.Arg(1)
+  }
+  if backends > 1 {
+    fmt.Fprint(os.Stderr, "Error: -sql, -csv and -jsonl are mutually exclusive options\n")
+    printUsage(registry)
+    os.Exit(1)
   }
 
-  var cmd hourglass.Command
-  switch commandName {
-  case "list":
-    cmd = hourglass.ListCommand{}
-  case "start":
-    cmd = hourglass.StartCommand{}
-  case "stop":
-    cmd = hourglass.StopCommand{}
-  case "edit":
-    cmd = hourglass.EditCommand{}
-  case "restart":
-    cmd = hourglass.RestartCommand{}
-  case "delete":
-    cmd = hourglass.DeleteCommand{}
-  default:
+  commandName := flag.Arg(0)
+  info := registry.Lookup(commandName)
+  if info == nil {
     fmt.Fprintln(os.Stderr, "Invalid command:", commandName)
-    printUsage()
+    printUsage(registry)
     os.Exit(1)
   }
 
-  if help {
-    fmt.Fprintf(os.Stderr, cmd.Help(), os.Args[0])
-    fmt.Fprintln(os.Stderr)
-    os.Exit(0)
+  /* Setup database */
+  var db hourglass.Database
+  if info.NoDatabase {
+    /* command doesn't need one */
+  } else if *jsonlFlag {
+    db = &hourglass.Jsonl{Filename: dbFile}
+  } else if !*csvFlag {
+    sql.Register("sqlite", &sqlite.SQLiteDriver{})
+    db = &hourglass.Sql{"sqlite", dbFile, nil, nil}
   } else {
-    /* Setup database */
-    currentUser, userErr := user.Current()
-    if userErr != nil {
-      fmt.Fprintln(os.Stderr, userErr)
+    var csvErr error
+    db, csvErr = hourglass.NewCsv(dbFile)
+    if csvErr != nil {
+      fmt.Fprintln(os.Stderr, csvErr)
       os.Exit(1)
     }
+  }
 
-    var db hourglass.Database
-    if !*csvFlag {
-      sql.Register("sqlite", &sqlite.SQLiteDriver{})
-      dbFile := path.Join(currentUser.HomeDir, ".hourglass.db")
-      db = &hourglass.Sql{"sqlite", dbFile, nil}
-    } else {
-      csvFile := path.Join(currentUser.HomeDir, ".hourglass.csv")
-
-      var csvErr error
-      db, csvErr = hourglass.NewCsv(csvFile)
-      if csvErr != nil {
-        fmt.Fprintln(os.Stderr, csvErr)
-        os.Exit(1)
-      }
-    }
-
+  if db != nil {
     migrateErr := db.Migrate()
     if migrateErr != nil {
       fmt.Fprintln(os.Stderr, migrateErr)
       os.Exit(1)
     }
+  }
 
-    c := hourglass.DefaultClock{}
-    output, err := cmd.Run(c, db, flag.Args()[1:]...)
-    switch err.(type) {
-    case nil:
-      writer := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
-      fmt.Fprintln(writer, output)
-      writer.Flush()
-      os.Exit(0)
-    case hourglass.SyntaxError:
-      fmt.Fprintln(os.Stderr, err)
-      fmt.Fprintf(os.Stderr, cmd.Help(), os.Args[0])
-      fmt.Fprintln(os.Stderr)
-      os.Exit(1)
-    default:
-      fmt.Fprintln(os.Stderr, err)
+  c := hourglass.DefaultClock{}
+  if db != nil {
+    /* activities are recorded as the current user's */
+    db = &hourglass.UserDatabase{Database: db, User: currentUser.Username}
+
+    hooksFile := *hooksFlag
+    if hooksFile == "" {
+      hooksFile = path.Join(currentUser.HomeDir, ".hourglass-hooks")
+    }
+    hooks, hooksErr := hourglass.LoadHooks(hooksFile)
+    if hooksErr != nil {
+      fmt.Fprintln(os.Stderr, hooksErr)
       os.Exit(1)
     }
+    if len(hooks) > 0 {
+      db = &hourglass.HookDatabase{Database: db, Clock: c, Hooks: hooks,
+        Timeout: *hookTimeoutFlag, Errors: os.Stderr}
+    }
+  }
+
+  output, err := info.Run(c, db, flag.Args()[1:]...)
+  if err == hourglass.ErrNothingRunning {
+    /* not really an error, but scripts need to know */
+    if output != "" {
+      fmt.Println(output)
+    }
+    os.Exit(1)
+  }
+  switch err.(type) {
+  case nil:
+    writer := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+    fmt.Fprintln(writer, output)
+    writer.Flush()
+    os.Exit(0)
+  case hourglass.SyntaxError:
+    fmt.Fprintln(os.Stderr, err)
+    fmt.Fprintf(os.Stderr, info.Help(), os.Args[0])
+    fmt.Fprintln(os.Stderr)
+    os.Exit(1)
+  default:
+    fmt.Fprintln(os.Stderr, err)
+    os.Exit(1)
   }
 }
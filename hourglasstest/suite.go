@@ -0,0 +1,453 @@
+/*
+ * Package hourglasstest checks that a Database behaves like the backends
+ * that come with hourglass. A backend's tests only need to say how to make
+ * a new database:
+ *
+ *   func TestMyDb_Suite(t *testing.T) {
+ *     hourglasstest.RunDatabaseSuite(t, func(t *testing.T) hourglass.Database {
+ *       return newMyDb(t)
+ *     })
+ *   }
+ */
+package hourglasstest
+
+import (
+  "fmt"
+  "testing"
+  "time"
+  "hourglass"
+)
+
+/*
+ * Makes a new, empty database that is ready to use, for each test in the
+ * suite. Anything to clean up afterwards can be registered with t.Cleanup.
+ */
+type Factory func(t *testing.T) hourglass.Database
+
+var suite = []struct {
+  name string
+  f func(t *testing.T, db hourglass.Database)
+}{
+  {"Ids", testIds},
+  {"FindActivity", testFindActivity},
+  {"FindActivity_NotFound", testFindActivityNotFound},
+  {"UpdateActivity", testUpdateActivity},
+  {"SaveActivity_NotFound", testSaveMissingActivity},
+  {"Tags", testTags},
+  {"TimeZones", testTimeZones},
+  {"FindAllActivities", testFindAllActivities},
+  {"FindRunningActivities", testFindRunningActivities},
+  {"FindActivitiesBetween", testFindActivitiesBetween},
+  {"DeleteActivity", testDeleteActivity},
+  {"Rates", testRates},
+  {"Projects", testProjects},
+  {"RenameProject", testRenameProject},
+  {"Goals", testGoals},
+}
+
+/* run every test against a new database from the factory */
+func RunDatabaseSuite(t *testing.T, factory Factory) {
+  for _, test := range suite {
+    test := test
+    t.Run(test.name, func(t *testing.T) {
+      test.f(t, factory(t))
+    })
+  }
+}
+
+func when(year, month, day, hour int) time.Time {
+  return time.Date(year, time.Month(month), day, hour, 0, 0, 0, time.Local)
+}
+
+func save(t *testing.T, db hourglass.Database, activities ...*hourglass.Activity) {
+  for _, activity := range activities {
+    err := db.SaveActivity(activity)
+    if err != nil {
+      t.Fatal(err)
+    }
+  }
+}
+
+/* saved activities come back the same */
+func checkFound(t *testing.T, db hourglass.Database, expected *hourglass.Activity) {
+  found, err := db.FindActivity(expected.Id)
+  if err != nil {
+    t.Errorf("activity %d: %s", expected.Id, err)
+  } else if !expected.Equal(found) {
+    t.Errorf("expected %v, got %v", expected, found)
+  }
+}
+
+func checkActivities(t *testing.T, what string, found []*hourglass.Activity, err error,
+  expected ...*hourglass.Activity) {
+
+  if err != nil {
+    t.Errorf("%s: %s", what, err)
+    return
+  }
+  if len(found) != len(expected) {
+    t.Errorf("%s: expected %d activities, got %d: %v", what, len(expected), len(found), found)
+    return
+  }
+  for i, activity := range expected {
+    if !activity.Equal(found[i]) {
+      t.Errorf("%s: expected %v, got %v", what, activity, found[i])
+    }
+  }
+}
+
+func testIds(t *testing.T, db hourglass.Database) {
+  for i := int64(1); i <= 3; i++ {
+    activity := &hourglass.Activity{Name: fmt.Sprint("foo ", i), Start: when(2013, 4, 26, 9)}
+    save(t, db, activity)
+    if activity.Id != i {
+      t.Errorf("expected id %d, got %d", i, activity.Id)
+    }
+  }
+}
+
+func testFindActivity(t *testing.T, db hourglass.Database) {
+  activity := &hourglass.Activity{Name: "foo", Project: "bar", Tags: []string{"baz"},
+    Start: when(2013, 4, 26, 9), End: when(2013, 4, 26, 12),
+    Pauses: []hourglass.Pause{{Start: when(2013, 4, 26, 10), End: when(2013, 4, 26, 11)}},
+    Notes: "commit abc1234\ncommit def5678", User: "alice"}
+  save(t, db, activity)
+  checkFound(t, db, activity)
+
+  /* changing what was found doesn't change what was saved */
+  found, _ := db.FindActivity(activity.Id)
+  if found != nil {
+    found.Name = "changed"
+    checkFound(t, db, activity)
+  }
+}
+
+func testFindActivityNotFound(t *testing.T, db hourglass.Database) {
+  _, err := db.FindActivity(1)
+  if err != hourglass.ErrNotFound {
+    t.Errorf("expected ErrNotFound with no activities, got %v", err)
+  }
+
+  activity := &hourglass.Activity{Name: "foo", Start: when(2013, 4, 26, 9)}
+  save(t, db, activity)
+  _, err = db.FindActivity(activity.Id + 1)
+  if err != hourglass.ErrNotFound {
+    t.Errorf("expected ErrNotFound, got %v", err)
+  }
+}
+
+func testUpdateActivity(t *testing.T, db hourglass.Database) {
+  activity := &hourglass.Activity{Name: "foo", Tags: []string{"baz"}, Start: when(2013, 4, 26, 9),
+    Pauses: []hourglass.Pause{{Start: when(2013, 4, 26, 10), End: when(2013, 4, 26, 11)}}}
+  other := &hourglass.Activity{Name: "bar", Start: when(2013, 4, 26, 10)}
+  save(t, db, activity, other)
+
+  activity.Name = "qux"
+  activity.Tags = nil
+  activity.Pauses = nil
+  activity.End = when(2013, 4, 26, 12)
+  save(t, db, activity)
+  if activity.Id != 1 {
+    t.Errorf("expected id to stay 1, got %d", activity.Id)
+  }
+  checkFound(t, db, activity)
+  checkFound(t, db, other)
+}
+
+/* tags with separators, quotes and other awkward characters */
+func testTags(t *testing.T, db hourglass.Database) {
+  activities := []*hourglass.Activity{
+    &hourglass.Activity{Name: "foo", Tags: []string{"one", "two, three", `"four"`, "five;six", "ünïcode"},
+      Start: when(2013, 4, 26, 9)},
+    &hourglass.Activity{Name: "bar", Tags: []string{" spaced ", "[json]"}, Start: when(2013, 4, 26, 10)},
+    &hourglass.Activity{Name: "baz", Start: when(2013, 4, 26, 11)},
+  }
+  save(t, db, activities...)
+  for _, activity := range activities {
+    checkFound(t, db, activity)
+  }
+}
+
+/* times come back as the same instant, whatever zone they were in */
+func testTimeZones(t *testing.T, db hourglass.Database) {
+  tokyo := time.FixedZone("JST", 9 * 60 * 60)
+  honolulu := time.FixedZone("HST", -10 * 60 * 60)
+  activity := &hourglass.Activity{Name: "foo",
+    Start: time.Date(2013, 4, 26, 23, 30, 0, 0, tokyo),
+    End: time.Date(2013, 4, 26, 6, 15, 0, 0, honolulu),
+    Pauses: []hourglass.Pause{{Start: time.Date(2013, 4, 26, 15, 0, 0, 0, time.UTC),
+      End: time.Date(2013, 4, 26, 15, 30, 0, 0, time.UTC)}}}
+  save(t, db, activity)
+  checkFound(t, db, activity)
+
+  /* ranges compare instants too */
+  found, err := db.FindActivitiesBetween(time.Date(2013, 4, 26, 14, 30, 0, 0, time.UTC),
+    time.Date(2013, 4, 26, 14, 31, 0, 0, time.UTC))
+  checkActivities(t, "between", found, err, activity)
+
+  /* and in the local zone, like the times commands compare them with */
+  found, err = db.FindAllActivities()
+  for _, a := range found {
+    times := []time.Time{a.Start, a.End}
+    for _, pause := range a.Pauses {
+      times = append(times, pause.Start, pause.End)
+    }
+    for _, when := range times {
+      if when.Location() != time.Local {
+        t.Errorf("expected %s to be in the local zone", when)
+      }
+    }
+  }
+}
+
+func testFindAllActivities(t *testing.T, db hourglass.Database) {
+  found, err := db.FindAllActivities()
+  checkActivities(t, "empty", found, err)
+
+  /* by id, not by start */
+  activities := []*hourglass.Activity{
+    &hourglass.Activity{Name: "foo", Start: when(2013, 4, 26, 9), End: when(2013, 4, 26, 10)},
+    &hourglass.Activity{Name: "bar", Start: when(2013, 4, 25, 9), End: when(2013, 4, 25, 10)},
+    &hourglass.Activity{Name: "baz", Start: when(2013, 4, 26, 11)},
+  }
+  save(t, db, activities...)
+  found, err = db.FindAllActivities()
+  checkActivities(t, "all", found, err, activities...)
+}
+
+func testFindRunningActivities(t *testing.T, db hourglass.Database) {
+  activities := []*hourglass.Activity{
+    &hourglass.Activity{Name: "stopped", Start: when(2013, 4, 26, 9), End: when(2013, 4, 26, 10)},
+    &hourglass.Activity{Name: "running", Start: when(2013, 4, 26, 10)},
+    /* paused activities are still running */
+    &hourglass.Activity{Name: "paused", Start: when(2013, 4, 26, 11),
+      Pauses: []hourglass.Pause{{Start: when(2013, 4, 26, 12)}}},
+    &hourglass.Activity{Name: "stopped later", Start: when(2013, 4, 26, 12)},
+  }
+  save(t, db, activities...)
+
+  found, err := db.FindRunningActivities()
+  checkActivities(t, "running", found, err, activities[1], activities[2], activities[3])
+
+  activities[3].End = when(2013, 4, 26, 13)
+  save(t, db, activities[3])
+  found, err = db.FindRunningActivities()
+  checkActivities(t, "after stopping", found, err, activities[1], activities[2])
+}
+
+func testFindActivitiesBetween(t *testing.T, db hourglass.Database) {
+  activities := []*hourglass.Activity{
+    &hourglass.Activity{Name: "before", Start: when(2013, 4, 25, 23), End: when(2013, 4, 26, 1)},
+    &hourglass.Activity{Name: "lower", Start: when(2013, 4, 26, 0)},
+    &hourglass.Activity{Name: "inside", Start: when(2013, 4, 26, 9), End: when(2013, 4, 26, 10)},
+    &hourglass.Activity{Name: "nearly upper", Start: when(2013, 4, 27, 0).Add(-time.Second)},
+    &hourglass.Activity{Name: "upper", Start: when(2013, 4, 27, 0)},
+  }
+  save(t, db, activities...)
+
+  /* activities are in a range by their start, including the lower bound but not the upper */
+  found, err := db.FindActivitiesBetween(when(2013, 4, 26, 0), when(2013, 4, 27, 0))
+  checkActivities(t, "day", found, err, activities[1], activities[2], activities[3])
+
+  found, err = db.FindActivitiesBetween(when(2013, 4, 28, 0), when(2013, 4, 29, 0))
+  checkActivities(t, "empty", found, err)
+}
+
+func testDeleteActivity(t *testing.T, db hourglass.Database) {
+  activity := &hourglass.Activity{Name: "foo", Start: when(2013, 4, 26, 9),
+    Pauses: []hourglass.Pause{{Start: when(2013, 4, 26, 10), End: when(2013, 4, 26, 11)}}}
+  other := &hourglass.Activity{Name: "bar", Start: when(2013, 4, 26, 10)}
+  save(t, db, activity, other)
+
+  err := db.DeleteActivity(activity.Id)
+  if err != nil {
+    t.Fatal(err)
+  }
+  _, err = db.FindActivity(activity.Id)
+  if err != hourglass.ErrNotFound {
+    t.Errorf("expected ErrNotFound, got %v", err)
+  }
+  err = db.DeleteActivity(activity.Id)
+  if err != hourglass.ErrNotFound {
+    t.Errorf("expected ErrNotFound deleting twice, got %v", err)
+  }
+  checkFound(t, db, other)
+
+  found, err := db.FindAllActivities()
+  checkActivities(t, "all", found, err, other)
+}
+
+func testRates(t *testing.T, db hourglass.Database) {
+  rates := []*hourglass.Rate{
+    &hourglass.Rate{Project: "foo", Hourly: hourglass.Money{Amount: 5000, Currency: "USD"}, Billable: true},
+    &hourglass.Rate{Project: "bar", Tag: "baz", Hourly: hourglass.Money{Amount: 7500, Currency: "EUR"}},
+    &hourglass.Rate{Project: "foo", Hourly: hourglass.Money{Amount: 6000, Currency: "USD"}, Billable: true},
+  }
+  for _, rate := range rates {
+    err := db.SaveRate(rate)
+    if err != nil {
+      t.Fatal(err)
+    }
+  }
+
+  /* the second rate for foo replaces the first */
+  found, err := db.FindRates()
+  if err != nil {
+    t.Fatal(err)
+  }
+  expected := []*hourglass.Rate{rates[1], rates[2]}
+  if len(found) != len(expected) {
+    t.Fatalf("expected %d rates, got %d", len(expected), len(found))
+  }
+  for i, rate := range expected {
+    if *found[i] != *rate {
+      t.Errorf("expected %v, got %v", rate, found[i])
+    }
+  }
+
+  err = db.DeleteRate("bar", "baz")
+  if err != nil {
+    t.Fatal(err)
+  }
+  err = db.DeleteRate("bar", "baz")
+  if err != hourglass.ErrNotFound {
+    t.Errorf("expected ErrNotFound, got %v", err)
+  }
+  found, _ = db.FindRates()
+  if len(found) != 1 || *found[0] != *rates[2] {
+    t.Errorf("expected only %v, got %v", rates[2], found)
+  }
+}
+
+func testProjects(t *testing.T, db hourglass.Database) {
+  projects := []*hourglass.Project{
+    &hourglass.Project{Name: "foo", Description: "Foo Inc."},
+    &hourglass.Project{Name: "bar"},
+    &hourglass.Project{Name: "foo", Description: "Foo Inc.", Archived: true},
+  }
+  for _, project := range projects {
+    err := db.SaveProject(project)
+    if err != nil {
+      t.Fatal(err)
+    }
+  }
+
+  /* by name, and the second foo replaces the first */
+  found, err := db.FindProjects()
+  if err != nil {
+    t.Fatal(err)
+  }
+  expected := []*hourglass.Project{projects[1], projects[2]}
+  if len(found) != len(expected) {
+    t.Fatalf("expected %d projects, got %d", len(expected), len(found))
+  }
+  for i, project := range expected {
+    if *found[i] != *project {
+      t.Errorf("expected %v, got %v", project, found[i])
+    }
+  }
+}
+
+func testSaveMissingActivity(t *testing.T, db hourglass.Database) {
+  save(t, db, &hourglass.Activity{Name: "foo", Start: when(2013, 4, 26, 9)})
+
+  missing := &hourglass.Activity{Id: 123, Name: "bar", Start: when(2013, 4, 26, 10),
+    Pauses: []hourglass.Pause{hourglass.Pause{Start: when(2013, 4, 26, 11)}}}
+  err := db.SaveActivity(missing)
+  if err != hourglass.ErrNotFound {
+    t.Errorf("expected ErrNotFound, got %v", err)
+  }
+  _, err = db.FindActivity(123)
+  if err != hourglass.ErrNotFound {
+    t.Errorf("expected activity not to be saved, got %v", err)
+  }
+  activities, _ := db.FindAllActivities()
+  if len(activities) != 1 {
+    t.Errorf("expected one activity, got %v", activities)
+  }
+}
+
+func testRenameProject(t *testing.T, db hourglass.Database) {
+  activity := &hourglass.Activity{Name: "foo", Project: "old", Start: when(2013, 4, 26, 9)}
+  other := &hourglass.Activity{Name: "bar", Project: "other", Start: when(2013, 4, 26, 10)}
+  save(t, db, activity, other)
+  db.SaveProject(&hourglass.Project{Name: "old", Description: "Old"})
+  db.SaveRate(&hourglass.Rate{Project: "old", Hourly: hourglass.Money{Amount: 5000, Currency: "USD"}})
+  db.SaveGoal(&hourglass.Goal{Project: "old", Target: 10, Period: hourglass.PeriodWeek})
+
+  err := db.RenameProject("old", "new")
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  activity.Project = "new"
+  checkFound(t, db, activity)
+  checkFound(t, db, other)
+  projects, _ := db.FindProjects()
+  if len(projects) != 1 || *projects[0] != (hourglass.Project{Name: "new", Description: "Old"}) {
+    t.Errorf("expected project to be renamed, got %v", projects)
+  }
+  rates, _ := db.FindRates()
+  if len(rates) != 1 || rates[0].Project != "new" {
+    t.Errorf("expected rate to be renamed, got %v", rates)
+  }
+  goals, _ := db.FindGoals()
+  if len(goals) != 1 || goals[0].Project != "new" {
+    t.Errorf("expected goal to be renamed, got %v", goals)
+  }
+
+  err = db.RenameProject("missing", "other")
+  if err != hourglass.ErrNotFound {
+    t.Errorf("expected ErrNotFound for a missing project, got %v", err)
+  }
+
+  /* renaming onto a project that exists changes nothing */
+  db.SaveProject(&hourglass.Project{Name: "other"})
+  err = db.RenameProject("new", "other")
+  if err != hourglass.ErrProjectExists {
+    t.Errorf("expected ErrProjectExists, got %v", err)
+  }
+  checkFound(t, db, activity)
+  projects, _ = db.FindProjects()
+  if len(projects) != 2 {
+    t.Errorf("expected two projects, got %v", projects)
+  }
+}
+
+func testGoals(t *testing.T, db hourglass.Database) {
+  goals := []*hourglass.Goal{
+    &hourglass.Goal{Project: "foo", Target: 20, Period: hourglass.PeriodWeek},
+    &hourglass.Goal{Tag: "meetings", Target: 1, Period: hourglass.PeriodDay, Cap: true},
+    &hourglass.Goal{Project: "foo", Target: 30, Period: hourglass.PeriodMonth},
+  }
+  for _, goal := range goals {
+    err := db.SaveGoal(goal)
+    if err != nil {
+      t.Fatal(err)
+    }
+  }
+
+  /* by project and tag, and the second goal for foo replaces the first */
+  found, err := db.FindGoals()
+  if err != nil {
+    t.Fatal(err)
+  }
+  expected := []*hourglass.Goal{goals[1], goals[2]}
+  if len(found) != len(expected) {
+    t.Fatalf("expected %d goals, got %d", len(expected), len(found))
+  }
+  for i, goal := range expected {
+    if *found[i] != *goal {
+      t.Errorf("expected %v, got %v", goal, found[i])
+    }
+  }
+
+  err = db.DeleteGoal("", "meetings")
+  if err != nil {
+    t.Fatal(err)
+  }
+  err = db.DeleteGoal("", "meetings")
+  if err != hourglass.ErrNotFound {
+    t.Errorf("expected ErrNotFound, got %v", err)
+  }
+}
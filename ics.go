@@ -0,0 +1,405 @@
+package hourglass
+
+import (
+  "bufio"
+  "bytes"
+  "flag"
+  "fmt"
+  "io"
+  "os"
+  "regexp"
+  "strconv"
+  "strings"
+  "time"
+)
+
+/* help messages */
+const (
+  exportHelp = "Usage: %s export [--format ics] [today|week|all|<from> <to>]\n\nExport activities\n\nEach activity becomes an event with the project and tags as categories. Dates are formatted like 2006-01-02."
+  importHelp = "Usage: %s import ics <file> [today|week|all|<from> <to>]\n\nCreate stopped activities from calendar events\n\nEvents that were already imported or that were exported by hourglass are skipped. Events are recognized by their UID, or by their start and summary when they don't have one. What was imported is remembered in a file next to the database, like ~/.hourglass-imported.csv for ~/.hourglass.db. Dates are formatted like 2006-01-02."
+)
+
+/* iCalendar date formats */
+const (
+  IcsDateTimeFormat = "20060102T150405"
+  IcsUTCFormat = "20060102T150405Z"
+  IcsDateFormat = "20060102"
+)
+
+/* lines longer than this are folded */
+const icsLineLength = 75
+
+/* uids of exported activities end with this */
+const IcsUidSuffix = "@hourglass"
+
+/* calendar event */
+type IcsEvent struct {
+  Uid string
+  Summary string
+  Project string
+  Categories []string
+  Start time.Time
+  End time.Time
+  Cancelled bool
+}
+
+func icsEscape(text string) string {
+  return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`).Replace(text)
+}
+
+func icsUnescape(text string) string {
+  return strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n").Replace(text)
+}
+
+/* split a list value on commas that aren't escaped */
+func icsSplitList(value string) (list []string) {
+  var item string
+  for i := 0; i < len(value); i++ {
+    switch {
+    case value[i] == '\\' && i + 1 < len(value):
+      item += value[i:i + 2]
+      i++
+    case value[i] == ',':
+      list = append(list, icsUnescape(item))
+      item = ""
+    default:
+      item += value[i:i + 1]
+    }
+  }
+  return append(list, icsUnescape(item))
+}
+
+/* write a content line, folding it at the line length */
+func icsWriteLine(buf *bytes.Buffer, line string) {
+  for len(line) > icsLineLength {
+    /* don't split utf-8 sequences */
+    n := icsLineLength
+    for n > 0 && line[n] & 0xC0 == 0x80 {
+      n--
+    }
+    buf.WriteString(line[:n] + "\r\n")
+    line = " " + line[n:]
+  }
+  buf.WriteString(line + "\r\n")
+}
+
+/* calendar with one event per activity */
+func FormatIcs(c Clock, activities []*Activity) string {
+  buf := new(bytes.Buffer)
+  now := c.Now().UTC().Format(IcsUTCFormat)
+
+  icsWriteLine(buf, "BEGIN:VCALENDAR")
+  icsWriteLine(buf, "VERSION:2.0")
+  icsWriteLine(buf, "PRODID:-//hourglass//hourglass//EN")
+  for _, activity := range activities {
+    end := activity.End
+    if end.IsZero() {
+      end = c.Now()
+    }
+
+    icsWriteLine(buf, "BEGIN:VEVENT")
+    icsWriteLine(buf, fmt.Sprintf("UID:%d%s", activity.Id, IcsUidSuffix))
+    icsWriteLine(buf, "DTSTAMP:" + now)
+    icsWriteLine(buf, "DTSTART:" + activity.Start.UTC().Format(IcsUTCFormat))
+    icsWriteLine(buf, "DTEND:" + end.UTC().Format(IcsUTCFormat))
+    icsWriteLine(buf, "SUMMARY:" + icsEscape(activity.Name))
+
+    var categories []string
+    if activity.Project != "" {
+      categories = append(categories, icsEscape(activity.Project))
+      icsWriteLine(buf, "X-HOURGLASS-PROJECT:" + icsEscape(activity.Project))
+    }
+    for _, tag := range activity.Tags {
+      categories = append(categories, icsEscape(tag))
+    }
+    if len(categories) > 0 {
+      icsWriteLine(buf, "CATEGORIES:" + strings.Join(categories, ","))
+    }
+    icsWriteLine(buf, "END:VEVENT")
+  }
+  icsWriteLine(buf, "END:VCALENDAR")
+  return buf.String()
+}
+
+/* only simple durations like PT1H30M are supported */
+var icsDurationRegexp = regexp.MustCompile(`^P(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+func parseIcsDuration(value string) (d time.Duration, err error) {
+  match := icsDurationRegexp.FindStringSubmatch(value)
+  if match == nil || value == "P" {
+    err = fmt.Errorf("invalid duration: %s", value)
+    return
+  }
+  units := []time.Duration{24 * time.Hour, time.Hour, time.Minute, time.Second}
+  for i, unit := range units {
+    if match[i + 1] != "" {
+      n, _ := strconv.Atoi(match[i + 1])
+      d += time.Duration(n) * unit
+    }
+  }
+  return
+}
+
+/* dates are either utc, in a named zone or floating (local) */
+func parseIcsDate(value string, params map[string]string) (t time.Time, allDay bool, err error) {
+  if params["VALUE"] == "DATE" || len(value) == len(IcsDateFormat) {
+    allDay = true
+    t, err = time.ParseInLocation(IcsDateFormat, value, time.Local)
+    return
+  }
+  if strings.HasSuffix(value, "Z") {
+    t, err = time.Parse(IcsUTCFormat, value)
+    return
+  }
+
+  location := time.Local
+  if tzid, ok := params["TZID"]; ok {
+    if zone, zoneErr := time.LoadLocation(strings.Trim(tzid, `"`)); zoneErr == nil {
+      location = zone
+    }
+  }
+  t, err = time.ParseInLocation(IcsDateTimeFormat, value, location)
+  return
+}
+
+/* events with a start and end time; all-day events are ignored */
+func ParseIcs(r io.Reader) (events []*IcsEvent, err error) {
+  /* unfold continuation lines */
+  var lines []string
+  scanner := bufio.NewScanner(r)
+  for scanner.Scan() {
+    line := strings.TrimRight(scanner.Text(), "\r")
+    if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+      lines[len(lines) - 1] += line[1:]
+    } else if line != "" {
+      lines = append(lines, line)
+    }
+  }
+  err = scanner.Err()
+  if err != nil {
+    return
+  }
+
+  var event *IcsEvent
+  var allDay bool
+  var duration time.Duration
+  /* components nested in an event, like alarms, have properties of their own */
+  var depth int
+  for i, line := range lines {
+    colon := strings.Index(line, ":")
+    if colon < 0 {
+      err = fmt.Errorf("invalid calendar line %d: %s", i + 1, line)
+      return
+    }
+    value := line[colon + 1:]
+    params := make(map[string]string)
+    nameParts := strings.Split(line[:colon], ";")
+    for _, param := range nameParts[1:] {
+      if equals := strings.Index(param, "="); equals > 0 {
+        params[strings.ToUpper(param[:equals])] = param[equals + 1:]
+      }
+    }
+
+    name := strings.ToUpper(nameParts[0])
+    if name == "BEGIN" && value == "VEVENT" {
+      event = &IcsEvent{}
+      allDay, duration, depth = false, 0, 0
+      continue
+    }
+    if event == nil {
+      continue
+    }
+    if name == "BEGIN" {
+      depth++
+      continue
+    }
+    if depth > 0 {
+      if name == "END" {
+        depth--
+      }
+      continue
+    }
+
+    var dateAllDay bool
+    switch name {
+    case "END":
+      if value != "VEVENT" {
+        continue
+      }
+      if event.End.IsZero() && duration > 0 {
+        event.End = event.Start.Add(duration)
+      }
+      if !allDay && !event.Start.IsZero() && event.End.After(event.Start) {
+        events = append(events, event)
+      }
+      event = nil
+    case "UID":
+      event.Uid = value
+    case "SUMMARY":
+      event.Summary = icsUnescape(value)
+    case "X-HOURGLASS-PROJECT":
+      event.Project = icsUnescape(value)
+    case "CATEGORIES":
+      event.Categories = append(event.Categories, icsSplitList(value)...)
+    case "STATUS":
+      event.Cancelled = strings.ToUpper(value) == "CANCELLED"
+    case "DTSTART":
+      event.Start, dateAllDay, err = parseIcsDate(value, params)
+      allDay = allDay || dateAllDay
+    case "DTEND":
+      event.End, dateAllDay, err = parseIcsDate(value, params)
+      allDay = allDay || dateAllDay
+    case "DURATION":
+      duration, err = parseIcsDuration(value)
+    }
+    if err != nil {
+      err = fmt.Errorf("invalid calendar line %d: %s", i + 1, err)
+      return
+    }
+  }
+  return
+}
+
+/* events without a uid are told apart by their start and summary */
+func (e *IcsEvent) key() string {
+  if e.Uid != "" {
+    return e.Uid
+  }
+  return e.Start.UTC().Format(IcsUTCFormat) + " " + e.Summary
+}
+
+/* activity for an event; the project isn't repeated in the tags */
+func (e *IcsEvent) Activity() *Activity {
+  activity := &Activity{Name: e.Summary, Project: e.Project, Start: e.Start, End: e.End}
+  for _, category := range e.Categories {
+    if category != e.Project && category != "" {
+      activity.Tags = append(activity.Tags, category)
+    }
+  }
+  if activity.Name == "" {
+    activity.Name = "untitled event"
+  }
+  return activity
+}
+
+/* export */
+type ExportCommand struct {
+  Format string
+}
+
+func (cmd *ExportCommand) Flags(fs *flag.FlagSet) {
+  fs.StringVar(&cmd.Format, "format", "ics", "output format (ics)")
+}
+
+func (cmd ExportCommand) Run(c Clock, db Database, args ...string) (output string, err error) {
+  if cmd.Format != "" && cmd.Format != "ics" {
+    err = SyntaxError(fmt.Sprint("unsupported format: ", cmd.Format))
+    return
+  }
+
+  var lower, upper time.Time
+  lower, upper, err = parseRange(c.Now(), args)
+  if err != nil {
+    return
+  }
+  var activities []*Activity
+  activities, err = findActivitiesInRange(db, lower, upper)
+  if err != nil {
+    return
+  }
+  output = strings.TrimSuffix(FormatIcs(c, activities), "\r\n")
+  return
+}
+
+func (ExportCommand) Help() string {
+  return exportHelp
+}
+
+/* import */
+type ImportCommand struct {
+  /* file with the uids of imported events */
+  Uids string
+}
+
+func (cmd ImportCommand) Run(c Clock, db Database, args ...string) (output string, err error) {
+  if len(args) < 2 {
+    err = SyntaxError("missing format or file argument")
+    return
+  }
+  if args[0] != "ics" {
+    err = SyntaxError(fmt.Sprint("unsupported format: ", args[0]))
+    return
+  }
+
+  var lower, upper time.Time
+  lower, upper, err = parseRange(c.Now(), args[2:])
+  if err != nil {
+    return
+  }
+
+  var f *os.File
+  f, err = os.Open(args[1])
+  if err != nil {
+    return
+  }
+  var events []*IcsEvent
+  events, err = ParseIcs(f)
+  f.Close()
+  if err != nil {
+    return
+  }
+
+  uids := csvTable{Filename: cmd.Uids}
+  var records [][]string
+  records, err = uids.readAll()
+  if err != nil {
+    return
+  }
+  seen := make(map[string]bool)
+  for _, record := range records {
+    seen[record[0]] = true
+  }
+
+  var imported, skipped int
+  for _, event := range events {
+    if event.Cancelled {
+      continue
+    }
+    if !lower.IsZero() && (event.Start.Before(lower) || !event.Start.Before(upper)) {
+      continue
+    }
+    key := event.key()
+    if seen[key] || strings.HasSuffix(event.Uid, IcsUidSuffix) {
+      skipped++
+      continue
+    }
+
+    activity := event.Activity()
+    err = db.SaveActivity(activity)
+    if err != nil {
+      break
+    }
+    imported++
+    seen[key] = true
+    records = append(records, []string{key, strconv.FormatInt(activity.Id, 10)})
+  }
+
+  /* remember what was imported, even after a failure */
+  if imported > 0 {
+    writeErr := uids.writeAll(records)
+    if err == nil {
+      err = writeErr
+    }
+  }
+  if err == nil {
+    output = fmt.Sprintf("imported %d activities", imported)
+    if skipped > 0 {
+      output += fmt.Sprintf(" (skipped %d duplicates)", skipped)
+    }
+  }
+  return
+}
+
+func (ImportCommand) Help() string {
+  return importHelp
+}
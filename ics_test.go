@@ -0,0 +1,225 @@
+package hourglass
+
+import (
+  "testing"
+  "io/ioutil"
+  "os"
+  "path/filepath"
+  "strings"
+  "time"
+)
+
+func TestFormatIcs(t *testing.T) {
+  c := fakeCmdClock{time.Date(2013, 4, 26, 12, 0, 0, 0, time.UTC)}
+  activities := []*Activity{
+    &Activity{Id: 1, Name: "foo, bar; baz", Project: "qux", Tags: []string{"a,b", "c"},
+      Start: time.Date(2013, 4, 26, 9, 0, 0, 0, time.UTC),
+      End: time.Date(2013, 4, 26, 10, 30, 0, 0, time.UTC)},
+    &Activity{Id: 2, Name: strings.Repeat("x", 100),
+      Start: time.Date(2013, 4, 26, 11, 0, 0, 0, time.UTC)},
+  }
+
+  output := FormatIcs(c, activities)
+  for _, expected := range []string{
+    "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n",
+    "BEGIN:VEVENT\r\nUID:1@hourglass\r\nDTSTAMP:20130426T120000Z\r\n",
+    "DTSTART:20130426T090000Z\r\nDTEND:20130426T103000Z\r\n",
+    `SUMMARY:foo\, bar\; baz` + "\r\n",
+    "X-HOURGLASS-PROJECT:qux\r\n",
+    `CATEGORIES:qux,a\,b,c` + "\r\n",
+    /* running activities end now */
+    "DTSTART:20130426T110000Z\r\nDTEND:20130426T120000Z\r\n",
+    "SUMMARY:" + strings.Repeat("x", 67) + "\r\n " + strings.Repeat("x", 33) + "\r\n",
+    "END:VEVENT\r\nEND:VCALENDAR\r\n",
+  } {
+    if !strings.Contains(output, expected) {
+      t.Errorf("expected output to contain %q:\n%s", expected, output)
+    }
+  }
+
+  /* round trip */
+  events, err := ParseIcs(strings.NewReader(output))
+  if err != nil {
+    t.Error(err)
+    return
+  }
+  if len(events) != 2 {
+    t.Errorf("expected 2 events, got %d", len(events))
+    return
+  }
+  activity := events[0].Activity()
+  expected := &Activity{Name: "foo, bar; baz", Project: "qux", Tags: []string{"a,b", "c"},
+    Start: activities[0].Start, End: activities[0].End}
+  if !expected.Equal(activity) {
+    t.Errorf("expected %v, got %v", expected, activity)
+  }
+  if events[1].Summary != strings.Repeat("x", 100) {
+    t.Errorf("expected folded summary to be unfolded, got %q", events[1].Summary)
+  }
+}
+
+const icsTestCalendar = "BEGIN:VCALENDAR\r\n" +
+  "BEGIN:VTIMEZONE\r\nTZID:America/New_York\r\nEND:VTIMEZONE\r\n" +
+  "BEGIN:VEVENT\r\nUID:standup-1\r\nSUMMARY:Standup\r\nCATEGORIES:meetings\r\n" +
+  "DTSTART;TZID=America/New_York:20130426T090000\r\nDURATION:PT15M\r\n" +
+  "BEGIN:VALARM\r\nACTION:DISPLAY\r\nSUMMARY:Alarm\r\nDESCRIPTION:Reminder\r\nTRIGGER:-PT10M\r\n" +
+  "DURATION:PT5M\r\nREPEAT:2\r\nEND:VALARM\r\nEND:VEVENT\r\n" +
+  "BEGIN:VEVENT\r\nUID:holiday\r\nSUMMARY:Holiday\r\n" +
+  "DTSTART;VALUE=DATE:20130426\r\nDTEND;VALUE=DATE:20130427\r\nEND:VEVENT\r\n" +
+  "BEGIN:VEVENT\r\nUID:cancelled\r\nSUMMARY:Cancelled\r\nSTATUS:CANCELLED\r\n" +
+  "DTSTART:20130426T150000Z\r\nDTEND:20130426T160000Z\r\nEND:VEVENT\r\n" +
+  "BEGIN:VEVENT\r\nUID:review-1\r\nSUMMARY:Review\r\n" +
+  "DTSTART:20130425T150000Z\r\nDTEND:20130425T160000Z\r\nEND:VEVENT\r\n" +
+  "BEGIN:VEVENT\r\nUID:5@hourglass\r\nSUMMARY:Exported\r\n" +
+  "DTSTART:20130426T170000Z\r\nDTEND:20130426T180000Z\r\nEND:VEVENT\r\n" +
+  "END:VCALENDAR\r\n"
+
+func TestParseIcs(t *testing.T) {
+  events, err := ParseIcs(strings.NewReader(icsTestCalendar))
+  if err != nil {
+    t.Error(err)
+    return
+  }
+
+  /* the all-day event is skipped */
+  if len(events) != 4 {
+    t.Errorf("expected 4 events, got %d", len(events))
+    return
+  }
+  newYork, _ := time.LoadLocation("America/New_York")
+  start := time.Date(2013, 4, 26, 9, 0, 0, 0, newYork)
+  /* the alarm's summary and duration aren't the event's */
+  if events[0].Uid != "standup-1" || events[0].Summary != "Standup" || !events[0].Start.Equal(start) ||
+    !events[0].End.Equal(start.Add(15 * time.Minute)) ||
+    len(events[0].Categories) != 1 || events[0].Categories[0] != "meetings" {
+    t.Errorf("unexpected event: %+v", events[0])
+  }
+  if !events[1].Cancelled {
+    t.Errorf("expected event to be cancelled: %+v", events[1])
+  }
+
+  _, err = ParseIcs(strings.NewReader("BEGIN:VEVENT\r\njunk\r\n"))
+  if err == nil {
+    t.Error("expected error for invalid line")
+  }
+  _, err = ParseIcs(strings.NewReader("BEGIN:VEVENT\r\nDURATION:junk\r\n"))
+  if err == nil {
+    t.Error("expected error for invalid duration")
+  }
+}
+
+func TestImportCommand_Run(t *testing.T) {
+  dir, err := ioutil.TempDir("", "hourglass")
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer os.RemoveAll(dir)
+  calendar := filepath.Join(dir, "calendar.ics")
+  ioutil.WriteFile(calendar, []byte(icsTestCalendar), 0644)
+
+  db := &Memory{}
+  cmd := ImportCommand{Uids: filepath.Join(dir, "uids.csv")}
+  c := fakeCmdClock{when(2013, 4, 26, 12)}
+
+  output, err := cmd.Run(c, db, "ics", calendar, "2013-04-26", "2013-04-26")
+  if err != nil {
+    t.Error(err)
+    return
+  }
+  if output != "imported 1 activities (skipped 1 duplicates)" {
+    t.Errorf("unexpected output: %q", output)
+  }
+  if len(db.activities) != 1 || db.activities[1].Name != "Standup" ||
+    db.activities[1].IsRunning() || db.activities[1].TagList() != "meetings" {
+    t.Errorf("unexpected activities: %v", db.activities)
+  }
+
+  /* importing again skips what was already imported */
+  output, err = cmd.Run(c, db, "ics", calendar)
+  if err != nil {
+    t.Error(err)
+    return
+  }
+  if output != "imported 1 activities (skipped 2 duplicates)" {
+    t.Errorf("unexpected output: %q", output)
+  }
+  if len(db.activities) != 2 || db.activities[2].Name != "Review" {
+    t.Errorf("unexpected activities: %v", db.activities)
+  }
+
+  for i, args := range [][]string{nil, {"csv", calendar}, {"ics", calendar, "junk"}} {
+    _, err = cmd.Run(c, db, args...)
+    if _, ok := err.(SyntaxError); !ok {
+      t.Errorf("test %d: expected error type SyntaxError, got %T", i, err)
+    }
+  }
+}
+
+func TestImportCommand_Run_WithoutUid(t *testing.T) {
+  dir, err := ioutil.TempDir("", "hourglass")
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer os.RemoveAll(dir)
+  calendar := filepath.Join(dir, "calendar.ics")
+  ioutil.WriteFile(calendar, []byte("BEGIN:VCALENDAR\r\n" +
+    "BEGIN:VEVENT\r\nSUMMARY:Standup\r\nDTSTART:20130426T130000Z\r\nDURATION:PT15M\r\nEND:VEVENT\r\n" +
+    "BEGIN:VEVENT\r\nSUMMARY:Review\r\nDTSTART:20130426T130000Z\r\nDURATION:PT15M\r\nEND:VEVENT\r\n" +
+    "END:VCALENDAR\r\n"), 0644)
+
+  db := &Memory{}
+  cmd := ImportCommand{Uids: filepath.Join(dir, "uids.csv")}
+  c := fakeCmdClock{when(2013, 4, 26, 12)}
+
+  expected := []string{"imported 2 activities", "imported 0 activities (skipped 2 duplicates)"}
+  for i, output := range expected {
+    result, err := cmd.Run(c, db, "ics", calendar)
+    if err != nil {
+      t.Fatal(err)
+    }
+    if result != output {
+      t.Errorf("run %d: expected %q, got %q", i, output, result)
+    }
+  }
+}
+
+func TestExportCommand_Run(t *testing.T) {
+  db := &Memory{}
+  db.SaveActivity(&Activity{Name: "foo", Start: when(2013, 4, 26, 9), End: when(2013, 4, 26, 10)})
+  db.SaveActivity(&Activity{Name: "bar", Start: when(2013, 4, 20, 9), End: when(2013, 4, 20, 10)})
+  c := fakeCmdClock{when(2013, 4, 26, 12)}
+
+  info := &CommandInfo{Name: "export", Command: &ExportCommand{}}
+  output, err := info.Run(c, db, "--format", "ics", "week")
+  if err != nil {
+    t.Error(err)
+    return
+  }
+  if strings.Count(output, "BEGIN:VEVENT") != 1 || !strings.Contains(output, "SUMMARY:foo") {
+    t.Errorf("unexpected output:\n%s", output)
+  }
+
+  output, err = info.Run(c, db)
+  if strings.Count(output, "BEGIN:VEVENT") != 2 {
+    t.Errorf("expected all activities:\n%s", output)
+  }
+
+  _, err = info.Run(c, db, "--format", "junk")
+  if _, ok := err.(SyntaxError); !ok {
+    t.Errorf("expected error type SyntaxError, got %T", err)
+  }
+}
+
+func TestExportCommand_Help(t *testing.T) {
+  cmd := ExportCommand{}
+  if cmd.Help() == "" {
+    t.Error("no help available")
+  }
+}
+
+func TestImportCommand_Help(t *testing.T) {
+  cmd := ImportCommand{}
+  if cmd.Help() == "" {
+    t.Error("no help available")
+  }
+}
@@ -0,0 +1,653 @@
+package hourglass
+
+import (
+  "bufio"
+  "bytes"
+  "crypto/rand"
+  "encoding/hex"
+  "encoding/json"
+  "fmt"
+  "io"
+  "os"
+  "sort"
+  "sync"
+  "time"
+)
+
+const JsonlVersion = 1
+
+/* help messages */
+const (
+  compactHelp = "Usage: %s compact\n\nShrink the database\n\nRewrites the event log of the -jsonl backend with just the events needed for the activities, rates, projects and goals it has now. The other backends don't need compacting."
+)
+
+/* event types */
+const (
+  jsonlEventCreate = "create"
+  jsonlEventUpdate = "update"
+  jsonlEventDelete = "delete"
+  jsonlEventRate = "rate"
+  jsonlEventDeleteRate = "delete-rate"
+  jsonlEventProject = "project"
+  jsonlEventRenameProject = "rename-project"
+  jsonlEventGoal = "goal"
+  jsonlEventDeleteGoal = "delete-goal"
+)
+
+/*
+ * JSON lines backend. Every change is appended to the file as an event on a
+ * line of its own, so the file diffs well and copies of it can be merged
+ * by syncing tools. The state is rebuilt by replaying the events:
+ *
+ *   - lines that aren't events, like conflict markers, are skipped
+ *   - activities are tracked by a random key rather than their id, and an
+ *     activity whose id was taken by another copy gets the next free one
+ *   - updates to activities that were never created create them, and
+ *     anything after an activity is deleted is ignored
+ *
+ * Compact rewrites the file with just the events needed for the state.
+ * Other processes can append while it runs, so it carries their events over
+ * to the new file before replacing the old one.
+ */
+type Jsonl struct {
+  Filename string
+  mutex sync.Mutex
+}
+
+type jsonlEvent struct {
+  Event string `json:"event"`
+  Key string `json:"key,omitempty"`
+  Id int64 `json:"id,omitempty"`
+  Activity *jsonlActivity `json:"activity,omitempty"`
+  Rate *jsonlRate `json:"rate,omitempty"`
+  Project *jsonlProject `json:"project,omitempty"`
+  Goal *jsonlGoal `json:"goal,omitempty"`
+  /* project names for renames */
+  Old string `json:"old,omitempty"`
+  New string `json:"new,omitempty"`
+}
+
+type jsonlActivity struct {
+  Name string `json:"name"`
+  Project string `json:"project,omitempty"`
+  Tags []string `json:"tags,omitempty"`
+  Start time.Time `json:"start"`
+  End *time.Time `json:"end,omitempty"`
+  Pauses []pauseJSON `json:"pauses,omitempty"`
+  Notes string `json:"notes,omitempty"`
+  User string `json:"user,omitempty"`
+}
+
+type jsonlRate struct {
+  Project string `json:"project"`
+  Tag string `json:"tag"`
+  Amount int64 `json:"amount,omitempty"`
+  Currency string `json:"currency,omitempty"`
+  Billable bool `json:"billable,omitempty"`
+}
+
+type jsonlProject struct {
+  Name string `json:"name"`
+  Description string `json:"description,omitempty"`
+  Archived bool `json:"archived,omitempty"`
+}
+
+type jsonlGoal struct {
+  Project string `json:"project"`
+  Tag string `json:"tag"`
+  Target string `json:"target,omitempty"`
+  Period string `json:"period,omitempty"`
+  Cap bool `json:"cap,omitempty"`
+}
+
+func newJsonlActivity(a *Activity) *jsonlActivity {
+  result := &jsonlActivity{Name: a.Name, Project: a.Project, Tags: a.Tags, Start: a.Start,
+    Notes: a.Notes, User: a.User}
+  if !a.End.IsZero() {
+    end := a.End
+    result.End = &end
+  }
+  for _, pause := range a.Pauses {
+    p := pauseJSON{Start: pause.Start}
+    if !pause.End.IsZero() {
+      end := pause.End
+      p.End = &end
+    }
+    result.Pauses = append(result.Pauses, p)
+  }
+  return result
+}
+
+func (j *jsonlActivity) activity(id int64) *Activity {
+  a := &Activity{Id: id, Name: j.Name, Project: j.Project, Tags: j.Tags, Start: j.Start,
+    Notes: j.Notes, User: j.User}
+  if j.End != nil {
+    a.End = *j.End
+  }
+  for _, p := range j.Pauses {
+    pause := Pause{Start: p.Start}
+    if p.End != nil {
+      pause.End = *p.End
+    }
+    a.Pauses = append(a.Pauses, pause)
+  }
+  return a
+}
+
+func newJsonlRate(r *Rate) *jsonlRate {
+  return &jsonlRate{r.Project, r.Tag, r.Hourly.Amount, r.Hourly.Currency, r.Billable}
+}
+
+func newJsonlGoal(g *Goal) *jsonlGoal {
+  return &jsonlGoal{g.Project, g.Tag, time.Duration(g.Target).String(), g.Period, g.Cap}
+}
+
+/* random key identifying an activity across copies of the file */
+func newJsonlKey() (key string, err error) {
+  b := make([]byte, 8)
+  _, err = rand.Read(b)
+  key = hex.EncodeToString(b)
+  return
+}
+
+/* state from replaying the events */
+type jsonlState struct {
+  *Memory
+  keys map[int64]string
+  ids map[string]int64
+  deleted map[string]bool
+  events int
+  /* bytes read, to notice events appended since */
+  size int64
+}
+
+func (state *jsonlState) apply(e *jsonlEvent) {
+  state.events++
+  switch e.Event {
+  case jsonlEventCreate, jsonlEventUpdate:
+    if e.Key == "" || e.Activity == nil || state.deleted[e.Key] {
+      return
+    }
+    id, ok := state.ids[e.Key]
+    if !ok {
+      id = e.Id
+      if _, taken := state.keys[id]; taken || id <= 0 {
+        id = state.lastId + 1
+      }
+      state.keys[id] = e.Key
+      state.ids[e.Key] = id
+      if id > state.lastId {
+        state.lastId = id
+      }
+    }
+    /* times come back in the local zone, whatever zone they were saved in */
+    state.activities[id] = e.Activity.activity(id).local()
+
+  case jsonlEventDelete:
+    state.deleted[e.Key] = true
+    if id, ok := state.ids[e.Key]; ok {
+      delete(state.activities, id)
+      delete(state.keys, id)
+      delete(state.ids, e.Key)
+    }
+
+  case jsonlEventRate:
+    if e.Rate != nil {
+      state.SaveRate(&Rate{e.Rate.Project, e.Rate.Tag, Money{e.Rate.Amount, e.Rate.Currency},
+        e.Rate.Billable})
+    }
+  case jsonlEventDeleteRate:
+    if e.Rate != nil {
+      state.DeleteRate(e.Rate.Project, e.Rate.Tag)
+    }
+  case jsonlEventProject:
+    if e.Project != nil {
+      state.SaveProject(&Project{e.Project.Name, e.Project.Description, e.Project.Archived})
+    }
+  case jsonlEventRenameProject:
+    state.RenameProject(e.Old, e.New)
+  case jsonlEventGoal:
+    if e.Goal != nil {
+      target, _ := time.ParseDuration(e.Goal.Target)
+      state.SaveGoal(&Goal{e.Goal.Project, e.Goal.Tag, Duration(target), e.Goal.Period, e.Goal.Cap})
+    }
+  case jsonlEventDeleteGoal:
+    if e.Goal != nil {
+      state.DeleteGoal(e.Goal.Project, e.Goal.Tag)
+    }
+
+  default:
+    /* from a newer version, maybe */
+    state.events--
+  }
+}
+
+func (db *Jsonl) Valid() (bool, error) {
+  return true, nil
+}
+
+func (db *Jsonl) Version() (int, error) {
+  return JsonlVersion, nil
+}
+
+/* there's nothing to migrate yet, but the file is created */
+func (db *Jsonl) Migrate() (err error) {
+  var f *os.File
+  f, err = os.OpenFile(db.Filename, os.O_WRONLY | os.O_APPEND | os.O_CREATE, 0644)
+  if err == nil {
+    err = f.Close()
+  }
+  return
+}
+
+/* replay the events, with the mutex held */
+func (db *Jsonl) replay() (state *jsonlState, err error) {
+  state = &jsonlState{
+    Memory: &Memory{activities: make(map[int64]*Activity)},
+    keys: make(map[int64]string),
+    ids: make(map[string]int64),
+    deleted: make(map[string]bool),
+  }
+
+  var f *os.File
+  f, err = os.Open(db.Filename)
+  if os.IsNotExist(err) {
+    return state, nil
+  } else if err != nil {
+    return
+  }
+  defer f.Close()
+
+  r := bufio.NewReader(f)
+  for {
+    var line []byte
+    line, err = r.ReadBytes('\n')
+    state.size += int64(len(line))
+    if len(bytes.TrimSpace(line)) > 0 {
+      var e jsonlEvent
+      if json.Unmarshal(line, &e) == nil {
+        state.apply(&e)
+      }
+    }
+    if err == io.EOF {
+      return state, nil
+    } else if err != nil {
+      return
+    }
+  }
+}
+
+/* append events, with the mutex held */
+func (db *Jsonl) append(events ...*jsonlEvent) (err error) {
+  var buf bytes.Buffer
+  for _, e := range events {
+    var line []byte
+    line, err = json.Marshal(e)
+    if err != nil {
+      return
+    }
+    buf.Write(line)
+    buf.WriteByte('\n')
+  }
+  return db.write(buf.Bytes())
+}
+
+/* append lines to the file, with the mutex held */
+func (db *Jsonl) write(data []byte) (err error) {
+  var f *os.File
+  f, err = os.OpenFile(db.Filename, os.O_RDWR | os.O_APPEND | os.O_CREATE, 0644)
+  if err != nil {
+    return
+  }
+  defer f.Close()
+
+  /* don't run on from a line that was cut short */
+  var info os.FileInfo
+  info, err = f.Stat()
+  if err != nil {
+    return
+  }
+  if info.Size() > 0 {
+    last := make([]byte, 1)
+    _, err = f.ReadAt(last, info.Size() - 1)
+    if err != nil {
+      return
+    }
+    if last[0] != '\n' {
+      _, err = f.Write([]byte{'\n'})
+      if err != nil {
+        return
+      }
+    }
+  }
+  _, err = f.Write(data)
+  return
+}
+
+func (db *Jsonl) load() (state *jsonlState, err error) {
+  db.mutex.Lock()
+  defer db.mutex.Unlock()
+  return db.replay()
+}
+
+func (db *Jsonl) SaveActivity(a *Activity) (err error) {
+  db.mutex.Lock()
+  defer db.mutex.Unlock()
+
+  var state *jsonlState
+  state, err = db.replay()
+  if err != nil {
+    return
+  }
+
+  e := &jsonlEvent{Event: jsonlEventUpdate, Id: a.Id, Activity: newJsonlActivity(a)}
+  if a.Id == 0 {
+    /* just a hint, the id is whatever replaying the event gives it */
+    e.Event = jsonlEventCreate
+    e.Id = state.lastId + 1
+    e.Key, err = newJsonlKey()
+    if err != nil {
+      return
+    }
+  } else {
+    var ok bool
+    e.Key, ok = state.keys[a.Id]
+    if !ok {
+      return ErrNotFound
+    }
+  }
+  err = db.append(e)
+  if err != nil || a.Id != 0 {
+    return
+  }
+
+  /* another process could have taken the id in the meantime */
+  state, err = db.replay()
+  if err != nil {
+    return
+  }
+  id, ok := state.ids[e.Key]
+  if !ok {
+    return ErrNotFound
+  }
+  a.Id = id
+  return
+}
+
+func (db *Jsonl) FindActivity(id int64) (activity *Activity, err error) {
+  var state *jsonlState
+  state, err = db.load()
+  if err != nil {
+    return
+  }
+  return state.FindActivity(id)
+}
+
+func (db *Jsonl) FindAllActivities() (activities []*Activity, err error) {
+  var state *jsonlState
+  state, err = db.load()
+  if err != nil {
+    return
+  }
+  return state.FindAllActivities()
+}
+
+func (db *Jsonl) FindRunningActivities() (activities []*Activity, err error) {
+  var state *jsonlState
+  state, err = db.load()
+  if err != nil {
+    return
+  }
+  return state.FindRunningActivities()
+}
+
+func (db *Jsonl) FindActivitiesBetween(lower time.Time, upper time.Time) (activities []*Activity, err error) {
+  var state *jsonlState
+  state, err = db.load()
+  if err != nil {
+    return
+  }
+  return state.FindActivitiesBetween(lower, upper)
+}
+
+func (db *Jsonl) DeleteActivity(id int64) (err error) {
+  db.mutex.Lock()
+  defer db.mutex.Unlock()
+
+  var state *jsonlState
+  state, err = db.replay()
+  if err != nil {
+    return
+  }
+  key, ok := state.keys[id]
+  if !ok {
+    return ErrNotFound
+  }
+  return db.append(&jsonlEvent{Event: jsonlEventDelete, Key: key, Id: id})
+}
+
+func (db *Jsonl) SaveRate(r *Rate) error {
+  db.mutex.Lock()
+  defer db.mutex.Unlock()
+  return db.append(&jsonlEvent{Event: jsonlEventRate, Rate: newJsonlRate(r)})
+}
+
+func (db *Jsonl) FindRates() (rates []*Rate, err error) {
+  var state *jsonlState
+  state, err = db.load()
+  if err != nil {
+    return
+  }
+  return state.FindRates()
+}
+
+func (db *Jsonl) DeleteRate(project, tag string) (err error) {
+  db.mutex.Lock()
+  defer db.mutex.Unlock()
+
+  var state *jsonlState
+  state, err = db.replay()
+  if err != nil {
+    return
+  }
+  err = state.DeleteRate(project, tag)
+  if err == nil {
+    err = db.append(&jsonlEvent{Event: jsonlEventDeleteRate, Rate: &jsonlRate{Project: project, Tag: tag}})
+  }
+  return
+}
+
+func (db *Jsonl) SaveProject(p *Project) error {
+  db.mutex.Lock()
+  defer db.mutex.Unlock()
+  return db.append(&jsonlEvent{Event: jsonlEventProject,
+    Project: &jsonlProject{p.Name, p.Description, p.Archived}})
+}
+
+func (db *Jsonl) FindProjects() (projects []*Project, err error) {
+  var state *jsonlState
+  state, err = db.load()
+  if err != nil {
+    return
+  }
+  return state.FindProjects()
+}
+
+func (db *Jsonl) RenameProject(old, new string) (err error) {
+  db.mutex.Lock()
+  defer db.mutex.Unlock()
+
+  var state *jsonlState
+  state, err = db.replay()
+  if err != nil {
+    return
+  }
+  err = state.RenameProject(old, new)
+  if err != nil {
+    return
+  }
+  err = db.append(&jsonlEvent{Event: jsonlEventRenameProject, Old: old, New: new})
+  return
+}
+
+func (db *Jsonl) SaveGoal(g *Goal) error {
+  db.mutex.Lock()
+  defer db.mutex.Unlock()
+  return db.append(&jsonlEvent{Event: jsonlEventGoal, Goal: newJsonlGoal(g)})
+}
+
+func (db *Jsonl) FindGoals() (goals []*Goal, err error) {
+  var state *jsonlState
+  state, err = db.load()
+  if err != nil {
+    return
+  }
+  return state.FindGoals()
+}
+
+func (db *Jsonl) DeleteGoal(project, tag string) (err error) {
+  db.mutex.Lock()
+  defer db.mutex.Unlock()
+
+  var state *jsonlState
+  state, err = db.replay()
+  if err != nil {
+    return
+  }
+  err = state.DeleteGoal(project, tag)
+  if err == nil {
+    err = db.append(&jsonlEvent{Event: jsonlEventDeleteGoal, Goal: &jsonlGoal{Project: project, Tag: tag}})
+  }
+  return
+}
+
+/*
+ * Rewrite the file with an event for each activity, rate, project and goal,
+ * and one for each deleted activity so that copies of the file merged back
+ * in later can't bring them back. The new file replaces the old one once
+ * it's complete.
+ */
+func (db *Jsonl) Compact() (before, after int, err error) {
+  db.mutex.Lock()
+  defer db.mutex.Unlock()
+
+  var state *jsonlState
+  state, err = db.replay()
+  if err != nil {
+    return
+  }
+  before = state.events
+
+  var events []*jsonlEvent
+  activities, _ := state.FindAllActivities()
+  for _, activity := range activities {
+    events = append(events, &jsonlEvent{Event: jsonlEventCreate, Key: state.keys[activity.Id],
+      Id: activity.Id, Activity: newJsonlActivity(activity)})
+  }
+  deleted := make([]string, 0, len(state.deleted))
+  for key := range state.deleted {
+    deleted = append(deleted, key)
+  }
+  sort.Strings(deleted)
+  for _, key := range deleted {
+    events = append(events, &jsonlEvent{Event: jsonlEventDelete, Key: key})
+  }
+  projects, _ := state.FindProjects()
+  for _, p := range projects {
+    events = append(events, &jsonlEvent{Event: jsonlEventProject,
+      Project: &jsonlProject{p.Name, p.Description, p.Archived}})
+  }
+  rates, _ := state.FindRates()
+  for _, r := range rates {
+    events = append(events, &jsonlEvent{Event: jsonlEventRate, Rate: newJsonlRate(r)})
+  }
+  goals, _ := state.FindGoals()
+  for _, g := range goals {
+    events = append(events, &jsonlEvent{Event: jsonlEventGoal, Goal: newJsonlGoal(g)})
+  }
+  after = len(events)
+
+  compacted := &Jsonl{Filename: db.Filename + ".tmp"}
+  os.Remove(compacted.Filename)
+  err = compacted.append(events...)
+  if err == nil {
+    err = db.replace(compacted.Filename, state.size)
+  }
+  if err != nil {
+    os.Remove(compacted.Filename)
+  }
+  return
+}
+
+/*
+ * Replace the file with a compacted one, after copying over the events that
+ * other processes appended since the first size bytes were read. Events
+ * appended between the last look and the rename are still lost, but that
+ * window is much shorter than compacting.
+ */
+func (db *Jsonl) replace(filename string, size int64) (err error) {
+  compacted := &Jsonl{Filename: filename}
+  for {
+    var info os.FileInfo
+    info, err = os.Stat(db.Filename)
+    if err != nil {
+      return
+    }
+    if info.Size() == size {
+      break
+    } else if info.Size() < size {
+      return fmt.Errorf("%s was rewritten while compacting", db.Filename)
+    }
+
+    var f *os.File
+    f, err = os.Open(db.Filename)
+    if err != nil {
+      return
+    }
+    tail := make([]byte, info.Size() - size)
+    _, err = f.ReadAt(tail, size)
+    f.Close()
+    if err != nil {
+      return
+    }
+    err = compacted.write(tail)
+    if err != nil {
+      return
+    }
+    size = info.Size()
+  }
+  return os.Rename(filename, db.Filename)
+}
+
+/* compact */
+type CompactCommand struct{}
+
+func (CompactCommand) Run(c Clock, db Database, args ...string) (output string, err error) {
+  if len(args) > 0 {
+    err = SyntaxError("too many arguments")
+    return
+  }
+
+  /* the backend might be wrapped for hooks and users */
+  for db != nil {
+    if compacter, ok := db.(Compacter); ok {
+      var before, after int
+      before, after, err = compacter.Compact()
+      if err == nil {
+        output = fmt.Sprintf("compacted %d events into %d", before, after)
+      }
+      return
+    }
+    wrapper, ok := db.(DatabaseWrapper)
+    if !ok {
+      break
+    }
+    db = wrapper.Unwrap()
+  }
+  err = fmt.Errorf("this backend doesn't need compacting")
+  return
+}
+
+func (CompactCommand) Help() string {
+  return compactHelp
+}
@@ -0,0 +1,301 @@
+package hourglass
+
+import (
+  "fmt"
+  "io/ioutil"
+  "os"
+  "path/filepath"
+  "strings"
+  "sync"
+  "testing"
+  "time"
+)
+
+func jsonlTestRun(f func(db *Jsonl), t *testing.T) {
+  dir, err := ioutil.TempDir("", "hourglass")
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer os.RemoveAll(dir)
+
+  db := &Jsonl{Filename: filepath.Join(dir, "hourglass.jsonl")}
+  err = db.Migrate()
+  if err != nil {
+    t.Fatal(err)
+  }
+  f(db)
+}
+
+func jsonlWrite(t *testing.T, db *Jsonl, lines ...string) {
+  err := ioutil.WriteFile(db.Filename, []byte(strings.Join(lines, "\n")), 0644)
+  if err != nil {
+    t.Fatal(err)
+  }
+}
+
+func TestJsonl_SaveActivity(t *testing.T) {
+  f := func(db *Jsonl) {
+    activity := &Activity{Name: "foo", Start: when(2013, 4, 26, 9)}
+    db.SaveActivity(activity)
+    activity.End = when(2013, 4, 26, 10)
+    db.SaveActivity(activity)
+    db.DeleteActivity(activity.Id)
+
+    data, err := ioutil.ReadFile(db.Filename)
+    if err != nil {
+      t.Fatal(err)
+    }
+    lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+    if len(lines) != 3 {
+      t.Fatalf("expected 3 events, got:\n%s", data)
+    }
+    for i, event := range []string{"create", "update", "delete"} {
+      if !strings.HasPrefix(lines[i], `{"event":"` + event + `"`) {
+        t.Errorf("expected %s event, got %s", event, lines[i])
+      }
+    }
+  }
+  jsonlTestRun(f, t)
+}
+
+/* what two copies of the file could look like after being merged */
+var jsonlReplayTests = []struct {
+  lines []string
+  expected []*Activity
+}{
+  /* test 0: conflict markers and a line that was cut short */
+  {
+    []string{
+      `{"event":"create","key":"a","id":1,"activity":{"name":"foo","start":"2013-04-26T09:00:00Z"}}`,
+      "<<<<<<< HEAD",
+      `{"event":"update","key":"a","id":1,"activity":{"name":"bar","start":"2013-04-26T09:00:00Z"}}`,
+      "=======",
+      ">>>>>>> theirs",
+      `{"event":"update","key":"a","id":1,"activity":{"na`,
+    },
+    []*Activity{&Activity{Id: 1, Name: "bar", Start: time.Date(2013, 4, 26, 9, 0, 0, 0, time.UTC)}},
+  },
+
+  /* test 1: both copies created activity 1 */
+  {
+    []string{
+      `{"event":"create","key":"a","id":1,"activity":{"name":"foo","start":"2013-04-26T09:00:00Z"}}`,
+      `{"event":"create","key":"b","id":1,"activity":{"name":"bar","start":"2013-04-26T10:00:00Z"}}`,
+      `{"event":"update","key":"b","id":1,"activity":{"name":"baz","start":"2013-04-26T10:00:00Z"}}`,
+    },
+    []*Activity{
+      &Activity{Id: 1, Name: "foo", Start: time.Date(2013, 4, 26, 9, 0, 0, 0, time.UTC)},
+      &Activity{Id: 2, Name: "baz", Start: time.Date(2013, 4, 26, 10, 0, 0, 0, time.UTC)},
+    },
+  },
+
+  /* test 2: updates without a create, and edits after a delete */
+  {
+    []string{
+      `{"event":"update","key":"a","id":3,"activity":{"name":"foo","start":"2013-04-26T09:00:00Z"}}`,
+      `{"event":"create","key":"b","id":1,"activity":{"name":"bar","start":"2013-04-26T10:00:00Z"}}`,
+      `{"event":"delete","key":"b","id":1}`,
+      `{"event":"update","key":"b","id":1,"activity":{"name":"baz","start":"2013-04-26T10:00:00Z"}}`,
+      `{"event":"archive","key":"a","id":3}`,
+    },
+    []*Activity{&Activity{Id: 3, Name: "foo", Start: time.Date(2013, 4, 26, 9, 0, 0, 0, time.UTC)}},
+  },
+}
+
+func TestJsonl_Replay(t *testing.T) {
+  for i, config := range jsonlReplayTests {
+    jsonlTestRun(func(db *Jsonl) {
+      jsonlWrite(t, db, config.lines...)
+      activities, err := db.FindAllActivities()
+      if err != nil {
+        t.Errorf("test %d: %s", i, err)
+        return
+      }
+      if len(activities) != len(config.expected) {
+        t.Errorf("test %d: expected %v, got %v", i, config.expected, activities)
+        return
+      }
+      for j, activity := range config.expected {
+        if !activity.Equal(activities[j]) {
+          t.Errorf("test %d: expected %v, got %v", i, activity, activities[j])
+        }
+      }
+    }, t)
+  }
+}
+
+func TestJsonl_SaveActivity_AfterCutShortLine(t *testing.T) {
+  f := func(db *Jsonl) {
+    jsonlWrite(t, db,
+      `{"event":"create","key":"a","id":1,"activity":{"name":"foo","start":"2013-04-26T09:00:00Z"}}`,
+      `{"event":"update","key":"a","id":1,"activity":{"na`)
+    activity := &Activity{Name: "bar", Start: when(2013, 4, 26, 10)}
+    err := db.SaveActivity(activity)
+    if err != nil {
+      t.Fatal(err)
+    }
+    if activity.Id != 2 {
+      t.Errorf("expected id 2, got %d", activity.Id)
+    }
+
+    var found *Activity
+    found, err = db.FindActivity(2)
+    if err != nil || !activity.Equal(found) {
+      t.Errorf("expected %v, got %v, %v", activity, found, err)
+    }
+  }
+  jsonlTestRun(f, t)
+}
+
+func TestJsonl_SaveActivity_FromTwoProcesses(t *testing.T) {
+  f := func(db *Jsonl) {
+    /* each with its own mutex, like two processes */
+    dbs := []*Jsonl{db, &Jsonl{Filename: db.Filename}}
+    saved := make([][]*Activity, len(dbs))
+    var wg sync.WaitGroup
+    for i := range dbs {
+      wg.Add(1)
+      go func(i int) {
+        defer wg.Done()
+        for j := 0; j < 20; j++ {
+          activity := &Activity{Name: fmt.Sprintf("%d-%d", i, j), Start: when(2013, 4, 26, 9)}
+          if err := dbs[i].SaveActivity(activity); err != nil {
+            t.Error(err)
+          }
+          saved[i] = append(saved[i], activity)
+        }
+      }(i)
+    }
+    wg.Wait()
+
+    for _, activities := range saved {
+      for _, activity := range activities {
+        found, err := db.FindActivity(activity.Id)
+        if err != nil || found.Name != activity.Name {
+          t.Errorf("expected %s for id %d, got %v, %v", activity.Name, activity.Id, found, err)
+        }
+      }
+    }
+  }
+  jsonlTestRun(f, t)
+}
+
+func TestJsonl_Compact(t *testing.T) {
+  f := func(db *Jsonl) {
+    activity := &Activity{Name: "foo", Tags: []string{"bar"}, Start: when(2013, 4, 26, 9)}
+    deleted := &Activity{Name: "baz", Start: when(2013, 4, 26, 10)}
+    db.SaveActivity(activity)
+    db.SaveActivity(deleted)
+    activity.End = when(2013, 4, 26, 11)
+    db.SaveActivity(activity)
+    db.DeleteActivity(deleted.Id)
+    db.SaveProject(&Project{Name: "old"})
+    db.RenameProject("old", "new")
+    db.SaveGoal(&Goal{Project: "new", Target: 10, Period: PeriodWeek})
+    db.SaveRate(&Rate{Project: "new", Hourly: Money{5000, "USD"}})
+    db.DeleteRate("new", "")
+    state, _ := db.load()
+    key := state.keys[deleted.Id]
+
+    before, after, err := db.Compact()
+    if err != nil {
+      t.Fatal(err)
+    }
+    if before != 9 || after != 4 {
+      t.Errorf("expected 9 events compacted into 4, got %d into %d", before, after)
+    }
+
+    var found *Activity
+    found, err = db.FindActivity(activity.Id)
+    if err != nil || !activity.Equal(found) {
+      t.Errorf("expected %v, got %v, %v", activity, found, err)
+    }
+    projects, _ := db.FindProjects()
+    goals, _ := db.FindGoals()
+    rates, _ := db.FindRates()
+    if len(projects) != 1 || projects[0].Name != "new" || len(goals) != 1 || goals[0].Target != 10 ||
+      len(rates) != 0 {
+      t.Errorf("unexpected projects %v, goals %v or rates %v", projects, goals, rates)
+    }
+
+    /* the ids of deleted activities are free again */
+    other := &Activity{Name: "qux", Start: when(2013, 4, 26, 12)}
+    db.SaveActivity(other)
+    if other.Id != 2 {
+      t.Errorf("expected id 2, got %d", other.Id)
+    }
+
+    /* an old copy merged back in doesn't bring the deleted activity back */
+    db.write([]byte(`{"event":"update","key":"` + key + `","id":2,"activity":{"name":"baz","start":"2013-04-26T10:00:00Z"}}` + "\n"))
+    activities, _ := db.FindAllActivities()
+    if len(activities) != 2 {
+      t.Errorf("expected 2 activities, got %v", activities)
+    }
+  }
+  jsonlTestRun(f, t)
+}
+
+func TestJsonl_Compact_WithAppends(t *testing.T) {
+  f := func(db *Jsonl) {
+    db.SaveActivity(&Activity{Name: "foo", Start: when(2013, 4, 26, 9)})
+    state, _ := db.load()
+    compacted := &Jsonl{Filename: db.Filename + ".tmp"}
+    compacted.SaveActivity(&Activity{Name: "foo", Start: when(2013, 4, 26, 9)})
+
+    /* another process appends while the file is being compacted */
+    other := &Jsonl{Filename: db.Filename}
+    other.SaveActivity(&Activity{Name: "bar", Start: when(2013, 4, 26, 10)})
+
+    err := db.replace(compacted.Filename, state.size)
+    if err != nil {
+      t.Fatal(err)
+    }
+    activities, _ := db.FindAllActivities()
+    if len(activities) != 2 || activities[1].Name != "bar" {
+      t.Errorf("expected the appended activity to be kept, got %v", activities)
+    }
+
+    /* the file was compacted by someone else */
+    compacted.SaveActivity(&Activity{Name: "baz", Start: when(2013, 4, 26, 11)})
+    err = db.replace(compacted.Filename, state.size + 1000)
+    if err == nil {
+      t.Error("expected error for a file that shrank")
+    }
+  }
+  jsonlTestRun(f, t)
+}
+
+func TestCompactCommand_Run(t *testing.T) {
+  f := func(db *Jsonl) {
+    db.SaveActivity(&Activity{Name: "foo", Start: when(2013, 4, 26, 9)})
+    db.SaveActivity(&Activity{Id: 1, Name: "bar", Start: when(2013, 4, 26, 9)})
+    c := fakeCmdClock{when(2013, 4, 26, 12)}
+
+    /* the backend is found under the wrappers */
+    wrapped := &HookDatabase{Database: &UserDatabase{Database: db, User: "alice"}, Clock: c}
+    output, err := CompactCommand{}.Run(c, wrapped)
+    if err != nil {
+      t.Fatal(err)
+    }
+    if output != "compacted 2 events into 1" {
+      t.Errorf("unexpected output: %q", output)
+    }
+
+    _, err = CompactCommand{}.Run(c, &Memory{})
+    if err == nil {
+      t.Error("expected error for a backend that can't be compacted")
+    }
+    _, err = CompactCommand{}.Run(c, db, "junk")
+    if _, ok := err.(SyntaxError); !ok {
+      t.Errorf("expected error type SyntaxError, got %T", err)
+    }
+  }
+  jsonlTestRun(f, t)
+}
+
+func TestCompactCommand_Help(t *testing.T) {
+  cmd := CompactCommand{}
+  if cmd.Help() == "" {
+    t.Error("no help available")
+  }
+}
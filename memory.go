@@ -0,0 +1,266 @@
+package hourglass
+
+import (
+  "sort"
+  "sync"
+  "time"
+)
+
+const MemoryVersion = 1
+
+/*
+ * Backend that keeps everything in memory, for tests and for programs that
+ * embed hourglass. Activities and records are copied going in and coming
+ * out, like the other backends, and ids count up from 1 without being
+ * reused. The zero value is ready to use.
+ */
+type Memory struct {
+  mutex sync.RWMutex
+  lastId int64
+  activities map[int64]*Activity
+  rates []*Rate
+  projects []*Project
+  goals []*Goal
+}
+
+func (db *Memory) Valid() (bool, error) {
+  return true, nil
+}
+
+func (db *Memory) Version() (int, error) {
+  return MemoryVersion, nil
+}
+
+func (db *Memory) Migrate() error {
+  return nil
+}
+
+func (db *Memory) SaveActivity(a *Activity) error {
+  db.mutex.Lock()
+  defer db.mutex.Unlock()
+
+  if a.Id == 0 {
+    db.lastId++
+    a.Id = db.lastId
+  } else if _, ok := db.activities[a.Id]; !ok {
+    return ErrNotFound
+  }
+  if db.activities == nil {
+    db.activities = make(map[int64]*Activity)
+  }
+  db.activities[a.Id] = a.local()
+  return nil
+}
+
+func (db *Memory) FindActivity(id int64) (*Activity, error) {
+  db.mutex.RLock()
+  defer db.mutex.RUnlock()
+
+  activity, ok := db.activities[id]
+  if !ok {
+    return nil, ErrNotFound
+  }
+  return activity.Clone(), nil
+}
+
+func (db *Memory) FindAllActivities() ([]*Activity, error) {
+  return db.findActivities(func(*Activity) bool { return true }), nil
+}
+
+func (db *Memory) FindRunningActivities() ([]*Activity, error) {
+  return db.findActivities(func(a *Activity) bool { return a.IsRunning() }), nil
+}
+
+func (db *Memory) FindActivitiesBetween(lower time.Time, upper time.Time) ([]*Activity, error) {
+  filter := func(a *Activity) bool {
+    return !a.Start.Before(lower) && a.Start.Before(upper)
+  }
+  return db.findActivities(filter), nil
+}
+
+/* copies of the matching activities, by id */
+func (db *Memory) findActivities(filter func(*Activity) bool) (activities []*Activity) {
+  db.mutex.RLock()
+  defer db.mutex.RUnlock()
+
+  for _, activity := range db.activities {
+    if filter(activity) {
+      activities = append(activities, activity.Clone())
+    }
+  }
+  sort.Slice(activities, func(i, j int) bool { return activities[i].Id < activities[j].Id })
+  return
+}
+
+func (db *Memory) DeleteActivity(id int64) error {
+  db.mutex.Lock()
+  defer db.mutex.Unlock()
+
+  if _, ok := db.activities[id]; !ok {
+    return ErrNotFound
+  }
+  delete(db.activities, id)
+  return nil
+}
+
+/* a rate replaces any other rate for the same project and tag */
+func (db *Memory) SaveRate(r *Rate) error {
+  db.mutex.Lock()
+  defer db.mutex.Unlock()
+
+  rate := *r
+  for i, existing := range db.rates {
+    if existing.Project == r.Project && existing.Tag == r.Tag {
+      db.rates[i] = &rate
+      return nil
+    }
+  }
+  db.rates = append(db.rates, &rate)
+  db.sort()
+  return nil
+}
+
+func (db *Memory) FindRates() (rates []*Rate, err error) {
+  db.mutex.RLock()
+  defer db.mutex.RUnlock()
+
+  for _, r := range db.rates {
+    rate := *r
+    rates = append(rates, &rate)
+  }
+  return
+}
+
+func (db *Memory) DeleteRate(project, tag string) error {
+  db.mutex.Lock()
+  defer db.mutex.Unlock()
+
+  for i, rate := range db.rates {
+    if rate.Project == project && rate.Tag == tag {
+      db.rates = append(db.rates[:i], db.rates[i + 1:]...)
+      return nil
+    }
+  }
+  return ErrNotFound
+}
+
+func (db *Memory) SaveProject(p *Project) error {
+  db.mutex.Lock()
+  defer db.mutex.Unlock()
+
+  project := *p
+  for i, existing := range db.projects {
+    if existing.Name == p.Name {
+      db.projects[i] = &project
+      return nil
+    }
+  }
+  db.projects = append(db.projects, &project)
+  db.sort()
+  return nil
+}
+
+func (db *Memory) FindProjects() (projects []*Project, err error) {
+  db.mutex.RLock()
+  defer db.mutex.RUnlock()
+
+  for _, p := range db.projects {
+    project := *p
+    projects = append(projects, &project)
+  }
+  return
+}
+
+/* the project and its activities, rates and goals are renamed together, unless the new name is taken */
+func (db *Memory) RenameProject(old, new string) error {
+  db.mutex.Lock()
+  defer db.mutex.Unlock()
+
+  for _, project := range db.projects {
+    if project.Name == new {
+      return ErrProjectExists
+    }
+  }
+  found := false
+  rename := func(project *string) {
+    if *project == old {
+      *project = new
+      found = true
+    }
+  }
+  for _, activity := range db.activities {
+    rename(&activity.Project)
+  }
+  for _, rate := range db.rates {
+    rename(&rate.Project)
+  }
+  for _, goal := range db.goals {
+    rename(&goal.Project)
+  }
+  for _, project := range db.projects {
+    rename(&project.Name)
+  }
+  if !found {
+    return ErrNotFound
+  }
+  db.sort()
+  return nil
+}
+
+/* a goal replaces any other goal for the same project and tag */
+func (db *Memory) SaveGoal(g *Goal) error {
+  db.mutex.Lock()
+  defer db.mutex.Unlock()
+
+  goal := *g
+  for i, existing := range db.goals {
+    if existing.Project == g.Project && existing.Tag == g.Tag {
+      db.goals[i] = &goal
+      return nil
+    }
+  }
+  db.goals = append(db.goals, &goal)
+  db.sort()
+  return nil
+}
+
+func (db *Memory) FindGoals() (goals []*Goal, err error) {
+  db.mutex.RLock()
+  defer db.mutex.RUnlock()
+
+  for _, g := range db.goals {
+    goal := *g
+    goals = append(goals, &goal)
+  }
+  return
+}
+
+func (db *Memory) DeleteGoal(project, tag string) error {
+  db.mutex.Lock()
+  defer db.mutex.Unlock()
+
+  for i, goal := range db.goals {
+    if goal.Project == project && goal.Tag == tag {
+      db.goals = append(db.goals[:i], db.goals[i + 1:]...)
+      return nil
+    }
+  }
+  return ErrNotFound
+}
+
+/* rates and goals by project and tag, projects by name */
+func (db *Memory) sort() {
+  sort.Slice(db.rates, func(i, j int) bool {
+    if db.rates[i].Project != db.rates[j].Project {
+      return db.rates[i].Project < db.rates[j].Project
+    }
+    return db.rates[i].Tag < db.rates[j].Tag
+  })
+  sort.Slice(db.projects, func(i, j int) bool { return db.projects[i].Name < db.projects[j].Name })
+  sort.Slice(db.goals, func(i, j int) bool {
+    if db.goals[i].Project != db.goals[j].Project {
+      return db.goals[i].Project < db.goals[j].Project
+    }
+    return db.goals[i].Tag < db.goals[j].Tag
+  })
+}
@@ -0,0 +1,32 @@
+package hourglass
+
+import (
+  "sync"
+  "testing"
+)
+
+func TestMemory_Concurrent(t *testing.T) {
+  db := &Memory{}
+  var wg sync.WaitGroup
+  for i := 0; i < 10; i++ {
+    wg.Add(1)
+    go func() {
+      defer wg.Done()
+      for j := 0; j < 10; j++ {
+        db.SaveActivity(&Activity{Name: "foo", Start: when(2013, 4, 26, 9)})
+        db.FindRunningActivities()
+      }
+    }()
+  }
+  wg.Wait()
+
+  activities, _ := db.FindAllActivities()
+  if len(activities) != 100 {
+    t.Fatalf("expected 100 activities, got %d", len(activities))
+  }
+  for i, activity := range activities {
+    if activity.Id != int64(i + 1) {
+      t.Errorf("expected id %d, got %d", i + 1, activity.Id)
+    }
+  }
+}
@@ -0,0 +1,157 @@
+package hourglass
+
+import (
+  "flag"
+  "fmt"
+  "io"
+  "os"
+  "os/signal"
+  "syscall"
+  "time"
+)
+
+/* help messages */
+const (
+  pomodoroHelp = "Usage: %s pomodoro [options] <name|@template> [project] [tag1[, tag2[, ...]]]\n\nWork on an activity in timed sessions with breaks in between\n\nThe activity is started like the start command at the beginning of each work session and stopped at the end. Breaks are logged as activities named break. Press Ctrl-C to stop early."
+)
+
+/* default session lengths */
+const (
+  DefaultPomodoroWork = 25 * time.Minute
+  DefaultPomodoroBreak = 5 * time.Minute
+  DefaultPomodoroLongBreak = 15 * time.Minute
+  DefaultPomodoroLongEvery = 4
+  DefaultPomodoroCycles = 4
+)
+
+/* how often the countdown is redrawn */
+const PomodoroTick = time.Second
+
+/* pomodoro */
+type PomodoroCommand struct {
+  Templates *Templates
+  Strict bool
+  Defaults *DirConfig
+  Work time.Duration
+  Break time.Duration
+  LongBreak time.Duration
+  LongEvery int
+  Cycles int
+
+  /* where the countdown is drawn, standard output by default */
+  Out io.Writer
+  /* stops the timer early, Ctrl-C by default */
+  Interrupt <-chan os.Signal
+}
+
+func (cmd *PomodoroCommand) Flags(fs *flag.FlagSet) {
+  fs.DurationVar(&cmd.Work, "work", DefaultPomodoroWork, "length of each work session")
+  fs.DurationVar(&cmd.Break, "break", DefaultPomodoroBreak, "length of short breaks")
+  fs.DurationVar(&cmd.LongBreak, "long-break", DefaultPomodoroLongBreak, "length of long breaks")
+  fs.IntVar(&cmd.LongEvery, "long-every", DefaultPomodoroLongEvery,
+    "take a long break after this many work sessions")
+  fs.IntVar(&cmd.Cycles, "cycles", DefaultPomodoroCycles, "number of work sessions")
+}
+
+func (cmd PomodoroCommand) Run(c Clock, db Database, args ...string) (output string, err error) {
+  if cmd.Work <= 0 {
+    cmd.Work = DefaultPomodoroWork
+  }
+  if cmd.Break <= 0 {
+    cmd.Break = DefaultPomodoroBreak
+  }
+  if cmd.LongBreak <= 0 {
+    cmd.LongBreak = DefaultPomodoroLongBreak
+  }
+  if cmd.LongEvery <= 0 {
+    cmd.LongEvery = DefaultPomodoroLongEvery
+  }
+  if cmd.Cycles <= 0 {
+    cmd.Cycles = DefaultPomodoroCycles
+  }
+  if cmd.Out == nil {
+    cmd.Out = os.Stdout
+  }
+  if cmd.Interrupt == nil {
+    interrupt := make(chan os.Signal, 1)
+    signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+    defer signal.Stop(interrupt)
+    cmd.Interrupt = interrupt
+  }
+
+  start := StartCommand{Templates: cmd.Templates, Strict: cmd.Strict, Defaults: cmd.Defaults}
+  var worked Duration
+  for cycle := 1; cycle <= cmd.Cycles; cycle++ {
+    var activity *Activity
+    activity, err = start.start(c, db, args...)
+    if err != nil {
+      return
+    }
+    label := fmt.Sprintf("%s %d/%d", activity.Name, cycle, cmd.Cycles)
+    finished := cmd.countdown(c, label, cmd.Work)
+    err = stopActivity(c, db, activity)
+    if err != nil {
+      return
+    }
+    worked += activity.Duration(c)
+    if !finished {
+      output = fmt.Sprintf("interrupted, stopped activity %d after %s of work", activity.Id, worked)
+      return
+    }
+    if cycle == cmd.Cycles {
+      break
+    }
+
+    length, name := cmd.Break, "break"
+    if cycle % cmd.LongEvery == 0 {
+      length, name = cmd.LongBreak, "long break"
+    }
+    breakActivity := &Activity{Name: "break", Start: c.Now()}
+    err = db.SaveActivity(breakActivity)
+    if err != nil {
+      return
+    }
+    finished = cmd.countdown(c, name, length)
+    err = stopActivity(c, db, breakActivity)
+    if err != nil {
+      return
+    }
+    if !finished {
+      output = fmt.Sprintf("interrupted, stopped break after %s of work", worked)
+      return
+    }
+  }
+  output = fmt.Sprintf("finished %d work sessions, %s of work", cmd.Cycles, worked)
+  return
+}
+
+func (PomodoroCommand) Help() string {
+  return pomodoroHelp
+}
+
+/* draw a countdown until it runs out or is interrupted, then ring the bell */
+func (cmd PomodoroCommand) countdown(c Clock, label string, length time.Duration) (finished bool) {
+  for remaining := length; remaining > 0; {
+    fmt.Fprintf(cmd.Out, "\r%s %02d:%02d ", label, int(remaining / time.Minute),
+      int(remaining % time.Minute / time.Second))
+
+    wait := PomodoroTick
+    if remaining < wait {
+      wait = remaining
+    }
+    select {
+    case <-after(c, wait):
+      remaining -= wait
+    case <-cmd.Interrupt:
+      fmt.Fprintln(cmd.Out)
+      return false
+    }
+  }
+  fmt.Fprint(cmd.Out, "\r", label, " done\a\n")
+  return true
+}
+
+func stopActivity(c Clock, db Database, activity *Activity) error {
+  activity.Stop(c.Now())
+  return db.SaveActivity(activity)
+}
@@ -0,0 +1,126 @@
+package hourglass
+
+import (
+  "bytes"
+  "os"
+  "strings"
+  "testing"
+  "time"
+)
+
+/* clock that moves forward whenever something waits on it */
+type tickingClock struct {
+  now *time.Time
+  /* called before each wait */
+  onWait func(now time.Time)
+}
+
+func (c tickingClock) Now() time.Time {
+  return *c.now
+}
+
+func (c tickingClock) Local(t time.Time) time.Time {
+  return t.Local()
+}
+
+func (c tickingClock) Since(t time.Time) time.Duration {
+  return c.now.Sub(t)
+}
+
+func (c tickingClock) After(d time.Duration) <-chan time.Time {
+  if c.onWait != nil {
+    c.onWait(*c.now)
+  }
+  *c.now = c.now.Add(d)
+  ch := make(chan time.Time, 1)
+  ch <- *c.now
+  return ch
+}
+
+func TestPomodoroCommand_Run(t *testing.T) {
+  db := &Memory{}
+  now := when(2013, 4, 26, 9)
+  c := tickingClock{now: &now}
+  out := new(bytes.Buffer)
+  cmd := PomodoroCommand{Work: 25 * time.Second, Break: 5 * time.Second,
+    LongBreak: 15 * time.Second, LongEvery: 2, Cycles: 3, Out: out,
+    Interrupt: make(chan os.Signal)}
+
+  output, err := cmd.Run(c, db, "foo", "bar", "baz")
+  if err != nil {
+    t.Fatal(err)
+  }
+  if output != "finished 3 work sessions, 00h01m of work" {
+    t.Errorf("unexpected output: %q", output)
+  }
+
+  start := when(2013, 4, 26, 9)
+  at := func(seconds int) time.Time {
+    return start.Add(time.Duration(seconds) * time.Second)
+  }
+  expected := []*Activity{
+    &Activity{Id: 1, Name: "foo", Project: "bar", Tags: []string{"baz"}, Start: at(0), End: at(25)},
+    &Activity{Id: 2, Name: "break", Start: at(25), End: at(30)},
+    &Activity{Id: 3, Name: "foo", Project: "bar", Tags: []string{"baz"}, Start: at(30), End: at(55)},
+    &Activity{Id: 4, Name: "break", Start: at(55), End: at(70)},
+    &Activity{Id: 5, Name: "foo", Project: "bar", Tags: []string{"baz"}, Start: at(70), End: at(95)},
+  }
+  activities, _ := db.FindAllActivities()
+  if len(activities) != len(expected) {
+    t.Fatalf("expected %d activities, got %d", len(expected), len(activities))
+  }
+  for i, activity := range expected {
+    if !activity.Equal(activities[i]) {
+      t.Errorf("activity %d: expected %v, got %v", i, activity, activities[i])
+    }
+  }
+
+  for _, label := range []string{"foo 1/3 00:25", "long break 00:15", "foo 3/3 done\a"} {
+    if !strings.Contains(out.String(), label) {
+      t.Errorf("expected countdown to contain %q", label)
+    }
+  }
+}
+
+func TestPomodoroCommand_Run_Interrupted(t *testing.T) {
+  db := &Memory{}
+  now := when(2013, 4, 26, 9)
+  interrupt := make(chan os.Signal, 1)
+  waits := 0
+  c := tickingClock{now: &now, onWait: func(time.Time) {
+    /* Ctrl-C ten minutes in */
+    waits++
+    if waits == 600 {
+      interrupt <- os.Interrupt
+    }
+  }}
+  cmd := PomodoroCommand{Out: new(bytes.Buffer), Interrupt: interrupt}
+
+  output, err := cmd.Run(c, db, "foo")
+  if err != nil {
+    t.Fatal(err)
+  }
+  if output != "interrupted, stopped activity 1 after 00h10m of work" {
+    t.Errorf("unexpected output: %q", output)
+  }
+  activities, _ := db.FindAllActivities()
+  if len(activities) != 1 || activities[0].IsRunning() {
+    t.Errorf("expected one stopped activity, got %v", activities)
+  }
+}
+
+func TestPomodoroCommand_Run_WithoutName(t *testing.T) {
+  now := when(2013, 4, 26, 9)
+  cmd := PomodoroCommand{Out: new(bytes.Buffer), Interrupt: make(chan os.Signal)}
+  _, err := cmd.Run(tickingClock{now: &now}, &Memory{})
+  if _, ok := err.(SyntaxError); !ok {
+    t.Errorf("expected error type SyntaxError, got %T", err)
+  }
+}
+
+func TestPomodoroCommand_Help(t *testing.T) {
+  cmd := PomodoroCommand{}
+  if cmd.Help() == "" {
+    t.Error("no help available")
+  }
+}
@@ -0,0 +1,185 @@
+package hourglass
+
+import (
+  "fmt"
+  "sort"
+  "strings"
+)
+
+/* help messages */
+const (
+  projectHelp = "Usage: %s project <add|list|rename|archive> [arguments]\n\nManage projects\n\n\tadd <name> [description]\n\tlist [all]\n\trename <old> <new>\n\tarchive <name>\n\nProjects used by activities but never added are listed as unregistered. Renaming a project also renames it on every activity. Archived projects are only listed with all."
+)
+
+/* registered project */
+type Project struct {
+  Name string
+  Description string
+  Archived bool
+}
+
+/* find a registered project by name */
+func findProject(db Database, name string) (project *Project, err error) {
+  var projects []*Project
+  projects, err = db.FindProjects()
+  if err != nil {
+    return
+  }
+  for _, p := range projects {
+    if p.Name == name {
+      return p, nil
+    }
+  }
+  return nil, ErrNotFound
+}
+
+/* in strict mode, activities can only use registered projects that aren't archived */
+func checkProject(db Database, name string) error {
+  if name == "" {
+    return nil
+  }
+  project, err := findProject(db, name)
+  if err == ErrNotFound {
+    return fmt.Errorf("unknown project: %s (see project add)", name)
+  } else if err != nil {
+    return err
+  }
+  if project.Archived {
+    return fmt.Errorf("project %s is archived", name)
+  }
+  return nil
+}
+
+/* project */
+type ProjectCommand struct{}
+
+func (ProjectCommand) Run(c Clock, db Database, args ...string) (output string, err error) {
+  if len(args) == 0 {
+    err = SyntaxError("missing subcommand")
+    return
+  }
+
+  switch args[0] {
+  case "add":
+    if len(args) < 2 || args[1] == "" {
+      err = SyntaxError("missing project name")
+      return
+    }
+    _, err = findProject(db, args[1])
+    if err == nil {
+      err = fmt.Errorf("project %s already exists", args[1])
+      return
+    } else if err != ErrNotFound {
+      return
+    }
+    project := &Project{Name: args[1], Description: strings.Join(args[2:], " ")}
+    err = db.SaveProject(project)
+    if err == nil {
+      output = fmt.Sprintf("added project %s", project.Name)
+    }
+
+  case "list":
+    all := len(args) > 1 && args[1] == "all"
+    if len(args) > 2 || len(args) == 2 && !all {
+      err = SyntaxError("invalid arguments")
+      return
+    }
+    output, err = listProjects(db, all)
+
+  case "rename":
+    if len(args) != 3 || args[1] == "" || args[2] == "" {
+      err = SyntaxError("expected old and new project names")
+      return
+    }
+    _, err = findProject(db, args[2])
+    if err == nil {
+      err = fmt.Errorf("project %s already exists", args[2])
+      return
+    } else if err != ErrNotFound {
+      return
+    }
+    err = db.RenameProject(args[1], args[2])
+    if err == nil {
+      output = fmt.Sprintf("renamed project %s to %s", args[1], args[2])
+    }
+
+  case "archive":
+    if len(args) != 2 {
+      err = SyntaxError("missing project name")
+      return
+    }
+    var project *Project
+    project, err = findProject(db, args[1])
+    if err == ErrNotFound {
+      err = fmt.Errorf("project %s doesn't exist", args[1])
+      return
+    } else if err != nil {
+      return
+    }
+    project.Archived = true
+    err = db.SaveProject(project)
+    if err == nil {
+      output = fmt.Sprintf("archived project %s", project.Name)
+    }
+
+  default:
+    err = SyntaxError(fmt.Sprint("invalid subcommand: ", args[0]))
+  }
+  return
+}
+
+func (ProjectCommand) Help() string {
+  return projectHelp
+}
+
+/* registered projects, then ones that are only used by activities */
+func listProjects(db Database, all bool) (output string, err error) {
+  var projects []*Project
+  projects, err = db.FindProjects()
+  if err != nil {
+    return
+  }
+  var activities []*Activity
+  activities, err = db.FindAllActivities()
+  if err != nil {
+    return
+  }
+
+  counts := make(map[string]int)
+  for _, activity := range activities {
+    if activity.Project != "" {
+      counts[activity.Project]++
+    }
+  }
+
+  var lines []string
+  for _, project := range projects {
+    state := "active"
+    if project.Archived {
+      if !all {
+        delete(counts, project.Name)
+        continue
+      }
+      state = "archived"
+    }
+    lines = append(lines, fmt.Sprintf("| %s\t| %s\t| %s\t| %d\t|",
+      project.Name, project.Description, state, counts[project.Name]))
+    delete(counts, project.Name)
+  }
+
+  var unregistered []string
+  for name := range counts {
+    unregistered = append(unregistered, name)
+  }
+  sort.Strings(unregistered)
+  for _, name := range unregistered {
+    lines = append(lines, fmt.Sprintf("| %s\t| \t| unregistered\t| %d\t|", name, counts[name]))
+  }
+
+  if len(lines) == 0 {
+    output = "there aren't any projects"
+    return
+  }
+  output = "| name\t| description\t| state\t| activities\t|\n" + strings.Join(lines, "\n")
+  return
+}
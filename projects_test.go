@@ -0,0 +1,120 @@
+package hourglass
+
+import (
+  "testing"
+)
+
+var projectCommandTests = []struct {
+  args []string
+  output string
+  err bool
+}{
+  {[]string{"add", "foo", "client", "work"}, "added project foo", false},
+  {[]string{"add", "bar"}, "added project bar", false},
+  {[]string{"add", "foo"}, "", true},
+  {
+    []string{"list"},
+    "| name\t| description\t| state\t| activities\t|\n" +
+    "| bar\t| \t| active\t| 0\t|\n" +
+    "| foo\t| client work\t| active\t| 1\t|\n" +
+    "| qux\t| \t| unregistered\t| 1\t|",
+    false,
+  },
+  {[]string{"archive", "bar"}, "archived project bar", false},
+  {[]string{"archive", "junk"}, "", true},
+  {
+    []string{"list"},
+    "| name\t| description\t| state\t| activities\t|\n" +
+    "| foo\t| client work\t| active\t| 1\t|\n" +
+    "| qux\t| \t| unregistered\t| 1\t|",
+    false,
+  },
+  {
+    []string{"list", "all"},
+    "| name\t| description\t| state\t| activities\t|\n" +
+    "| bar\t| \t| archived\t| 0\t|\n" +
+    "| foo\t| client work\t| active\t| 1\t|\n" +
+    "| qux\t| \t| unregistered\t| 1\t|",
+    false,
+  },
+  {[]string{"rename", "foo", "bar"}, "", true},
+  {[]string{"rename", "foo", "baz"}, "renamed project foo to baz", false},
+  {[]string{"rename", "foo"}, "", true},
+  {[]string{"list", "junk"}, "", true},
+  {[]string{"junk"}, "", true},
+  {nil, "", true},
+}
+
+func TestProjectCommand_Run(t *testing.T) {
+  db := &Memory{}
+  db.SaveActivity(&Activity{Name: "a", Project: "foo", Start: when(2013, 4, 26, 8)})
+  db.SaveActivity(&Activity{Name: "b", Project: "qux", Start: when(2013, 4, 26, 9)})
+  c := fakeCmdClock{when(2013, 4, 26, 12)}
+  for i, config := range projectCommandTests {
+    output, err := ProjectCommand{}.Run(c, db, config.args...)
+    if err != nil {
+      if !config.err {
+        t.Errorf("test %d: %s", i, err)
+      }
+      continue
+    } else if config.err {
+      t.Errorf("test %d: expected error, got nil", i)
+      continue
+    }
+    if output != config.output {
+      t.Errorf("test %d: expected %q, got %q", i, config.output, output)
+    }
+  }
+
+  activity, _ := db.FindActivity(1)
+  if activity.Project != "baz" {
+    t.Errorf("expected renamed project on activity, got %q", activity.Project)
+  }
+}
+
+func TestStrictMode(t *testing.T) {
+  db := &Memory{}
+  db.SaveProject(&Project{Name: "foo"})
+  db.SaveProject(&Project{Name: "old", Archived: true})
+  c := fakeCmdClock{when(2013, 4, 26, 12)}
+
+  var tests = []struct {
+    args []string
+    err bool
+  }{
+    {[]string{"a", "foo"}, false},
+    {[]string{"b"}, false},
+    {[]string{"c", "bar"}, true},
+    {[]string{"d", "old"}, true},
+  }
+  for i, config := range tests {
+    _, err := StartCommand{Strict: true}.Run(c, db, config.args...)
+    if err != nil && !config.err {
+      t.Errorf("test %d: %s", i, err)
+    } else if err == nil && config.err {
+      t.Errorf("test %d: expected error, got nil", i)
+    }
+  }
+
+  _, err := EditCommand{Strict: true}.Run(c, db, "1", "project", "bar")
+  if err == nil {
+    t.Error("expected error editing to an unknown project")
+  }
+  _, err = EditCommand{Strict: true}.Run(c, db, "1", "name", "z")
+  if err != nil {
+    t.Error(err)
+  }
+
+  /* without strict mode, any project is fine */
+  _, err = StartCommand{}.Run(c, db, "e", "bar")
+  if err != nil {
+    t.Error(err)
+  }
+}
+
+func TestProjectCommand_Help(t *testing.T) {
+  cmd := ProjectCommand{}
+  if cmd.Help() == "" {
+    t.Error("no help available")
+  }
+}
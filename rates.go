@@ -0,0 +1,242 @@
+package hourglass
+
+import (
+  "fmt"
+  "sort"
+  "strconv"
+  "strings"
+  "time"
+)
+
+/* help messages */
+const (
+  rateHelp = "Usage: %s rate <set|list|delete> [arguments]\n\nManage hourly billing rates\n\n\tset <project>[:<tag>] <amount> <currency>\n\tset <project>[:<tag>] none\n\tlist\n\tdelete <project>[:<tag>]\n\nA rate for a tag applies to activities in the project with that tag, and a rate with an empty project (like :urgent) applies to the tag in any project. Activities with a rate of none aren't billable."
+)
+
+/* currencies without two decimal places */
+var currencyExponents = map[string]int{
+  "BIF": 0, "CLP": 0, "ISK": 0, "JPY": 0, "KRW": 0, "PYG": 0, "VND": 0,
+  "BHD": 3, "JOD": 3, "KWD": 3, "OMR": 3, "TND": 3,
+}
+
+func currencyExponent(currency string) int {
+  if exponent, ok := currencyExponents[currency]; ok {
+    return exponent
+  }
+  return 2
+}
+
+/* amount of money in minor units, like cents */
+type Money struct {
+  Amount int64
+  Currency string
+}
+
+func ParseMoney(amount, currency string) (m Money, err error) {
+  currency = strings.ToUpper(currency)
+  if len(currency) != 3 {
+    err = SyntaxError(fmt.Sprint("invalid currency: ", currency))
+    return
+  }
+  m.Currency = currency
+
+  exponent := currencyExponent(currency)
+  whole, fraction := amount, ""
+  if dot := strings.Index(amount, "."); dot >= 0 {
+    whole, fraction = amount[:dot], amount[dot + 1:]
+  }
+  if len(fraction) > exponent || whole == "" && fraction == "" {
+    err = SyntaxError(fmt.Sprint("invalid amount: ", amount))
+    return
+  }
+  fraction += strings.Repeat("0", exponent - len(fraction))
+
+  m.Amount, err = strconv.ParseInt(whole + fraction, 10, 64)
+  if err != nil || m.Amount < 0 {
+    err = SyntaxError(fmt.Sprint("invalid amount: ", amount))
+  }
+  return
+}
+
+func (m Money) String() string {
+  exponent := currencyExponent(m.Currency)
+  amount := m.Amount
+  sign := ""
+  if amount < 0 {
+    sign, amount = "-", -amount
+  }
+  if exponent == 0 {
+    return fmt.Sprintf("%s%d %s", sign, amount, m.Currency)
+  }
+  divisor := int64(1)
+  for i := 0; i < exponent; i++ {
+    divisor *= 10
+  }
+  return fmt.Sprintf("%s%d.%0*d %s", sign, amount / divisor, exponent, amount % divisor, m.Currency)
+}
+
+/* amounts in several currencies */
+type MoneyList []Money
+
+func (list MoneyList) Add(m Money) MoneyList {
+  for i := range list {
+    if list[i].Currency == m.Currency {
+      list[i].Amount += m.Amount
+      return list
+    }
+  }
+  list = append(list, m)
+  sort.Slice(list, func(i, j int) bool { return list[i].Currency < list[j].Currency })
+  return list
+}
+
+func (list MoneyList) String() string {
+  amounts := make([]string, len(list))
+  for i, m := range list {
+    amounts[i] = m.String()
+  }
+  return strings.Join(amounts, " + ")
+}
+
+/* hourly rate for a project, a tag within a project or a tag anywhere */
+type Rate struct {
+  Project string
+  Tag string
+  Hourly Money
+  Billable bool
+}
+
+func (r *Rate) Target() string {
+  if r.Tag == "" {
+    return r.Project
+  }
+  return r.Project + ":" + r.Tag
+}
+
+/* amount earned over a duration, rounded to the nearest minor unit */
+func (r *Rate) Amount(d Duration) Money {
+  if !r.Billable {
+    return Money{0, r.Hourly.Currency}
+  }
+  seconds := int64(time.Duration(d) / time.Second)
+  return Money{(r.Hourly.Amount * seconds + 1800) / 3600, r.Hourly.Currency}
+}
+
+func parseRateTarget(target string) (project, tag string) {
+  if colon := strings.LastIndex(target, ":"); colon >= 0 {
+    return target[:colon], target[colon + 1:]
+  }
+  return target, ""
+}
+
+/* most specific rate: tag in project, then tag anywhere, then project */
+func rateFor(rates []*Rate, activity *Activity) *Rate {
+  var projectRate, tagRate *Rate
+  for _, rate := range rates {
+    if rate.Tag == "" {
+      if rate.Project == activity.Project {
+        projectRate = rate
+      }
+      continue
+    }
+    for _, tag := range activity.Tags {
+      if tag != rate.Tag {
+        continue
+      }
+      if rate.Project == activity.Project && rate.Project != "" {
+        return rate
+      } else if rate.Project == "" && tagRate == nil {
+        tagRate = rate
+      }
+    }
+  }
+  if tagRate != nil {
+    return tagRate
+  }
+  return projectRate
+}
+
+/* amount earned for an activity, if it's billable */
+func activityAmount(c Clock, rates []*Rate, activity *Activity) (m Money, ok bool) {
+  rate := rateFor(rates, activity)
+  if rate == nil || !rate.Billable {
+    return
+  }
+  return rate.Amount(activity.Duration(c)), true
+}
+
+/* rate */
+type RateCommand struct{}
+
+func (RateCommand) Run(c Clock, db Database, args ...string) (output string, err error) {
+  if len(args) == 0 {
+    err = SyntaxError("missing subcommand")
+    return
+  }
+
+  switch args[0] {
+  case "set":
+    if len(args) != 3 && len(args) != 4 {
+      err = SyntaxError("expected a target and an amount and currency or none")
+      return
+    }
+    rate := &Rate{}
+    rate.Project, rate.Tag = parseRateTarget(args[1])
+    if len(args) == 3 {
+      if args[2] != "none" {
+        err = SyntaxError("missing currency")
+        return
+      }
+    } else {
+      rate.Hourly, err = ParseMoney(args[2], args[3])
+      if err != nil {
+        return
+      }
+      rate.Billable = true
+    }
+    err = db.SaveRate(rate)
+    if err == nil {
+      output = fmt.Sprintf("saved rate for %s", args[1])
+    }
+
+  case "list":
+    var rates []*Rate
+    rates, err = db.FindRates()
+    if err != nil {
+      return
+    }
+    if len(rates) == 0 {
+      output = "there aren't any rates"
+      return
+    }
+    output = "| project\t| tag\t| rate\t|"
+    for _, rate := range rates {
+      hourly := "not billable"
+      if rate.Billable {
+        hourly = rate.Hourly.String() + "/hour"
+      }
+      output += fmt.Sprintf("\n| %s\t| %s\t| %s\t|", rate.Project, rate.Tag, hourly)
+    }
+
+  case "delete":
+    if len(args) != 2 {
+      err = SyntaxError("missing target")
+      return
+    }
+    project, tag := parseRateTarget(args[1])
+    err = db.DeleteRate(project, tag)
+    if err == ErrNotFound {
+      err = fmt.Errorf("there isn't a rate for %s", args[1])
+    } else if err == nil {
+      output = fmt.Sprintf("deleted rate for %s", args[1])
+    }
+
+  default:
+    err = SyntaxError(fmt.Sprint("invalid subcommand: ", args[0]))
+  }
+  return
+}
+
+func (RateCommand) Help() string {
+  return rateHelp
+}
@@ -0,0 +1,179 @@
+package hourglass
+
+import (
+  "testing"
+  "time"
+)
+
+var moneyTests = []struct {
+  amount string
+  currency string
+  minor int64
+  output string
+  err bool
+}{
+  {"120", "usd", 12000, "120.00 USD", false},
+  {"120.5", "USD", 12050, "120.50 USD", false},
+  {".05", "EUR", 5, "0.05 EUR", false},
+  {"1500", "JPY", 1500, "1500 JPY", false},
+  {"1.234", "KWD", 1234, "1.234 KWD", false},
+  {"1.5", "JPY", 0, "", true},
+  {"1.005", "USD", 0, "", true},
+  {"-1", "USD", 0, "", true},
+  {"junk", "USD", 0, "", true},
+  {"", "USD", 0, "", true},
+  {"1", "dollars", 0, "", true},
+}
+
+func TestParseMoney(t *testing.T) {
+  for i, config := range moneyTests {
+    m, err := ParseMoney(config.amount, config.currency)
+    if err != nil {
+      if !config.err {
+        t.Errorf("test %d: %s", i, err)
+      } else if _, ok := err.(SyntaxError); !ok {
+        t.Errorf("test %d: expected error type SyntaxError, got %T", i, err)
+      }
+      continue
+    } else if config.err {
+      t.Errorf("test %d: expected error, got nil", i)
+      continue
+    }
+    if m.Amount != config.minor {
+      t.Errorf("test %d: expected %d, got %d", i, config.minor, m.Amount)
+    }
+    if m.String() != config.output {
+      t.Errorf("test %d: expected %q, got %q", i, config.output, m.String())
+    }
+  }
+}
+
+func TestMoneyList_Add(t *testing.T) {
+  var list MoneyList
+  list = list.Add(Money{100, "USD"})
+  list = list.Add(Money{250, "EUR"})
+  list = list.Add(Money{50, "USD"})
+  if list.String() != "2.50 EUR + 1.50 USD" {
+    t.Errorf("unexpected amounts: %s", list)
+  }
+}
+
+func TestRate_Amount(t *testing.T) {
+  rate := &Rate{Hourly: Money{12000, "USD"}, Billable: true}
+  amount := rate.Amount(Duration(90 * time.Minute))
+  if amount != (Money{18000, "USD"}) {
+    t.Errorf("expected 180.00 USD, got %s", amount)
+  }
+  /* rounded to the nearest cent */
+  amount = rate.Amount(Duration(time.Second))
+  if amount != (Money{3, "USD"}) {
+    t.Errorf("expected 0.03 USD, got %s", amount)
+  }
+}
+
+func TestRateFor(t *testing.T) {
+  rates := []*Rate{
+    &Rate{"", "internal", Money{}, false},
+    &Rate{"", "urgent", Money{20000, "USD"}, true},
+    &Rate{"foo", "", Money{12000, "USD"}, true},
+    &Rate{"foo", "urgent", Money{15000, "USD"}, true},
+  }
+  var tests = []struct {
+    activity *Activity
+    rate *Rate
+  }{
+    {&Activity{Project: "foo"}, rates[2]},
+    {&Activity{Project: "foo", Tags: []string{"urgent"}}, rates[3]},
+    {&Activity{Project: "bar", Tags: []string{"urgent"}}, rates[1]},
+    {&Activity{Project: "foo", Tags: []string{"internal"}}, rates[0]},
+    {&Activity{Project: "bar"}, nil},
+  }
+  for i, config := range tests {
+    rate := rateFor(rates, config.activity)
+    if rate != config.rate {
+      t.Errorf("test %d: expected %+v, got %+v", i, config.rate, rate)
+    }
+  }
+}
+
+var rateCommandTests = []struct {
+  args []string
+  output string
+  err bool
+}{
+  {[]string{"set", "foo", "120", "USD"}, "saved rate for foo", false},
+  {[]string{"set", "foo:urgent", "150.50", "eur"}, "saved rate for foo:urgent", false},
+  {[]string{"set", ":internal", "none"}, "saved rate for :internal", false},
+  {
+    []string{"list"},
+    "| project\t| tag\t| rate\t|\n" +
+    "| \t| internal\t| not billable\t|\n" +
+    "| foo\t| \t| 120.00 USD/hour\t|\n" +
+    "| foo\t| urgent\t| 150.50 EUR/hour\t|",
+    false,
+  },
+  {[]string{"delete", "foo:urgent"}, "deleted rate for foo:urgent", false},
+  {[]string{"delete", "foo:urgent"}, "", true},
+  {[]string{"set", "foo", "120"}, "", true},
+  {[]string{"set", "foo", "abc", "USD"}, "", true},
+  {[]string{"junk"}, "", true},
+  {nil, "", true},
+}
+
+func TestRateCommand_Run(t *testing.T) {
+  db := &Memory{}
+  c := fakeCmdClock{when(2013, 4, 26, 12)}
+  for i, config := range rateCommandTests {
+    output, err := RateCommand{}.Run(c, db, config.args...)
+    if err != nil {
+      if !config.err {
+        t.Errorf("test %d: %s", i, err)
+      }
+      continue
+    } else if config.err {
+      t.Errorf("test %d: expected error, got nil", i)
+      continue
+    }
+    if output != config.output {
+      t.Errorf("test %d: expected %q, got %q", i, config.output, output)
+    }
+  }
+}
+
+func TestListCommand_Run_WithRates(t *testing.T) {
+  db := &Memory{}
+  db.SaveRate(&Rate{"foo", "", Money{12000, "USD"}, true})
+  db.SaveRate(&Rate{"", "internal", Money{}, false})
+  db.SaveActivity(&Activity{Name: "bar", Project: "foo", Start: when(2013, 4, 26, 8),
+    End: when(2013, 4, 26, 10)})
+  db.SaveActivity(&Activity{Name: "baz", Project: "foo", Tags: []string{"internal"},
+    Start: when(2013, 4, 26, 10), End: when(2013, 4, 26, 11)})
+  db.SaveActivity(&Activity{Name: "qux", Start: when(2013, 4, 26, 11)})
+  c := fakeCmdClock{when(2013, 4, 26, 12)}
+
+  output, err := ListCommand{}.Run(c, db)
+  if err != nil {
+    t.Error(err)
+    return
+  }
+  expected := "| id\t| name\t| project\t| tags\t| state\t| start\t| end\t| duration\t|\n" +
+    "| 1\t| bar\t| foo\t| \t| stopped\t| 08:00\t| 10:00\t| 02h00m\t|\n" +
+    "| 2\t| baz\t| foo\t| internal\t| stopped\t| 10:00\t| 11:00\t| 01h00m\t|\n" +
+    "| 3\t| qux\t| \t| \t| running\t| 11:00\t| \t| 01h00m\t|\n" +
+    "foo: 03h00m (240.00 USD), unsorted: 01h00m, earned: 240.00 USD"
+  outputOk, diff, checkErr := checkStringsEqual(expected, output)
+  if !outputOk {
+    if checkErr == nil {
+      t.Errorf("bad output:\n%s", diff)
+    } else {
+      t.Errorf("output didn't match, but couldn't create diff: %s", checkErr)
+    }
+  }
+}
+
+func TestRateCommand_Help(t *testing.T) {
+  cmd := RateCommand{}
+  if cmd.Help() == "" {
+    t.Error("no help available")
+  }
+}
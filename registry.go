@@ -0,0 +1,161 @@
+package hourglass
+
+import (
+  "bytes"
+  "flag"
+  "fmt"
+  "io/ioutil"
+  "strings"
+)
+
+/* help messages */
+const (
+  helpHelp = "Usage: %s help [command]\n\nShow help for a command, or list all commands"
+)
+
+/* commands with options */
+type FlagCommand interface {
+  Command
+  Flags(fs *flag.FlagSet)
+}
+
+/* registry entry */
+type CommandInfo struct {
+  Name string
+  Aliases []string
+  Summary string
+  Hidden bool
+  NoDatabase bool
+  Command Command
+  flags *flag.FlagSet
+}
+
+/* the flag set is created once, since defining flags resets their values */
+func (info *CommandInfo) FlagSet() *flag.FlagSet {
+  if info.flags == nil {
+    info.flags = flag.NewFlagSet(info.Name, flag.ContinueOnError)
+    info.flags.SetOutput(ioutil.Discard)
+    if cmd, ok := info.Command.(FlagCommand); ok {
+      cmd.Flags(info.flags)
+    }
+  }
+  return info.flags
+}
+
+func (info *CommandInfo) HasFlags() (ok bool) {
+  info.FlagSet().VisitAll(func(*flag.Flag) { ok = true })
+  return
+}
+
+/* parse options, then run the command with the remaining arguments */
+func (info *CommandInfo) Run(c Clock, db Database, args ...string) (string, error) {
+  if info.HasFlags() {
+    fs := info.FlagSet()
+    err := fs.Parse(args)
+    if err == flag.ErrHelp {
+      return "", SyntaxError("help requested")
+    } else if err != nil {
+      return "", SyntaxError(err.Error())
+    }
+    args = fs.Args()
+  }
+  return info.Command.Run(c, db, args...)
+}
+
+/* command help followed by its options */
+func (info *CommandInfo) Help() string {
+  help := info.Command.Help()
+  if info.HasFlags() {
+    buf := new(bytes.Buffer)
+    fs := info.FlagSet()
+    fs.SetOutput(buf)
+    fs.PrintDefaults()
+    fs.SetOutput(ioutil.Discard)
+
+    /* help is used as a format string */
+    options := strings.Replace(buf.String(), "%", "%%", -1)
+    help += "\n\nOptions:\n\n" + strings.TrimRight(options, "\n")
+  }
+  if len(info.Aliases) > 0 {
+    help += "\n\nAliases: " + strings.Join(info.Aliases, ", ")
+  }
+  return help
+}
+
+/* command registry */
+type Registry struct {
+  commands []*CommandInfo
+}
+
+func (r *Registry) Register(info *CommandInfo) {
+  r.commands = append(r.commands, info)
+}
+
+func (r *Registry) Lookup(name string) *CommandInfo {
+  for _, info := range r.commands {
+    if info.Name == name {
+      return info
+    }
+    for _, alias := range info.Aliases {
+      if alias == name {
+        return info
+      }
+    }
+  }
+  return nil
+}
+
+/* all commands, in the order they were registered */
+func (r *Registry) Commands() []*CommandInfo {
+  return r.commands
+}
+
+/* names of commands that aren't hidden */
+func (r *Registry) Names() (names []string) {
+  for _, info := range r.commands {
+    if !info.Hidden {
+      names = append(names, info.Name)
+    }
+  }
+  return
+}
+
+/* list of commands for the usage message */
+func (r *Registry) Usage() (usage string) {
+  usage = "Commands:\n"
+  for _, info := range r.commands {
+    if !info.Hidden {
+      usage += fmt.Sprintf("\n\t%s\t%s", info.Name, info.Summary)
+    }
+  }
+  return
+}
+
+/* help */
+type HelpCommand struct {
+  Registry *Registry
+  Program string
+  Usage string
+}
+
+func (cmd HelpCommand) Run(c Clock, db Database, args ...string) (output string, err error) {
+  switch len(args) {
+  case 0:
+    output = fmt.Sprintf(cmd.Usage, cmd.Program, cmd.Registry.Usage())
+    output = strings.TrimRight(output, "\n")
+  case 1:
+    info := cmd.Registry.Lookup(args[0])
+    if info == nil {
+      err = fmt.Errorf("invalid command: %s", args[0])
+      return
+    }
+    output = fmt.Sprintf(info.Help(), cmd.Program)
+  default:
+    err = SyntaxError("too many arguments")
+  }
+  return
+}
+
+func (HelpCommand) Help() string {
+  return helpHelp
+}
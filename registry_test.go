@@ -0,0 +1,173 @@
+package hourglass
+
+import (
+  "testing"
+  "flag"
+  "strings"
+)
+
+/* fake command with options */
+type fakeFlagCommand struct {
+  verbose bool
+  name string
+  args []string
+}
+func (cmd *fakeFlagCommand) Flags(fs *flag.FlagSet) {
+  fs.BoolVar(&cmd.verbose, "verbose", false, "be verbose")
+  fs.StringVar(&cmd.name, "name", "default", "a name with 100% less typing")
+}
+func (cmd *fakeFlagCommand) Run(c Clock, db Database, args ...string) (string, error) {
+  cmd.args = args
+  return strings.Join(args, " "), nil
+}
+func (cmd *fakeFlagCommand) Help() string {
+  return "Usage: %s fake [--verbose] [--name <name>] [args]\n\nDo nothing"
+}
+
+func TestCommandInfo_Run(t *testing.T) {
+  cmd := &fakeFlagCommand{}
+  info := &CommandInfo{Name: "fake", Command: cmd}
+  c := fakeCmdClock{when(2013, 4, 26, 9)}
+
+  output, err := info.Run(c, &Memory{}, "--verbose", "-name", "foo", "bar", "--baz")
+  if err != nil {
+    t.Error(err)
+    return
+  }
+  if !cmd.verbose || cmd.name != "foo" {
+    t.Errorf("flags weren't parsed: %+v", cmd)
+  }
+  if output != "bar --baz" {
+    t.Errorf("expected %q, got %q", "bar --baz", output)
+  }
+}
+
+func TestCommandInfo_Run_WithBadFlag(t *testing.T) {
+  info := &CommandInfo{Name: "fake", Command: &fakeFlagCommand{}}
+  c := fakeCmdClock{when(2013, 4, 26, 9)}
+
+  _, err := info.Run(c, &Memory{}, "--junk")
+  if _, ok := err.(SyntaxError); !ok {
+    t.Errorf("expected error type SyntaxError, got %T", err)
+  }
+}
+
+func TestCommandInfo_Run_WithoutFlags(t *testing.T) {
+  /* commands without options get every argument */
+  info := &CommandInfo{Name: "edit", Command: &EditCommand{}}
+  db := &Memory{}
+  db.SaveActivity(&Activity{Name: "foo"})
+  c := fakeCmdClock{when(2013, 4, 26, 9)}
+
+  _, err := info.Run(c, db, "1", "name", "-bar")
+  if err != nil {
+    t.Error(err)
+    return
+  }
+  if db.activities[1].Name != "-bar" {
+    t.Errorf("expected %q, got %q", "-bar", db.activities[1].Name)
+  }
+}
+
+func TestCommandInfo_Help(t *testing.T) {
+  info := &CommandInfo{Name: "fake", Aliases: []string{"f"}, Command: &fakeFlagCommand{}}
+  help := info.Help()
+  for _, expected := range []string{"Do nothing", "-verbose", "-name", "100%% less", "Aliases: f"} {
+    if !strings.Contains(help, expected) {
+      t.Errorf("expected help to contain %q:\n%s", expected, help)
+    }
+  }
+
+  info = &CommandInfo{Name: "edit", Command: &EditCommand{}}
+  if strings.Contains(info.Help(), "Options:") {
+    t.Errorf("expected no options:\n%s", info.Help())
+  }
+}
+
+func TestRegistry_Lookup(t *testing.T) {
+  r := &Registry{}
+  list := &CommandInfo{Name: "list", Aliases: []string{"ls"}, Command: &ListCommand{}}
+  r.Register(list)
+  r.Register(&CommandInfo{Name: "stop", Command: &StopCommand{}})
+
+  if r.Lookup("list") != list {
+    t.Error("couldn't find command by name")
+  }
+  if r.Lookup("ls") != list {
+    t.Error("couldn't find command by alias")
+  }
+  if r.Lookup("junk") != nil {
+    t.Error("expected nil for unknown command")
+  }
+}
+
+func TestRegistry_Usage(t *testing.T) {
+  r := &Registry{}
+  r.Register(&CommandInfo{Name: "list", Summary: "List activities", Command: &ListCommand{}})
+  r.Register(&CommandInfo{Name: "secret", Hidden: true, Command: &ListCommand{}})
+  r.Register(&CommandInfo{Name: "stop", Summary: "Stop an activity", Command: &StopCommand{}})
+
+  expected := "Commands:\n\n\tlist\tList activities\n\tstop\tStop an activity"
+  if r.Usage() != expected {
+    t.Errorf("expected %q, got %q", expected, r.Usage())
+  }
+  names := r.Names()
+  if len(names) != 2 || names[0] != "list" || names[1] != "stop" {
+    t.Errorf("expected [list stop], got %v", names)
+  }
+}
+
+/* help command tests */
+var helpTests = []struct {
+  args []string
+  output string
+  err bool
+  syntaxErr bool
+}{
+  {nil, "hg: Commands:\n\n\tlist\tList activities", false, false},
+  {
+    []string{"ls"},
+    "Usage: hg list [--budgets] [--user <name> | --all-users] [all|week]\n\nList activities\n\n" +
+    "With --budgets, the week listing warns about budgets that have been exceeded (see goal). " +
+    "Only your own activities are listed unless another user is given with --user or --all-users is used.\n\n" +
+    "Options:\n\n  -all-users\n    \tshow activities by every user\n" +
+    "  -budgets\n    \twarn about exceeded budgets in the week listing\n" +
+    "  -user string\n    \tonly show activities by this user\n\nAliases: ls",
+    false, false,
+  },
+  {[]string{"junk"}, "", true, false},
+  {[]string{"list", "stop"}, "", true, true},
+}
+
+func TestHelpCommand_Run(t *testing.T) {
+  r := &Registry{}
+  r.Register(&CommandInfo{Name: "list", Aliases: []string{"ls"},
+    Summary: "List activities", Command: &ListCommand{}})
+  cmd := HelpCommand{r, "hg", "%s: %s"}
+  c := fakeCmdClock{when(2013, 4, 26, 9)}
+
+  for i, config := range helpTests {
+    output, err := cmd.Run(c, nil, config.args...)
+    if output != config.output {
+      t.Errorf("test %d: expected %q, got %q", i, config.output, output)
+    }
+    if err != nil {
+      if !config.err {
+        t.Errorf("test %d: %s", i, err)
+      } else if config.syntaxErr {
+        if _, ok := err.(SyntaxError); !ok {
+          t.Errorf("test %d: expected error type SyntaxError, got %T", i, err)
+        }
+      }
+    } else if config.err {
+      t.Errorf("test %d: expected error, got nil", i)
+    }
+  }
+}
+
+func TestHelpCommand_Help(t *testing.T) {
+  cmd := HelpCommand{}
+  if cmd.Help() == "" {
+    t.Error("no help available")
+  }
+}
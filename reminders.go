@@ -0,0 +1,384 @@
+package hourglass
+
+import (
+  "bytes"
+  "encoding/json"
+  "flag"
+  "fmt"
+  "io"
+  "net/http"
+  "os"
+  "os/exec"
+  "os/signal"
+  "strings"
+  "syscall"
+  "time"
+)
+
+/* help messages */
+const (
+  remindHelp = "Usage: %s remind [options]\n\nSend reminders once\n\nA reminder is sent when nothing has been running for the idle time during working hours, or when an activity has been running longer than the maximum running time. Reminders ring the terminal bell unless --command or --webhook is given. The command is run by sh with the reminder as $1, and the webhook gets a JSON object like {\"text\": \"...\"}."
+  watchHelp = "Usage: %s watch [options]\n\nKeep checking for reminders until interrupted\n\nEach reminder is only sent once until whatever caused it goes away. See remind for the kinds of reminders."
+)
+
+/* reminder defaults */
+const (
+  DefaultRemindIdle = 30 * time.Minute
+  DefaultRemindHours = "09:00-17:00"
+  DefaultRemindDays = "mon-fri"
+  DefaultWatchInterval = time.Minute
+)
+
+var weekdayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+/* delivers reminders */
+type Notifier interface {
+  Notify(message string) error
+}
+
+/* rings the terminal bell */
+type BellNotifier struct {
+  Out io.Writer
+}
+
+func (n BellNotifier) Notify(message string) (err error) {
+  _, err = fmt.Fprintf(n.Out, "\a%s\n", message)
+  return
+}
+
+/* runs a shell command with the message as $1 */
+type CommandNotifier struct {
+  Command string
+}
+
+func (n CommandNotifier) Notify(message string) (err error) {
+  var out []byte
+  out, err = exec.Command("sh", "-c", n.Command, "hourglass", message).CombinedOutput()
+  if err != nil {
+    err = fmt.Errorf("reminder command failed: %s: %s", err, strings.TrimSpace(string(out)))
+  }
+  return
+}
+
+/* posts the message as JSON */
+type WebhookNotifier struct {
+  URL string
+  Client *http.Client
+}
+
+func (n WebhookNotifier) Notify(message string) (err error) {
+  client := n.Client
+  if client == nil {
+    client = &http.Client{Timeout: 10 * time.Second}
+  }
+  body, _ := json.Marshal(map[string]string{"text": message})
+
+  var resp *http.Response
+  resp, err = client.Post(n.URL, "application/json", bytes.NewReader(body))
+  if err != nil {
+    return
+  }
+  resp.Body.Close()
+  if resp.StatusCode < 200 || resp.StatusCode > 299 {
+    err = fmt.Errorf("reminder webhook returned %s", resp.Status)
+  }
+  return
+}
+
+/* something to remind about, the key identifies it while it lasts */
+type reminder struct {
+  key string
+  message string
+}
+
+/* options shared by remind and watch */
+type ReminderOptions struct {
+  Idle time.Duration
+  MaxRunning time.Duration
+  Hours string
+  Days string
+  Command string
+  Webhook string
+
+  /* replace the notifiers from the options, for testing */
+  Notifiers []Notifier
+  /* where the bell rings, standard output by default */
+  Out io.Writer
+}
+
+func (o *ReminderOptions) flags(fs *flag.FlagSet) {
+  fs.DurationVar(&o.Idle, "idle", DefaultRemindIdle,
+    "remind when nothing has been running for this long, 0 to never")
+  fs.DurationVar(&o.MaxRunning, "max", o.MaxRunning,
+    "remind when an activity has been running for this long")
+  fs.StringVar(&o.Hours, "hours", DefaultRemindHours, "working hours")
+  fs.StringVar(&o.Days, "days", DefaultRemindDays, "working days, like mon-fri or mon,wed,fri")
+  fs.StringVar(&o.Command, "command", "", "shell command to run for each reminder")
+  fs.StringVar(&o.Webhook, "webhook", "", "URL to post each reminder to")
+}
+
+func (o *ReminderOptions) notifiers() []Notifier {
+  if o.Notifiers != nil {
+    return o.Notifiers
+  }
+  var notifiers []Notifier
+  if o.Command != "" {
+    notifiers = append(notifiers, CommandNotifier{o.Command})
+  }
+  if o.Webhook != "" {
+    notifiers = append(notifiers, WebhookNotifier{URL: o.Webhook})
+  }
+  if len(notifiers) == 0 {
+    out := o.Out
+    if out == nil {
+      out = os.Stdout
+    }
+    notifiers = append(notifiers, BellNotifier{out})
+  }
+  return notifiers
+}
+
+/* working hours like 09:00-17:00, as offsets from midnight */
+func parseHours(hours string) (start, end time.Duration, err error) {
+  parts := strings.Split(hours, "-")
+  if len(parts) != 2 {
+    err = SyntaxError(fmt.Sprint("invalid working hours: ", hours))
+    return
+  }
+  var offsets [2]time.Duration
+  for i, part := range parts {
+    t, parseErr := time.Parse(TimeFormat, strings.TrimSpace(part))
+    if parseErr != nil {
+      err = SyntaxError(fmt.Sprint("invalid working hours: ", hours))
+      return
+    }
+    offsets[i] = time.Duration(t.Hour()) * time.Hour + time.Duration(t.Minute()) * time.Minute
+  }
+  start, end = offsets[0], offsets[1]
+  if end <= start {
+    err = SyntaxError(fmt.Sprint("invalid working hours: ", hours))
+  }
+  return
+}
+
+/* working days like mon-fri or mon,wed,fri */
+func parseDays(days string) (set [7]bool, err error) {
+  for _, part := range strings.Split(strings.ToLower(days), ",") {
+    bounds := strings.Split(strings.TrimSpace(part), "-")
+    if len(bounds) > 2 {
+      err = SyntaxError(fmt.Sprint("invalid working days: ", days))
+      return
+    }
+    var indexes [2]int
+    for i := range indexes {
+      indexes[i] = -1
+      bound := bounds[len(bounds) - 1]
+      if i == 0 {
+        bound = bounds[0]
+      }
+      for day, name := range weekdayNames {
+        if name == bound {
+          indexes[i] = day
+        }
+      }
+      if indexes[i] < 0 {
+        err = SyntaxError(fmt.Sprint("invalid working days: ", days))
+        return
+      }
+    }
+    /* ranges can wrap around, like fri-mon */
+    for day := indexes[0]; ; day = (day + 1) % 7 {
+      set[day] = true
+      if day == indexes[1] {
+        break
+      }
+    }
+  }
+  return
+}
+
+/* current reminders */
+func (o *ReminderOptions) check(c Clock, db Database) (reminders []reminder, err error) {
+  if o.Hours == "" {
+    o.Hours = DefaultRemindHours
+  }
+  if o.Days == "" {
+    o.Days = DefaultRemindDays
+  }
+  var start, end time.Duration
+  start, end, err = parseHours(o.Hours)
+  if err != nil {
+    return
+  }
+  var days [7]bool
+  days, err = parseDays(o.Days)
+  if err != nil {
+    return
+  }
+  maxRunning, _ := runningLimits(o.MaxRunning, 0)
+
+  var running []*Activity
+  running, err = db.FindRunningActivities()
+  if err != nil {
+    return
+  }
+  for _, activity := range running {
+    if activity.IsOverdue(c, maxRunning) {
+      reminders = append(reminders, reminder{
+        fmt.Sprint("running:", activity.Id),
+        fmt.Sprintf("activity %d (%s) has been running for %s", activity.Id, activity.Name,
+          activity.Duration(c)),
+      })
+    }
+  }
+  if len(running) > 0 || o.Idle <= 0 {
+    return
+  }
+
+  now := c.Now()
+  lower, upper := dayRange(now)
+  if !days[now.Weekday()] || now.Before(lower.Add(start)) || !now.Before(lower.Add(end)) {
+    return
+  }
+
+  /* idle since the start of the day or the last activity, whichever is later */
+  var activities []*Activity
+  activities, err = db.FindActivitiesBetween(lower, upper)
+  if err != nil {
+    return
+  }
+  since := lower.Add(start)
+  for _, activity := range activities {
+    if activity.End.After(since) {
+      since = activity.End
+    }
+  }
+  if idle := now.Sub(since); idle >= o.Idle {
+    reminders = append(reminders, reminder{
+      "idle", fmt.Sprintf("nothing has been running for %s", Duration(idle)),
+    })
+  }
+  return
+}
+
+/* send a reminder with every notifier */
+func (o *ReminderOptions) send(r reminder) error {
+  errs := &DatabaseErrors{}
+  for _, notifier := range o.notifiers() {
+    err := notifier.Notify(r.message)
+    if err != nil {
+      errs.Append(err)
+    }
+  }
+  if errs.IsEmpty() {
+    return nil
+  }
+  return errs
+}
+
+/* remind */
+type RemindCommand struct {
+  ReminderOptions
+}
+
+func (cmd *RemindCommand) Flags(fs *flag.FlagSet) {
+  cmd.flags(fs)
+}
+
+func (cmd RemindCommand) Run(c Clock, db Database, args ...string) (output string, err error) {
+  if len(args) > 0 {
+    err = SyntaxError("too many arguments")
+    return
+  }
+
+  var reminders []reminder
+  reminders, err = cmd.check(c, db)
+  if err != nil {
+    return
+  }
+  for _, r := range reminders {
+    err = cmd.send(r)
+    if err != nil {
+      return
+    }
+  }
+  if len(reminders) == 0 {
+    output = "nothing to remind about"
+  } else {
+    output = fmt.Sprintf("sent %d reminders", len(reminders))
+  }
+  return
+}
+
+func (RemindCommand) Help() string {
+  return remindHelp
+}
+
+/* watch */
+type WatchCommand struct {
+  ReminderOptions
+  Interval time.Duration
+
+  /* stops watching, Ctrl-C by default */
+  Interrupt <-chan os.Signal
+}
+
+func (cmd *WatchCommand) Flags(fs *flag.FlagSet) {
+  cmd.flags(fs)
+  fs.DurationVar(&cmd.Interval, "interval", DefaultWatchInterval, "how often to check")
+}
+
+func (cmd WatchCommand) Run(c Clock, db Database, args ...string) (output string, err error) {
+  if len(args) > 0 {
+    err = SyntaxError("too many arguments")
+    return
+  }
+  if cmd.Interval <= 0 {
+    cmd.Interval = DefaultWatchInterval
+  }
+  if cmd.Out == nil {
+    cmd.Out = os.Stdout
+  }
+  if cmd.Interrupt == nil {
+    interrupt := make(chan os.Signal, 1)
+    signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+    defer signal.Stop(interrupt)
+    cmd.Interrupt = interrupt
+  }
+
+  /* reminders already sent, until they go away */
+  sent := make(map[string]bool)
+  count := 0
+  for {
+    var reminders []reminder
+    reminders, err = cmd.check(c, db)
+    if err != nil {
+      return
+    }
+    current := make(map[string]bool)
+    for _, r := range reminders {
+      current[r.key] = true
+      if sent[r.key] {
+        continue
+      }
+      /* a failed reminder shouldn't stop the watch */
+      sendErr := cmd.send(r)
+      if sendErr != nil {
+        fmt.Fprintln(cmd.Out, sendErr)
+      }
+      count++
+    }
+    sent = current
+
+    select {
+    case <-after(c, cmd.Interval):
+    case <-cmd.Interrupt:
+      output = fmt.Sprintf("stopped watching after %d reminders", count)
+      return
+    }
+  }
+}
+
+func (WatchCommand) Help() string {
+  return watchHelp
+}
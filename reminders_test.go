@@ -0,0 +1,243 @@
+package hourglass
+
+import (
+  "encoding/json"
+  "io/ioutil"
+  "net/http"
+  "net/http/httptest"
+  "os"
+  "path/filepath"
+  "testing"
+  "time"
+)
+
+/* records reminders instead of sending them */
+type fakeNotifier struct {
+  messages []string
+}
+
+func (n *fakeNotifier) Notify(message string) error {
+  n.messages = append(n.messages, message)
+  return nil
+}
+
+var parseDaysTests = []struct {
+  days string
+  expected [7]bool
+  err bool
+}{
+  {"mon-fri", [7]bool{false, true, true, true, true, true, false}, false},
+  {"fri-mon", [7]bool{true, true, false, false, false, true, true}, false},
+  {"Mon,wed, sat", [7]bool{false, true, false, true, false, false, true}, false},
+  {"sun", [7]bool{true, false, false, false, false, false, false}, false},
+  {"monday", [7]bool{}, true},
+  {"mon-tue-wed", [7]bool{}, true},
+  {"", [7]bool{}, true},
+}
+
+func TestParseDays(t *testing.T) {
+  for i, config := range parseDaysTests {
+    days, err := parseDays(config.days)
+    if err != nil {
+      if !config.err {
+        t.Errorf("test %d: %s", i, err)
+      }
+      continue
+    } else if config.err {
+      t.Errorf("test %d: expected error, got nil", i)
+      continue
+    }
+    if days != config.expected {
+      t.Errorf("test %d: expected %v, got %v", i, config.expected, days)
+    }
+  }
+}
+
+func TestParseHours(t *testing.T) {
+  start, end, err := parseHours("08:30-17:00")
+  if err != nil {
+    t.Error(err)
+  } else if start != 8 * time.Hour + 30 * time.Minute || end != 17 * time.Hour {
+    t.Errorf("unexpected working hours: %s to %s", start, end)
+  }
+  for _, hours := range []string{"17:00-08:30", "8am-5pm", "09:00"} {
+    _, _, err = parseHours(hours)
+    if _, ok := err.(SyntaxError); !ok {
+      t.Errorf("%s: expected error type SyntaxError, got %T", hours, err)
+    }
+  }
+}
+
+var remindTests = []struct {
+  now time.Time
+  activities []*Activity
+  messages []string
+}{
+  /* test 0: nothing since the start of the working day */
+  {when(2013, 4, 26, 12), nil, []string{"nothing has been running for 03h00m"}},
+
+  /* test 1: idle since the last activity */
+  {
+    when(2013, 4, 26, 12),
+    []*Activity{&Activity{Name: "foo", Start: when(2013, 4, 26, 9), End: when(2013, 4, 26, 11)}},
+    []string{"nothing has been running for 01h00m"},
+  },
+
+  /* test 2: not idle for long enough */
+  {
+    when(2013, 4, 26, 11).Add(20 * time.Minute),
+    []*Activity{&Activity{Name: "foo", Start: when(2013, 4, 26, 9), End: when(2013, 4, 26, 11)}},
+    nil,
+  },
+
+  /* test 3: outside working hours */
+  {when(2013, 4, 26, 8), nil, nil},
+  {when(2013, 4, 26, 17), nil, nil},
+
+  /* test 5: weekends */
+  {when(2013, 4, 27, 12), nil, nil},
+
+  /* test 6: running too long, any time */
+  {
+    when(2013, 4, 27, 20),
+    []*Activity{&Activity{Name: "foo", Start: when(2013, 4, 27, 8)}},
+    []string{"activity 1 (foo) has been running for 12h00m"},
+  },
+
+  /* test 7: running normally */
+  {when(2013, 4, 26, 12), []*Activity{&Activity{Name: "foo", Start: when(2013, 4, 26, 9)}}, nil},
+}
+
+func TestRemindCommand_Run(t *testing.T) {
+  for i, config := range remindTests {
+    db := &Memory{}
+    for _, activity := range config.activities {
+      db.SaveActivity(activity)
+    }
+    notifier := &fakeNotifier{}
+    cmd := RemindCommand{ReminderOptions{Idle: 30 * time.Minute, Notifiers: []Notifier{notifier}}}
+
+    output, err := cmd.Run(fakeCmdClock{config.now}, db)
+    if err != nil {
+      t.Errorf("test %d: %s", i, err)
+      continue
+    }
+    if len(notifier.messages) != len(config.messages) {
+      t.Errorf("test %d: expected %q, got %q", i, config.messages, notifier.messages)
+      continue
+    }
+    for j, message := range config.messages {
+      if notifier.messages[j] != message {
+        t.Errorf("test %d: expected %q, got %q", i, message, notifier.messages[j])
+      }
+    }
+    expected := "nothing to remind about"
+    if len(config.messages) > 0 {
+      expected = "sent 1 reminders"
+    }
+    if output != expected {
+      t.Errorf("test %d: expected %q, got %q", i, expected, output)
+    }
+  }
+}
+
+func TestWatchCommand_Run(t *testing.T) {
+  db := &Memory{}
+  now := when(2013, 4, 26, 12)
+  interrupt := make(chan os.Signal, 1)
+  waits := 0
+  c := tickingClock{now: &now, onWait: func(now time.Time) {
+    waits++
+    switch waits {
+    case 3:
+      /* the idle reminder goes away once something is running */
+      db.SaveActivity(&Activity{Name: "foo", Start: now})
+    case 6:
+      interrupt <- os.Interrupt
+    }
+  }}
+  notifier := &fakeNotifier{}
+  cmd := WatchCommand{ReminderOptions: ReminderOptions{Idle: 30 * time.Minute,
+    MaxRunning: 2 * time.Minute, Notifiers: []Notifier{notifier}}, Interrupt: interrupt}
+
+  output, err := cmd.Run(c, db)
+  if err != nil {
+    t.Fatal(err)
+  }
+  expected := []string{
+    "nothing has been running for 03h00m",
+    "activity 1 (foo) has been running for 00h03m",
+  }
+  if len(notifier.messages) != len(expected) {
+    t.Fatalf("expected %q, got %q", expected, notifier.messages)
+  }
+  for i, message := range expected {
+    if notifier.messages[i] != message {
+      t.Errorf("expected %q, got %q", message, notifier.messages[i])
+    }
+  }
+  if output != "stopped watching after 2 reminders" {
+    t.Errorf("unexpected output: %q", output)
+  }
+}
+
+func TestCommandNotifier_Notify(t *testing.T) {
+  dir, err := ioutil.TempDir("", "hourglass")
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer os.RemoveAll(dir)
+  filename := filepath.Join(dir, "reminder")
+
+  n := CommandNotifier{`printf '%s' "$1" > ` + filename}
+  err = n.Notify("time's up")
+  if err != nil {
+    t.Fatal(err)
+  }
+  data, _ := ioutil.ReadFile(filename)
+  if string(data) != "time's up" {
+    t.Errorf("expected the message as $1, got %q", data)
+  }
+
+  err = CommandNotifier{"exit 1"}.Notify("foo")
+  if err == nil {
+    t.Error("expected error from failing command")
+  }
+}
+
+func TestWebhookNotifier_Notify(t *testing.T) {
+  var body map[string]string
+  server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    json.NewDecoder(r.Body).Decode(&body)
+    if body["text"] == "fail" {
+      w.WriteHeader(http.StatusInternalServerError)
+    }
+  }))
+  defer server.Close()
+
+  err := WebhookNotifier{URL: server.URL}.Notify("time's up")
+  if err != nil {
+    t.Fatal(err)
+  }
+  if body["text"] != "time's up" {
+    t.Errorf("unexpected body: %v", body)
+  }
+  err = WebhookNotifier{URL: server.URL}.Notify("fail")
+  if err == nil {
+    t.Error("expected error from failing webhook")
+  }
+}
+
+func TestRemindCommand_Help(t *testing.T) {
+  cmd := RemindCommand{}
+  if cmd.Help() == "" {
+    t.Error("no help available")
+  }
+}
+
+func TestWatchCommand_Help(t *testing.T) {
+  cmd := WatchCommand{}
+  if cmd.Help() == "" {
+    t.Error("no help available")
+  }
+}
@@ -0,0 +1,537 @@
+package hourglass
+
+import (
+  "encoding/json"
+  "flag"
+  "fmt"
+  "io"
+  "mime"
+  "net/http"
+  "net/url"
+  "strconv"
+  "strings"
+  "time"
+)
+
+/* help messages */
+const (
+  serveHelp = "Usage: %s serve [--addr <host:port>]\n\nServe a web dashboard and a JSON API for activities\n\nEndpoints:\n\tGET /?range=<today|week>\n\tGET /activities?range=<today|week|all>\n\tGET /activities?from=<date>&to=<date>\n\tPOST /activities\n\tPOST /activities/stop\n\tGET /activities/<id>\n\tPATCH /activities/<id>\n\tDELETE /activities/<id>\n\tPOST /activities/<id>/restart\n\tGET /report?range=<today|week|all>\n\nRequests that change activities must send a Content-Type of application/json and come from the same site. Dates are formatted like 2006-01-02. PATCH takes any of name, project, tags, start and end, and an end of null resumes a stopped activity."
+)
+
+const DefaultAddr = "localhost:8080"
+
+/* json representation of an activity */
+type activityJSON struct {
+  Id int64 `json:"id"`
+  Name string `json:"name"`
+  Project string `json:"project"`
+  Tags []string `json:"tags"`
+  Start time.Time `json:"start"`
+  End *time.Time `json:"end,omitempty"`
+  Pauses []pauseJSON `json:"pauses,omitempty"`
+  Status string `json:"status"`
+  Duration string `json:"duration"`
+  Seconds int64 `json:"seconds"`
+  Notes string `json:"notes,omitempty"`
+  User string `json:"user,omitempty"`
+}
+
+type pauseJSON struct {
+  Start time.Time `json:"start"`
+  End *time.Time `json:"end,omitempty"`
+}
+
+func newActivityJSON(c Clock, a *Activity) *activityJSON {
+  result := &activityJSON{
+    Id: a.Id, Name: a.Name, Project: a.Project, Tags: a.Tags,
+    Start: a.Start, Status: a.Status(), Notes: a.Notes, User: a.User,
+  }
+  if result.Tags == nil {
+    result.Tags = []string{}
+  }
+  if !a.End.IsZero() {
+    end := a.End
+    result.End = &end
+  }
+  for _, pause := range a.Pauses {
+    p := pauseJSON{Start: pause.Start}
+    if !pause.End.IsZero() {
+      end := pause.End
+      p.End = &end
+    }
+    result.Pauses = append(result.Pauses, p)
+  }
+  duration := a.Duration(c)
+  result.Duration = duration.String()
+  result.Seconds = int64(time.Duration(duration) / time.Second)
+  return result
+}
+
+/* request bodies */
+type startRequest struct {
+  Name string `json:"name"`
+  Project string `json:"project"`
+  Tags []string `json:"tags"`
+  Last bool `json:"last"`
+}
+
+type stopRequest struct {
+  At string `json:"at"`
+}
+
+type editRequest struct {
+  Name *string `json:"name"`
+  Project *string `json:"project"`
+  Tags *[]string `json:"tags"`
+  Start *string `json:"start"`
+  /* null clears the end, resuming the activity */
+  End json.RawMessage `json:"end"`
+}
+
+/* report response */
+type projectJSON struct {
+  Project string `json:"project"`
+  Duration string `json:"duration"`
+  Seconds int64 `json:"seconds"`
+  Earned string `json:"earned,omitempty"`
+}
+
+type reportJSON struct {
+  Projects []projectJSON `json:"projects"`
+  Duration string `json:"duration"`
+  Seconds int64 `json:"seconds"`
+  Earned string `json:"earned,omitempty"`
+}
+
+/* database wrapper that remembers what a command saved */
+type recordingDb struct {
+  Database
+  saved []*Activity
+}
+
+func (db *recordingDb) SaveActivity(a *Activity) error {
+  err := db.Database.SaveActivity(a)
+  if err == nil {
+    db.saved = append(db.saved, a)
+  }
+  return err
+}
+
+func (db *recordingDb) RestartActivity(a *Activity) error {
+  err := saveRestart(db.Database, a)
+  if err == nil {
+    db.saved = append(db.saved, a)
+  }
+  return err
+}
+
+/* http handler for the json api */
+type Server struct {
+  Clock Clock
+  Database Database
+  Templates *Templates
+  /* only allow registered projects */
+  Strict bool
+  /* limits for stopping at max or eod */
+  MaxRunning time.Duration
+  EndOfDay time.Duration
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+  parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+  /* browsers send form posts to any site, but not json without asking first */
+  if parts[0] == "activities" && r.Method != "GET" {
+    if crossSite(r) {
+      s.writeError(w, http.StatusForbidden, "cross-site request")
+      return
+    }
+    if r.Method != "DELETE" && !isJSON(r) {
+      s.writeError(w, http.StatusUnsupportedMediaType, "content type must be application/json")
+      return
+    }
+  }
+
+  switch {
+  case parts[0] == "" && len(parts) == 1:
+    if r.Method == "GET" {
+      s.dashboard(w, r, http.StatusOK, "")
+    } else {
+      s.methodNotAllowed(w, "GET")
+    }
+
+  case parts[0] == "ui" && len(parts) == 2 && (parts[1] == "start" || parts[1] == "stop"):
+    if r.Method != "POST" {
+      s.methodNotAllowed(w, "POST")
+    } else if crossSite(r) {
+      s.dashboard(w, r, http.StatusForbidden, "cross-site request")
+    } else if parts[1] == "start" {
+      s.uiStart(w, r)
+    } else {
+      s.uiStop(w, r)
+    }
+
+  case parts[0] == "activities" && len(parts) == 1:
+    switch r.Method {
+    case "GET":
+      s.listActivities(w, r)
+    case "POST":
+      s.startActivity(w, r)
+    default:
+      s.methodNotAllowed(w, "GET, POST")
+    }
+
+  case parts[0] == "activities" && len(parts) == 2 && parts[1] == "stop":
+    if r.Method == "POST" {
+      s.stopActivities(w, r)
+    } else {
+      s.methodNotAllowed(w, "POST")
+    }
+
+  case parts[0] == "activities" && len(parts) == 2:
+    switch r.Method {
+    case "GET":
+      s.showActivity(w, r, parts[1])
+    case "PATCH":
+      s.editActivity(w, r, parts[1])
+    case "DELETE":
+      s.deleteActivity(w, r, parts[1])
+    default:
+      s.methodNotAllowed(w, "GET, PATCH, DELETE")
+    }
+
+  case parts[0] == "activities" && len(parts) == 3 && parts[2] == "restart":
+    if r.Method == "POST" {
+      s.restartActivity(w, r, parts[1])
+    } else {
+      s.methodNotAllowed(w, "POST")
+    }
+
+  case parts[0] == "report" && len(parts) == 1:
+    if r.Method == "GET" {
+      s.report(w, r)
+    } else {
+      s.methodNotAllowed(w, "GET")
+    }
+
+  default:
+    s.writeError(w, http.StatusNotFound, "not found")
+  }
+}
+
+func (s *Server) listActivities(w http.ResponseWriter, r *http.Request) {
+  activities, err := s.findActivities(r)
+  if err != nil {
+    s.writeCommandError(w, err)
+    return
+  }
+  s.writeActivities(w, http.StatusOK, activities)
+}
+
+func (s *Server) startActivity(w http.ResponseWriter, r *http.Request) {
+  var req startRequest
+  if !s.readJSON(w, r, &req) {
+    return
+  }
+
+  var args []string
+  if !req.Last {
+    if req.Name == "" {
+      s.writeError(w, http.StatusBadRequest, "name is required")
+      return
+    }
+    args = append(args, req.Name)
+  }
+  if req.Project != "" || len(req.Tags) > 0 {
+    args = append(append(args, req.Project), req.Tags...)
+  }
+  cmd := StartCommand{Templates: s.Templates, Last: req.Last, Strict: s.Strict}
+  s.runCommand(w, http.StatusCreated, cmd, args...)
+}
+
+func (s *Server) stopActivities(w http.ResponseWriter, r *http.Request) {
+  var req stopRequest
+  if !s.readJSON(w, r, &req) {
+    return
+  }
+  s.runCommand(w, http.StatusOK, StopCommand{At: req.At, MaxRunning: s.MaxRunning, EndOfDay: s.EndOfDay})
+}
+
+func (s *Server) showActivity(w http.ResponseWriter, r *http.Request, idString string) {
+  id, err := strconv.ParseInt(idString, 10, 64)
+  if err != nil {
+    s.writeError(w, http.StatusBadRequest, "invalid id")
+    return
+  }
+
+  var activity *Activity
+  activity, err = s.Database.FindActivity(id)
+  if err != nil {
+    s.writeCommandError(w, err)
+    return
+  }
+  s.writeJSON(w, http.StatusOK, newActivityJSON(s.Clock, activity))
+}
+
+func (s *Server) editActivity(w http.ResponseWriter, r *http.Request, id string) {
+  var req editRequest
+  if !s.readJSON(w, r, &req) {
+    return
+  }
+
+  /* every field is checked before the activity is saved */
+  var edits [][]string
+  if req.Name != nil && *req.Name == "" {
+    s.writeError(w, http.StatusBadRequest, "name is required")
+    return
+  } else if req.Name != nil {
+    edits = append(edits, []string{"name", *req.Name})
+  }
+  if req.Project != nil {
+    edits = append(edits, []string{"project", *req.Project})
+  }
+  if req.Tags != nil {
+    edits = append(edits, append([]string{"tags"}, *req.Tags...))
+  }
+  if req.Start != nil {
+    edits = append(edits, []string{"start", *req.Start})
+  }
+  clearEnd := string(req.End) == "null"
+  if len(req.End) > 0 && !clearEnd {
+    var end string
+    if json.Unmarshal(req.End, &end) != nil {
+      s.writeError(w, http.StatusBadRequest, "end must be a date or null")
+      return
+    }
+    edits = append(edits, []string{"end", end})
+  }
+  if len(edits) == 0 && !clearEnd {
+    s.writeError(w, http.StatusBadRequest, "nothing to edit")
+    return
+  }
+
+  activityId, err := strconv.ParseInt(id, 10, 64)
+  if err != nil {
+    s.writeError(w, http.StatusBadRequest, "invalid id")
+    return
+  }
+  var activity *Activity
+  activity, err = s.Database.FindActivity(activityId)
+  if err != nil {
+    s.writeCommandError(w, err)
+    return
+  }
+
+  /* a copy, in case the database shares it */
+  activity = activity.Clone()
+  cmd := EditCommand{Strict: s.Strict}
+  for _, edit := range edits {
+    err = cmd.edit(s.Database, activity, edit[0], edit[1:])
+    if err != nil {
+      s.writeCommandError(w, err)
+      return
+    }
+  }
+  if clearEnd {
+    activity.End = time.Time{}
+  }
+
+  err = s.Database.SaveActivity(activity)
+  if err != nil {
+    s.writeCommandError(w, err)
+    return
+  }
+  s.writeJSON(w, http.StatusOK, newActivityJSON(s.Clock, activity))
+}
+
+func (s *Server) deleteActivity(w http.ResponseWriter, r *http.Request, id string) {
+  _, err := DeleteCommand{}.Run(s.Clock, s.Database, id)
+  if err != nil {
+    s.writeCommandError(w, err)
+    return
+  }
+  w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) restartActivity(w http.ResponseWriter, r *http.Request, id string) {
+  s.runCommand(w, http.StatusCreated, RestartCommand{}, id)
+}
+
+func (s *Server) report(w http.ResponseWriter, r *http.Request) {
+  activities, err := s.findActivities(r)
+  if err != nil {
+    s.writeCommandError(w, err)
+    return
+  }
+
+  var rates []*Rate
+  rates, err = s.Database.FindRates()
+  if err != nil {
+    s.writeCommandError(w, err)
+    return
+  }
+
+  projects, total, earned := projectTotals(s.Clock, rates, activities)
+  report := &reportJSON{Projects: projects, Earned: earned.String()}
+  report.Duration = total.String()
+  report.Seconds = int64(time.Duration(total) / time.Second)
+  s.writeJSON(w, http.StatusOK, report)
+}
+
+/* per-project durations and amounts, unsorted last */
+func projectTotals(c Clock, rates []*Rate, activities []*Activity) (projects []projectJSON, total Duration, earned MoneyList) {
+  totals := newProjectDurationList()
+  for _, activity := range activities {
+    duration := activity.Duration(c)
+    totals.add(activity.Project, duration)
+    total += duration
+    if amount, ok := activityAmount(c, rates, activity); ok {
+      totals.addAmount(activity.Project, amount)
+      earned = earned.Add(amount)
+    }
+  }
+
+  projects = []projectJSON{}
+  for _, pd := range totals.slice {
+    projects = append(projects, projectJSON{pd.name, pd.duration.String(),
+      int64(time.Duration(pd.duration) / time.Second), pd.amounts.String()})
+  }
+  return
+}
+
+/* activities in the range given by the query string, today by default */
+func (s *Server) findActivities(r *http.Request) (activities []*Activity, err error) {
+  query := r.URL.Query()
+  args := []string{query.Get("range")}
+  if query.Get("from") != "" || query.Get("to") != "" {
+    args = []string{query.Get("from"), query.Get("to")}
+  } else if args[0] == "" {
+    args[0] = "today"
+  }
+
+  var lower, upper time.Time
+  lower, upper, err = parseRange(s.Clock.Now(), args)
+  if err != nil {
+    return
+  }
+  activities, err = findActivitiesInRange(s.Database, lower, upper)
+  return
+}
+
+/* run a command and respond with the activities it saved */
+func (s *Server) runCommand(w http.ResponseWriter, status int, cmd Command, args ...string) {
+  db := &recordingDb{Database: s.Database}
+  _, err := cmd.Run(s.Clock, db, args...)
+  if err != nil {
+    s.writeCommandError(w, err)
+    return
+  }
+
+  if status == http.StatusCreated && len(db.saved) == 1 {
+    s.writeJSON(w, status, newActivityJSON(s.Clock, db.saved[0]))
+  } else {
+    s.writeActivities(w, status, db.saved)
+  }
+}
+
+/* whether a browser says the request came from another site */
+func crossSite(r *http.Request) bool {
+  switch r.Header.Get("Sec-Fetch-Site") {
+  case "", "same-origin", "none":
+  default:
+    return true
+  }
+  if origin := r.Header.Get("Origin"); origin != "" {
+    u, err := url.Parse(origin)
+    return err != nil || u.Host != r.Host
+  }
+  return false
+}
+
+func isJSON(r *http.Request) bool {
+  mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+  return err == nil && mediaType == "application/json"
+}
+
+/* an empty body is the same as an empty object */
+func (s *Server) readJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+  err := json.NewDecoder(r.Body).Decode(v)
+  if err != nil && err != io.EOF {
+    s.writeError(w, http.StatusBadRequest, fmt.Sprint("invalid json: ", err))
+    return false
+  }
+  return true
+}
+
+func (s *Server) writeActivities(w http.ResponseWriter, status int, activities []*Activity) {
+  result := make([]*activityJSON, len(activities))
+  for i, activity := range activities {
+    result[i] = newActivityJSON(s.Clock, activity)
+  }
+  s.writeJSON(w, status, result)
+}
+
+func (s *Server) writeJSON(w http.ResponseWriter, status int, v interface{}) {
+  w.Header().Set("Content-Type", "application/json")
+  w.WriteHeader(status)
+  json.NewEncoder(w).Encode(v)
+}
+
+func (s *Server) writeError(w http.ResponseWriter, status int, message string) {
+  s.writeJSON(w, status, map[string]string{"error": message})
+}
+
+/* map errors from commands and databases to status codes */
+func commandErrorStatus(err error) int {
+  switch err.(type) {
+  case SyntaxError:
+    return http.StatusBadRequest
+  default:
+    if err == ErrNotFound {
+      return http.StatusNotFound
+    }
+    return http.StatusInternalServerError
+  }
+}
+
+func (s *Server) writeCommandError(w http.ResponseWriter, err error) {
+  s.writeError(w, commandErrorStatus(err), err.Error())
+}
+
+func (s *Server) methodNotAllowed(w http.ResponseWriter, allowed string) {
+  w.Header().Set("Allow", allowed)
+  s.writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+}
+
+/* serve */
+type ServeCommand struct {
+  Addr string
+  Templates *Templates
+  Strict bool
+  MaxRunning time.Duration
+  EndOfDay time.Duration
+}
+
+func (cmd *ServeCommand) Flags(fs *flag.FlagSet) {
+  fs.StringVar(&cmd.Addr, "addr", DefaultAddr, "address to listen on")
+}
+
+func (cmd ServeCommand) Run(c Clock, db Database, args ...string) (output string, err error) {
+  if len(args) > 0 {
+    err = SyntaxError("too many arguments")
+    return
+  }
+  addr := cmd.Addr
+  if addr == "" {
+    addr = DefaultAddr
+  }
+
+  server := &Server{Clock: c, Database: db, Templates: cmd.Templates, Strict: cmd.Strict,
+    MaxRunning: cmd.MaxRunning, EndOfDay: cmd.EndOfDay}
+  err = http.ListenAndServe(addr, server)
+  return
+}
+
+func (ServeCommand) Help() string {
+  return serveHelp
+}
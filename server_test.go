@@ -0,0 +1,425 @@
+package hourglass
+
+import (
+  "testing"
+  "encoding/json"
+  "net/http"
+  "net/http/httptest"
+  "strings"
+  "time"
+)
+
+func serverTestRequest(s *Server, method, path, body string) *httptest.ResponseRecorder {
+  req := httptest.NewRequest(method, path, strings.NewReader(body))
+  req.Header.Set("Content-Type", "application/json")
+  w := httptest.NewRecorder()
+  s.ServeHTTP(w, req)
+  return w
+}
+
+func newTestServer(activities ...*Activity) (*Server, *Memory) {
+  db := &Memory{}
+  for _, activity := range activities {
+    db.SaveActivity(activity)
+  }
+  return &Server{Clock: fakeCmdClock{when(2013, 4, 26, 12)}, Database: db}, db
+}
+
+var serverStatusTests = []struct {
+  method string
+  path string
+  body string
+  status int
+}{
+  /* test 0: unknown path */
+  {"GET", "/junk", "", http.StatusNotFound},
+
+  /* test 1: wrong method */
+  {"PUT", "/activities", "", http.StatusMethodNotAllowed},
+
+  /* test 2: bad range */
+  {"GET", "/activities?range=junk", "", http.StatusBadRequest},
+
+  /* test 3: bad date */
+  {"GET", "/activities?from=junk&to=2013-04-26", "", http.StatusBadRequest},
+
+  /* test 4: missing activity */
+  {"GET", "/activities/123", "", http.StatusNotFound},
+
+  /* test 5: bad id */
+  {"GET", "/activities/foo", "", http.StatusBadRequest},
+
+  /* test 6: bad json */
+  {"POST", "/activities", "{", http.StatusBadRequest},
+
+  /* test 7: missing name */
+  {"POST", "/activities", `{"project": "foo"}`, http.StatusBadRequest},
+
+  /* test 8: edit missing activity */
+  {"PATCH", "/activities/123", `{"name": "foo"}`, http.StatusNotFound},
+
+  /* test 9: edit with bad date */
+  {"PATCH", "/activities/1", `{"start": "junk"}`, http.StatusBadRequest},
+
+  /* test 10: edit with nothing */
+  {"PATCH", "/activities/1", `{}`, http.StatusBadRequest},
+
+  /* test 11: delete missing activity */
+  {"DELETE", "/activities/123", "", http.StatusNotFound},
+
+  /* test 12: delete with bad id */
+  {"DELETE", "/activities/foo", "", http.StatusBadRequest},
+
+  /* test 13: restart missing activity */
+  {"POST", "/activities/123/restart", "", http.StatusNotFound},
+
+  /* test 14: stop with bad time */
+  {"POST", "/activities/stop", `{"at": "junk"}`, http.StatusBadRequest},
+
+  /* test 15: delete */
+  {"DELETE", "/activities/1", "", http.StatusNoContent},
+}
+
+func TestServer_Status(t *testing.T) {
+  for i, config := range serverStatusTests {
+    s, _ := newTestServer(&Activity{Name: "foo", Start: when(2013, 4, 26, 9)})
+    w := serverTestRequest(s, config.method, config.path, config.body)
+    if w.Code != config.status {
+      t.Errorf("test %d: expected status %d, got %d: %s", i, config.status, w.Code, w.Body)
+    }
+    if w.Code >= 400 {
+      var body map[string]string
+      if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body["error"] == "" {
+        t.Errorf("test %d: expected error body, got %s", i, w.Body)
+      }
+    }
+  }
+}
+
+func TestServer_ListActivities(t *testing.T) {
+  s, _ := newTestServer(
+    &Activity{Name: "foo", Start: when(2013, 4, 25, 9), End: when(2013, 4, 25, 10)},
+    &Activity{Name: "bar", Project: "baz", Tags: []string{"qux"}, Start: when(2013, 4, 26, 9)},
+  )
+
+  var tests = []struct {
+    path string
+    ids []int64
+  }{
+    {"/activities", []int64{2}},
+    {"/activities?range=week", []int64{1, 2}},
+    {"/activities?range=all", []int64{1, 2}},
+    {"/activities?from=2013-04-25&to=2013-04-25", []int64{1}},
+  }
+  for i, config := range tests {
+    w := serverTestRequest(s, "GET", config.path, "")
+    if w.Code != http.StatusOK {
+      t.Errorf("test %d: expected status 200, got %d", i, w.Code)
+      continue
+    }
+
+    var activities []activityJSON
+    err := json.Unmarshal(w.Body.Bytes(), &activities)
+    if err != nil {
+      t.Errorf("test %d: %s", i, err)
+      continue
+    }
+    if len(activities) != len(config.ids) {
+      t.Errorf("test %d: expected %d activities, got %d", i, len(config.ids), len(activities))
+      continue
+    }
+    for j, id := range config.ids {
+      if activities[j].Id != id {
+        t.Errorf("test %d: expected id %d, got %d", i, id, activities[j].Id)
+      }
+    }
+  }
+
+  w := serverTestRequest(s, "GET", "/activities/2", "")
+  var activity activityJSON
+  err := json.Unmarshal(w.Body.Bytes(), &activity)
+  if err != nil {
+    t.Error(err)
+    return
+  }
+  if activity.Name != "bar" || activity.Project != "baz" || len(activity.Tags) != 1 ||
+    activity.Status != "running" || activity.End != nil ||
+    activity.Duration != "03h00m" || activity.Seconds != 3 * 3600 {
+    t.Errorf("unexpected activity: %+v", activity)
+  }
+}
+
+func TestServer_StartActivity(t *testing.T) {
+  s, db := newTestServer()
+  w := serverTestRequest(s, "POST", "/activities",
+    `{"name": "foo", "project": "bar", "tags": ["baz", "qux"]}`)
+  if w.Code != http.StatusCreated {
+    t.Errorf("expected status 201, got %d: %s", w.Code, w.Body)
+    return
+  }
+
+  var activity activityJSON
+  err := json.Unmarshal(w.Body.Bytes(), &activity)
+  if err != nil {
+    t.Error(err)
+    return
+  }
+  expected := &Activity{Id: 1, Name: "foo", Project: "bar", Tags: []string{"baz", "qux"},
+    Start: when(2013, 4, 26, 12)}
+  if activity.Id != 1 || !expected.Equal(db.activities[1]) {
+    t.Errorf("expected %v, got %v (%+v)", expected, db.activities[1], activity)
+  }
+
+  /* start the last activity again */
+  w = serverTestRequest(s, "POST", "/activities", `{"last": true}`)
+  if w.Code != http.StatusCreated {
+    t.Errorf("expected status 201, got %d: %s", w.Code, w.Body)
+    return
+  }
+  expected.Id = 2
+  if !expected.Equal(db.activities[2]) {
+    t.Errorf("expected %v, got %v", expected, db.activities[2])
+  }
+}
+
+func TestServer_StopActivities(t *testing.T) {
+  s, db := newTestServer(
+    &Activity{Name: "foo", Start: when(2013, 4, 26, 9)},
+    &Activity{Name: "bar", Start: when(2013, 4, 26, 10)},
+  )
+
+  w := serverTestRequest(s, "POST", "/activities/stop", "")
+  if w.Code != http.StatusOK {
+    t.Errorf("expected status 200, got %d: %s", w.Code, w.Body)
+    return
+  }
+
+  var activities []activityJSON
+  err := json.Unmarshal(w.Body.Bytes(), &activities)
+  if err != nil {
+    t.Error(err)
+    return
+  }
+  if len(activities) != 2 {
+    t.Errorf("expected 2 activities, got %d", len(activities))
+  }
+  for id, activity := range db.activities {
+    if activity.IsRunning() {
+      t.Errorf("expected activity %d to be stopped", id)
+    }
+  }
+
+  /* stop at a particular time */
+  s, db = newTestServer(&Activity{Name: "foo", Start: when(2013, 4, 26, 9)})
+  w = serverTestRequest(s, "POST", "/activities/stop", `{"at": "11:00"}`)
+  if w.Code != http.StatusOK {
+    t.Errorf("expected status 200, got %d: %s", w.Code, w.Body)
+  } else if !db.activities[1].End.Equal(when(2013, 4, 26, 11)) {
+    t.Errorf("expected %v, got %v", when(2013, 4, 26, 11), db.activities[1].End)
+  }
+
+  /* stop at the server's maximum running time */
+  s, db = newTestServer(&Activity{Name: "foo", Start: when(2013, 4, 26, 9)})
+  s.MaxRunning = time.Hour
+  w = serverTestRequest(s, "POST", "/activities/stop", `{"at": "max"}`)
+  if w.Code != http.StatusOK {
+    t.Errorf("expected status 200, got %d: %s", w.Code, w.Body)
+  } else if !db.activities[1].End.Equal(when(2013, 4, 26, 10)) {
+    t.Errorf("expected %v, got %v", when(2013, 4, 26, 10), db.activities[1].End)
+  }
+}
+
+func TestServer_EditActivity(t *testing.T) {
+  s, db := newTestServer(&Activity{Name: "foo", Project: "bar", Tags: []string{"baz"},
+    Start: when(2013, 4, 26, 9)})
+
+  w := serverTestRequest(s, "PATCH", "/activities/1",
+    `{"name": "qux", "tags": [], "end": "2013-04-26 11:00"}`)
+  if w.Code != http.StatusOK {
+    t.Errorf("expected status 200, got %d: %s", w.Code, w.Body)
+    return
+  }
+
+  expected := &Activity{Id: 1, Name: "qux", Project: "bar", Tags: []string{},
+    Start: when(2013, 4, 26, 9), End: when(2013, 4, 26, 11)}
+  if !expected.Equal(db.activities[1]) {
+    t.Errorf("expected %v, got %v", expected, db.activities[1])
+  }
+
+  var activity activityJSON
+  err := json.Unmarshal(w.Body.Bytes(), &activity)
+  if err != nil {
+    t.Error(err)
+  } else if activity.Name != "qux" || activity.End == nil || activity.Duration != "02h00m" {
+    t.Errorf("unexpected activity: %+v", activity)
+  }
+}
+
+func TestServer_EditActivity_RoundTrip(t *testing.T) {
+  s, db := newTestServer(&Activity{Name: "foo", Start: when(2013, 4, 26, 9),
+    End: when(2013, 4, 26, 10)})
+
+  w := serverTestRequest(s, "GET", "/activities/1", "")
+  var activity map[string]interface{}
+  err := json.Unmarshal(w.Body.Bytes(), &activity)
+  if err != nil {
+    t.Error(err)
+    return
+  }
+
+  /* send back the dates as they were received */
+  body, _ := json.Marshal(map[string]interface{}{"start": activity["start"], "end": activity["end"]})
+  w = serverTestRequest(s, "PATCH", "/activities/1", string(body))
+  if w.Code != http.StatusOK {
+    t.Errorf("expected status 200, got %d: %s", w.Code, w.Body)
+  } else if !db.activities[1].Start.Equal(when(2013, 4, 26, 9)) ||
+    !db.activities[1].End.Equal(when(2013, 4, 26, 10)) {
+    t.Errorf("expected dates to be unchanged, got %v", db.activities[1])
+  }
+}
+
+func TestServer_EditActivity_AllOrNothing(t *testing.T) {
+  s, db := newTestServer(&Activity{Name: "foo", Start: when(2013, 4, 26, 9)})
+
+  w := serverTestRequest(s, "PATCH", "/activities/1", `{"name": "qux", "start": "junk"}`)
+  if w.Code != http.StatusBadRequest {
+    t.Errorf("expected status 400, got %d: %s", w.Code, w.Body)
+  }
+  if db.activities[1].Name != "foo" {
+    t.Errorf("expected name to be unchanged, got %q", db.activities[1].Name)
+  }
+}
+
+func TestServer_EditActivity_ClearEnd(t *testing.T) {
+  s, db := newTestServer(&Activity{Name: "foo", Start: when(2013, 4, 26, 9),
+    End: when(2013, 4, 26, 10)})
+
+  w := serverTestRequest(s, "PATCH", "/activities/1", `{"end": null}`)
+  if w.Code != http.StatusOK {
+    t.Errorf("expected status 200, got %d: %s", w.Code, w.Body)
+  } else if !db.activities[1].IsRunning() {
+    t.Errorf("expected activity to be running, got %v", db.activities[1])
+  }
+}
+
+func TestServer_Strict(t *testing.T) {
+  s, db := newTestServer(&Activity{Name: "foo", Start: when(2013, 4, 26, 9)})
+  s.Strict = true
+
+  w := serverTestRequest(s, "POST", "/activities", `{"name": "bar", "project": "unknown"}`)
+  if w.Code == http.StatusCreated {
+    t.Errorf("expected unknown project to be refused: %s", w.Body)
+  }
+  w = serverTestRequest(s, "PATCH", "/activities/1", `{"project": "unknown"}`)
+  if w.Code == http.StatusOK {
+    t.Errorf("expected unknown project to be refused: %s", w.Body)
+  }
+  if len(db.activities) != 1 || db.activities[1].Project != "" {
+    t.Errorf("unexpected activities: %v", db.activities)
+  }
+}
+
+var serverCrossSiteTests = []struct {
+  method string
+  path string
+  headers map[string]string
+  status int
+}{
+  /* test 0: same origin */
+  {"POST", "/activities", map[string]string{"Origin": "http://example.com",
+    "Sec-Fetch-Site": "same-origin", "Content-Type": "application/json"}, http.StatusCreated},
+
+  /* test 1: not from a browser */
+  {"POST", "/activities", map[string]string{"Content-Type": "application/json"}, http.StatusCreated},
+
+  /* test 2: another site */
+  {"POST", "/activities", map[string]string{"Origin": "http://evil.example",
+    "Content-Type": "application/json"}, http.StatusForbidden},
+
+  /* test 3: fetch metadata from another site */
+  {"PATCH", "/activities/1", map[string]string{"Sec-Fetch-Site": "cross-site",
+    "Content-Type": "application/json"}, http.StatusForbidden},
+
+  /* test 4: deleting from another site */
+  {"DELETE", "/activities/1", map[string]string{"Origin": "null"}, http.StatusForbidden},
+
+  /* test 5: form posts */
+  {"POST", "/activities/stop", map[string]string{"Content-Type": "text/plain"},
+    http.StatusUnsupportedMediaType},
+
+  /* test 6: no content type */
+  {"POST", "/activities/1/restart", map[string]string{}, http.StatusUnsupportedMediaType},
+
+  /* test 7: parameters are allowed */
+  {"PATCH", "/activities/1", map[string]string{"Content-Type": "application/json; charset=utf-8"},
+    http.StatusOK},
+}
+
+func TestServer_CrossSite(t *testing.T) {
+  for i, config := range serverCrossSiteTests {
+    s, db := newTestServer(&Activity{Name: "foo", Start: when(2013, 4, 26, 9),
+      End: when(2013, 4, 26, 10)})
+    req := httptest.NewRequest(config.method, "http://example.com" + config.path,
+      strings.NewReader(`{"name": "bar"}`))
+    for key, value := range config.headers {
+      req.Header.Set(key, value)
+    }
+    w := httptest.NewRecorder()
+    s.ServeHTTP(w, req)
+
+    if w.Code != config.status {
+      t.Errorf("test %d: expected status %d, got %d: %s", i, config.status, w.Code, w.Body)
+    } else if w.Code >= 400 && (len(db.activities) != 1 || db.activities[1].Name != "foo") {
+      t.Errorf("test %d: activities were changed: %v", i, db.activities)
+    }
+  }
+}
+
+func TestServer_RestartActivity(t *testing.T) {
+  s, db := newTestServer(&Activity{Name: "foo", Start: when(2013, 4, 26, 9),
+    End: when(2013, 4, 26, 10)})
+
+  w := serverTestRequest(s, "POST", "/activities/1/restart", "")
+  if w.Code != http.StatusCreated {
+    t.Errorf("expected status 201, got %d: %s", w.Code, w.Body)
+    return
+  }
+  if len(db.activities) != 2 || db.activities[2].Name != "foo" || !db.activities[2].IsRunning() {
+    t.Errorf("activity wasn't restarted: %v", db.activities)
+  }
+}
+
+func TestServer_Report(t *testing.T) {
+  s, _ := newTestServer(
+    &Activity{Name: "foo", Project: "bar", Start: when(2013, 4, 26, 8), End: when(2013, 4, 26, 10)},
+    &Activity{Name: "baz", Start: when(2013, 4, 26, 11),
+      Pauses: []Pause{Pause{when(2013, 4, 26, 11).Add(30 * time.Minute), time.Time{}}}},
+  )
+
+  w := serverTestRequest(s, "GET", "/report", "")
+  if w.Code != http.StatusOK {
+    t.Errorf("expected status 200, got %d: %s", w.Code, w.Body)
+    return
+  }
+
+  var report reportJSON
+  err := json.Unmarshal(w.Body.Bytes(), &report)
+  if err != nil {
+    t.Error(err)
+    return
+  }
+  if report.Duration != "02h30m" || report.Seconds != 9000 {
+    t.Errorf("unexpected total: %+v", report)
+  }
+  if len(report.Projects) != 2 || report.Projects[0] != (projectJSON{"bar", "02h00m", 7200, ""}) ||
+    report.Projects[1] != (projectJSON{"", "00h30m", 1800, ""}) {
+    t.Errorf("unexpected projects: %+v", report.Projects)
+  }
+}
+
+func TestServeCommand_Help(t *testing.T) {
+  cmd := ServeCommand{}
+  if cmd.Help() == "" {
+    t.Error("no help available")
+  }
+}
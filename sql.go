@@ -7,16 +7,26 @@ import (
   "time"
 )
 
-const SqlVersion = 2
+const SqlVersion = 10
 
 /* sql backend */
 type Sql struct {
   DriverName string
   DataSourceName string
   Log io.Writer
+  /* picked from the driver name when nil */
+  Dialect *SqlDialect
+}
+
+func (db *Sql) dialect() *SqlDialect {
+  if db.Dialect != nil {
+    return db.Dialect
+  }
+  return DialectFor(db.DriverName)
 }
 
 func (db *Sql) exec(conn *sql.DB, query string, args ...interface{}) (res sql.Result, err error) {
+  query = db.dialect().rebind(query)
   if db.Log != nil {
     message := fmt.Sprintf("exec: \"%s\" with args: %v\n", query, args)
     db.Log.Write([]byte(message))
@@ -25,7 +35,18 @@ func (db *Sql) exec(conn *sql.DB, query string, args ...interface{}) (res sql.Re
   return
 }
 
+func (db *Sql) execTx(tx *sql.Tx, query string, args ...interface{}) (res sql.Result, err error) {
+  query = db.dialect().rebind(query)
+  if db.Log != nil {
+    message := fmt.Sprintf("exec: \"%s\" with args: %v\n", query, args)
+    db.Log.Write([]byte(message))
+  }
+  res, err = tx.Exec(query, args...)
+  return
+}
+
 func (db *Sql) query(conn *sql.DB, query string, args ...interface{}) (rows *sql.Rows, err error) {
+  query = db.dialect().rebind(query)
   if db.Log != nil {
     message := fmt.Sprintf("query: \"%s\" with args: %v\n", query, args)
     db.Log.Write([]byte(message))
@@ -35,6 +56,7 @@ func (db *Sql) query(conn *sql.DB, query string, args ...interface{}) (rows *sql
 }
 
 func (db *Sql) queryRow(conn *sql.DB, query string, args ...interface{}) (row *sql.Row) {
+  query = db.dialect().rebind(query)
   if db.Log != nil {
     message := fmt.Sprintf("queryRow: \"%s\" with args: %v\n", query, args)
     db.Log.Write([]byte(message))
@@ -82,6 +104,7 @@ func (db *Sql) Migrate() error {
   version := 0
   versionRow.Scan(&version)
 
+  d := db.dialect()
   var execErr error
   for ; version < SqlVersion; version++ {
     switch version {
@@ -91,8 +114,40 @@ func (db *Sql) Migrate() error {
         _, execErr = db.exec(conn, "INSERT INTO schema_info VALUES (?)", 0)
       }
     case 1:
-      _, execErr = db.exec(conn, `CREATE TABLE activities (id INTEGER PRIMARY KEY,
-        name TEXT, project TEXT, tags TEXT, start TIMESTAMP, end TIMESTAMP)`)
+      _, execErr = db.exec(conn, fmt.Sprintf(`CREATE TABLE activities (id %s,
+        name TEXT, project TEXT, tags TEXT, start %s, "end" %[2]s)`, d.Serial, d.Timestamp))
+    case 2:
+      _, execErr = db.exec(conn, fmt.Sprintf(`CREATE TABLE pauses (id %s,
+        activity_id %s, start %s, "end" %[3]s)`, d.Serial, d.Integer, d.Timestamp))
+    case 3:
+      _, execErr = db.exec(conn, fmt.Sprintf(`CREATE TABLE rates (id %s,
+        project TEXT, tag TEXT, amount %s, currency TEXT, billable BOOLEAN)`, d.Serial, d.Integer))
+    case 4:
+      _, execErr = db.exec(conn, fmt.Sprintf(`CREATE TABLE projects (id %s,
+        name %s UNIQUE, description TEXT, archived BOOLEAN)`, d.Serial, d.Key))
+    case 5:
+      /* tags were joined with commas, which tags could contain */
+      execErr = db.migrateTags(conn)
+    case 6:
+      _, execErr = db.exec(conn, fmt.Sprintf(`CREATE TABLE goals (id %s,
+        project TEXT, tag TEXT, target %s, period TEXT, cap BOOLEAN)`, d.Serial, d.Integer))
+    case 7:
+      _, execErr = db.exec(conn, "ALTER TABLE activities ADD COLUMN notes " + d.DefaultText)
+    case 8:
+      _, execErr = db.exec(conn, "ALTER TABLE activities ADD COLUMN owner " + d.DefaultText)
+    case 9:
+      /*
+       * running activities and breaks have a NULL end, since MySQL refuses
+       * zero dates; they were stored with the zero time, which is before the
+       * earliest date MySQL takes
+       */
+      earliest := time.Date(1000, 1, 1, 0, 0, 0, 0, time.UTC)
+      for _, table := range []string{"activities", "pauses"} {
+        if execErr == nil {
+          _, execErr = db.exec(conn, `UPDATE ` + table + ` SET "end" = NULL WHERE "end" < ?`,
+            earliest)
+        }
+      }
     }
 
     if execErr != nil {
@@ -118,6 +173,39 @@ func (db *Sql) Migrate() error {
   return err
 }
 
+/* re-encode every activity's tags, reading them all before updating */
+func (db *Sql) migrateTags(conn *sql.DB) (err error) {
+  var rows *sql.Rows
+  rows, err = db.query(conn, "SELECT id, tags FROM activities WHERE tags != ''")
+  if err != nil {
+    return
+  }
+  tags := make(map[int64]string)
+  for rows.Next() {
+    var id int64
+    var tagList string
+    err = rows.Scan(&id, &tagList)
+    if err != nil {
+      rows.Close()
+      return
+    }
+    tags[id] = encodeTags(splitTagList(tagList))
+  }
+  err = rows.Err()
+  rows.Close()
+  if err != nil {
+    return
+  }
+
+  for id, encoded := range tags {
+    _, err = db.exec(conn, "UPDATE activities SET tags = ? WHERE id = ?", encoded, id)
+    if err != nil {
+      return
+    }
+  }
+  return
+}
+
 func (db *Sql) SaveActivity(a *Activity) error {
   err := &DatabaseErrors{}
 
@@ -127,35 +215,69 @@ func (db *Sql) SaveActivity(a *Activity) error {
     return err
   }
 
+  if a.Id != 0 {
+    /* MySQL doesn't count unchanged rows as affected, so look first */
+    var id int64
+    scanErr := db.queryRow(conn, "SELECT id FROM activities WHERE id = ?", a.Id).Scan(&id)
+    if scanErr != nil {
+      conn.Close()
+      if scanErr == sql.ErrNoRows {
+        return ErrNotFound
+      }
+      err.Append(scanErr)
+      return err
+    }
+  }
+
   var query string
   var args []interface{}
   if (a.Id == 0) {
     query = `
-      INSERT INTO activities (name, project, tags, start, end)
-      VALUES(?, ?, ?, ?, ?)
+      INSERT INTO activities (name, project, tags, start, "end", notes, owner)
+      VALUES(?, ?, ?, ?, ?, ?, ?)
     `
-    args = []interface{}{a.Name, a.Project, a.TagList(), a.Start.UTC(), a.End.UTC()}
+    args = []interface{}{a.Name, a.Project, encodeTags(a.Tags), a.Start.UTC(), sqlEnd(a.End), a.Notes,
+      a.User}
   } else {
     query = `
       UPDATE activities SET name = ?, project = ?, tags = ?,
-      start = ?, end = ? WHERE id = ?
+      start = ?, "end" = ?, notes = ?, owner = ? WHERE id = ?
     `
-    args = []interface{}{a.Name, a.Project, a.TagList(), a.Start.UTC(), a.End.UTC(), a.Id}
+    args = []interface{}{a.Name, a.Project, encodeTags(a.Tags), a.Start.UTC(), sqlEnd(a.End), a.Notes,
+      a.User, a.Id}
   }
 
   /* Execute the query */
-  res, execErr := db.exec(conn, query, args...)
-  if execErr == nil {
-    if a.Id == 0 {
-      id, idErr := res.LastInsertId()
-      if idErr == nil {
-        a.Id = id
-      } else {
-        err.Append(idErr)
-      }
+  if a.Id == 0 && db.dialect().Returning {
+    /* there's no LastInsertId, so the new id is read back instead */
+    var id int64
+    scanErr := db.queryRow(conn, query + " RETURNING id", args...).Scan(&id)
+    if scanErr == nil {
+      a.Id = id
+    } else {
+      err.Append(scanErr)
     }
   } else {
-    err.Append(execErr)
+    res, execErr := db.exec(conn, query, args...)
+    if execErr == nil {
+      if a.Id == 0 {
+        id, idErr := res.LastInsertId()
+        if idErr == nil {
+          a.Id = id
+        } else {
+          err.Append(idErr)
+        }
+      }
+    } else {
+      err.Append(execErr)
+    }
+  }
+
+  if err.IsEmpty() {
+    pauseErr := db.savePauses(conn, a)
+    if pauseErr != nil {
+      err.Append(pauseErr)
+    }
   }
 
   connErr := conn.Close()
@@ -169,6 +291,65 @@ func (db *Sql) SaveActivity(a *Activity) error {
   return err
 }
 
+/* ends that haven't happened yet are NULL */
+func sqlEnd(end time.Time) interface{} {
+  if end.IsZero() {
+    return nil
+  }
+  return end.UTC()
+}
+
+/* an end read back, with NULL as the zero time */
+func sqlEndTime(end *time.Time) time.Time {
+  if end == nil {
+    return time.Time{}
+  }
+  return end.Local()
+}
+
+func (db *Sql) savePauses(conn *sql.DB, a *Activity) (err error) {
+  _, err = db.exec(conn, "DELETE FROM pauses WHERE activity_id = ?", a.Id)
+  if err != nil {
+    return
+  }
+  for _, pause := range a.Pauses {
+    _, err = db.exec(conn, `INSERT INTO pauses (activity_id, start, "end") VALUES(?, ?, ?)`,
+      a.Id, pause.Start.UTC(), sqlEnd(pause.End))
+    if err != nil {
+      return
+    }
+  }
+  return
+}
+
+func (db *Sql) findPauses(conn *sql.DB, predicate string, args ...interface{}) (pauses map[int64][]Pause, err error) {
+  query := `SELECT activity_id, start, "end" FROM pauses
+    WHERE activity_id IN (SELECT id FROM activities ` + predicate + `)
+    ORDER BY start`
+
+  var rows *sql.Rows
+  rows, err = db.query(conn, query, args...)
+  if err != nil {
+    return
+  }
+  defer rows.Close()
+
+  pauses = make(map[int64][]Pause)
+  for rows.Next() {
+    var activityId int64
+    var start time.Time
+    var end *time.Time
+    err = rows.Scan(&activityId, &start, &end)
+    if err != nil {
+      return
+    }
+    pause := Pause{start.Local(), sqlEndTime(end)}
+    pauses[activityId] = append(pauses[activityId], pause)
+  }
+  err = rows.Err()
+  return
+}
+
 func (db *Sql) findActivities(predicate string, args ...interface{}) ([]*Activity, error) {
   var activities []*Activity = nil
   err := &DatabaseErrors{}
@@ -179,8 +360,8 @@ func (db *Sql) findActivities(predicate string, args ...interface{}) ([]*Activit
     return activities, err
   }
 
-  query := `SELECT id, name, project, tags, start, end
-    FROM activities ` + predicate
+  query := `SELECT id, name, project, tags, start, "end", notes, owner
+    FROM activities ` + predicate + " ORDER BY id"
   rows, queryErr := db.query(conn, query, args...)
 
   if queryErr != nil {
@@ -188,13 +369,19 @@ func (db *Sql) findActivities(predicate string, args ...interface{}) ([]*Activit
   } else {
     for rows.Next() {
       var id int64
-      var name, project, tagList string
-      var start, end time.Time
+      var name, project, tagList, notes, owner string
+      var start time.Time
+      var end *time.Time
 
-      scanErr := rows.Scan(&id, &name, &project, &tagList, &start, &end)
+      scanErr := rows.Scan(&id, &name, &project, &tagList, &start, &end, &notes, &owner)
       if scanErr == nil {
-        activity := &Activity{Id: id, Name: name, Project: project, Start: start.Local(), End: end.Local()}
-        activity.SetTagList(tagList)
+        activity := &Activity{Id: id, Name: name, Project: project, Start: start.Local(), End: sqlEndTime(end),
+          Notes: notes, User: owner}
+        var tagErr error
+        activity.Tags, tagErr = decodeTags(tagList)
+        if tagErr != nil {
+          err.Append(tagErr)
+        }
         activities = append(activities, activity)
       } else {
         err.Append(scanErr)
@@ -202,6 +389,17 @@ func (db *Sql) findActivities(predicate string, args ...interface{}) ([]*Activit
     }
   }
 
+  if err.IsEmpty() && len(activities) > 0 {
+    pauses, pauseErr := db.findPauses(conn, predicate, args...)
+    if pauseErr == nil {
+      for _, activity := range activities {
+        activity.Pauses = pauses[activity.Id]
+      }
+    } else {
+      err.Append(pauseErr)
+    }
+  }
+
   connErr := conn.Close()
   if connErr != nil {
     err.Append(connErr)
@@ -230,7 +428,7 @@ func (db *Sql) FindAllActivities() (activities []*Activity, err error) {
 }
 
 func (db *Sql) FindRunningActivities() (activities []*Activity, err error) {
-  activities, err = db.findActivities("WHERE end IS ?", &time.Time{})
+  activities, err = db.findActivities(`WHERE "end" IS NULL`)
   return
 }
 
@@ -256,5 +454,243 @@ func (db *Sql) DeleteActivity(id int64) (err error) {
       err = ErrNotFound
     }
   }
+  if err == nil {
+    _, err = db.exec(conn, "DELETE FROM pauses WHERE activity_id = ?", id)
+  }
+  return
+}
+
+/* a rate replaces any other rate for the same project and tag */
+func (db *Sql) SaveRate(r *Rate) (err error) {
+  var conn *sql.DB
+  conn, err = sql.Open(db.DriverName, db.DataSourceName)
+  if err != nil {
+    return
+  }
+  defer conn.Close()
+
+  _, err = db.exec(conn, "DELETE FROM rates WHERE project = ? AND tag = ?", r.Project, r.Tag)
+  if err == nil {
+    _, err = db.exec(conn, `INSERT INTO rates (project, tag, amount, currency, billable)
+      VALUES(?, ?, ?, ?, ?)`, r.Project, r.Tag, r.Hourly.Amount, r.Hourly.Currency, r.Billable)
+  }
+  return
+}
+
+func (db *Sql) FindRates() (rates []*Rate, err error) {
+  var conn *sql.DB
+  conn, err = sql.Open(db.DriverName, db.DataSourceName)
+  if err != nil {
+    return
+  }
+  defer conn.Close()
+
+  var rows *sql.Rows
+  rows, err = db.query(conn, `SELECT project, tag, amount, currency, billable
+    FROM rates ORDER BY project, tag`)
+  if err != nil {
+    return
+  }
+  defer rows.Close()
+
+  for rows.Next() {
+    rate := &Rate{}
+    err = rows.Scan(&rate.Project, &rate.Tag, &rate.Hourly.Amount, &rate.Hourly.Currency,
+      &rate.Billable)
+    if err != nil {
+      return
+    }
+    rates = append(rates, rate)
+  }
+  err = rows.Err()
+  return
+}
+
+func (db *Sql) DeleteRate(project, tag string) (err error) {
+  var conn *sql.DB
+  conn, err = sql.Open(db.DriverName, db.DataSourceName)
+  if err != nil {
+    return
+  }
+  defer conn.Close()
+
+  var result sql.Result
+  result, err = db.exec(conn, "DELETE FROM rates WHERE project = ? AND tag = ?", project, tag)
+  if err == nil {
+    var n int64
+    n, err = result.RowsAffected()
+    if err == nil && n == 0 {
+      err = ErrNotFound
+    }
+  }
+  return
+}
+
+func (db *Sql) SaveProject(p *Project) (err error) {
+  var conn *sql.DB
+  conn, err = sql.Open(db.DriverName, db.DataSourceName)
+  if err != nil {
+    return
+  }
+  defer conn.Close()
+
+  /* MySQL counts rows that an update leaves alone as unaffected, so look first */
+  var id int64
+  err = db.queryRow(conn, "SELECT id FROM projects WHERE name = ?", p.Name).Scan(&id)
+  if err == nil {
+    _, err = db.exec(conn, "UPDATE projects SET description = ?, archived = ? WHERE id = ?",
+      p.Description, p.Archived, id)
+  } else if err == sql.ErrNoRows {
+    _, err = db.exec(conn, "INSERT INTO projects (name, description, archived) VALUES(?, ?, ?)",
+      p.Name, p.Description, p.Archived)
+  }
+  return
+}
+
+func (db *Sql) FindProjects() (projects []*Project, err error) {
+  var conn *sql.DB
+  conn, err = sql.Open(db.DriverName, db.DataSourceName)
+  if err != nil {
+    return
+  }
+  defer conn.Close()
+
+  var rows *sql.Rows
+  rows, err = db.query(conn, "SELECT name, description, archived FROM projects ORDER BY name")
+  if err != nil {
+    return
+  }
+  defer rows.Close()
+
+  for rows.Next() {
+    project := &Project{}
+    err = rows.Scan(&project.Name, &project.Description, &project.Archived)
+    if err != nil {
+      return
+    }
+    projects = append(projects, project)
+  }
+  err = rows.Err()
+  return
+}
+
+/* the project, its activities, rates and goals are renamed together, unless the new name is taken */
+func (db *Sql) RenameProject(old, new string) (err error) {
+  var conn *sql.DB
+  conn, err = sql.Open(db.DriverName, db.DataSourceName)
+  if err != nil {
+    return
+  }
+  defer conn.Close()
+
+  var count int
+  err = db.queryRow(conn, "SELECT COUNT(*) FROM projects WHERE name = ?", new).Scan(&count)
+  if err != nil {
+    return
+  } else if count > 0 {
+    err = ErrProjectExists
+    return
+  }
+
+  var tx *sql.Tx
+  tx, err = conn.Begin()
+  if err != nil {
+    return
+  }
+  queries := []string{
+    "UPDATE activities SET project = ? WHERE project = ?",
+    "UPDATE rates SET project = ? WHERE project = ?",
+    "UPDATE goals SET project = ? WHERE project = ?",
+    "UPDATE projects SET name = ? WHERE name = ?",
+  }
+  var renamed int64
+  for _, query := range queries {
+    var res sql.Result
+    res, err = db.execTx(tx, query, new, old)
+    if err != nil {
+      tx.Rollback()
+      return
+    }
+    var n int64
+    n, err = res.RowsAffected()
+    if err != nil {
+      tx.Rollback()
+      return
+    }
+    renamed += n
+  }
+  if renamed == 0 {
+    tx.Rollback()
+    err = ErrNotFound
+    return
+  }
+  err = tx.Commit()
+  return
+}
+
+/* a goal replaces any other goal for the same project and tag */
+func (db *Sql) SaveGoal(g *Goal) (err error) {
+  var conn *sql.DB
+  conn, err = sql.Open(db.DriverName, db.DataSourceName)
+  if err != nil {
+    return
+  }
+  defer conn.Close()
+
+  _, err = db.exec(conn, "DELETE FROM goals WHERE project = ? AND tag = ?", g.Project, g.Tag)
+  if err == nil {
+    _, err = db.exec(conn, `INSERT INTO goals (project, tag, target, period, cap)
+      VALUES(?, ?, ?, ?, ?)`, g.Project, g.Tag, int64(g.Target), g.Period, g.Cap)
+  }
+  return
+}
+
+func (db *Sql) FindGoals() (goals []*Goal, err error) {
+  var conn *sql.DB
+  conn, err = sql.Open(db.DriverName, db.DataSourceName)
+  if err != nil {
+    return
+  }
+  defer conn.Close()
+
+  var rows *sql.Rows
+  rows, err = db.query(conn, `SELECT project, tag, target, period, cap
+    FROM goals ORDER BY project, tag`)
+  if err != nil {
+    return
+  }
+  defer rows.Close()
+
+  for rows.Next() {
+    goal := &Goal{}
+    var target int64
+    err = rows.Scan(&goal.Project, &goal.Tag, &target, &goal.Period, &goal.Cap)
+    if err != nil {
+      return
+    }
+    goal.Target = Duration(target)
+    goals = append(goals, goal)
+  }
+  err = rows.Err()
+  return
+}
+
+func (db *Sql) DeleteGoal(project, tag string) (err error) {
+  var conn *sql.DB
+  conn, err = sql.Open(db.DriverName, db.DataSourceName)
+  if err != nil {
+    return
+  }
+  defer conn.Close()
+
+  var result sql.Result
+  result, err = db.exec(conn, "DELETE FROM goals WHERE project = ? AND tag = ?", project, tag)
+  if err == nil {
+    var n int64
+    n, err = result.RowsAffected()
+    if err == nil && n == 0 {
+      err = ErrNotFound
+    }
+  }
   return
 }
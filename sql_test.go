@@ -21,7 +21,7 @@ func sqlTestRun(f func (db *Sql), t *testing.T) {
     t.Error(closeErr)
   }
 
-  db := &Sql{"sqlite", dbFile.Name(), nil}
+  db := &Sql{"sqlite", dbFile.Name(), nil, nil}
 
   /* Check database validity, register driver if necessary */
   var ok bool
@@ -61,6 +61,45 @@ func TestSql_Version(t *testing.T) {
   sqlTestRun(f, t)
 }
 
+func TestSql_Migrate_NullEnd(t *testing.T) {
+  f := func (db *Sql) {
+    /* running activities had the zero time as their end before version 10 */
+    conn, err := sql.Open(db.DriverName, db.DataSourceName)
+    if err != nil {
+      t.Fatal(err)
+    }
+    defer conn.Close()
+    start := time.Date(2013, 4, 26, 9, 0, 0, 0, time.UTC)
+    for _, query := range []string{
+      `INSERT INTO activities (id, name, project, tags, start, "end") VALUES(1, 'foo', '', '', ?, ?)`,
+      `INSERT INTO pauses (activity_id, start, "end") VALUES(1, ?, ?)`,
+    } {
+      _, err = conn.Exec(query, start, time.Time{})
+      if err != nil {
+        t.Fatal(err)
+      }
+    }
+    _, err = conn.Exec("UPDATE schema_info SET version = 9")
+    if err != nil {
+      t.Fatal(err)
+    }
+
+    err = db.Migrate()
+    if err != nil {
+      t.Fatal(err)
+    }
+    var running []*Activity
+    running, err = db.FindRunningActivities()
+    if err != nil {
+      t.Fatal(err)
+    }
+    if len(running) != 1 || !running[0].IsPaused() {
+      t.Errorf("expected a paused activity, got %v", running)
+    }
+  }
+  sqlTestRun(f, t)
+}
+
 func TestSql_SaveActivity(t *testing.T) {
   f := func (db *Sql) {
     activity := &Activity{Name: "foo", Project: "bar"}
@@ -133,22 +172,14 @@ func TestSql_SaveActivity_WithExistingActivity(t *testing.T) {
 }
 
 
-func TestSql_FindActivity_WithNonExistantId(t *testing.T) {
-  f := func(db *Sql) {
-    _, findErr := db.FindActivity(1234)
-    if findErr != ErrNotFound {
-      t.Errorf("expected ErrNotFound, got %T", findErr)
-      return
-    }
-  }
-  sqlTestRun(f, t)
-}
-
-func TestSql_FindAllActivities(t *testing.T) {
+func TestSql_SaveActivity_WithPauses(t *testing.T) {
   f := func (db *Sql) {
     activity := &Activity{Name: "foo", Project: "bar"}
-    activity.End = time.Now()
-    activity.Start = activity.End.Add(-time.Hour)
+    activity.Start = time.Now().Add(-time.Hour)
+    activity.Pauses = []Pause{
+      Pause{activity.Start.Add(10 * time.Minute), activity.Start.Add(20 * time.Minute)},
+      Pause{Start: activity.Start.Add(30 * time.Minute)},
+    }
 
     saveErr := db.SaveActivity(activity)
     if saveErr != nil {
@@ -156,118 +187,37 @@ func TestSql_FindAllActivities(t *testing.T) {
       return
     }
 
-    activities, findErr := db.FindAllActivities()
-    if findErr != nil {
-      t.Error(findErr)
-      return
-    }
-
-    if len(activities) != 1 {
-      t.Error("expected to find 1 activity, but found", len(activities))
-      return
-    }
-
-    if !activity.Equal(activities[0]) {
-      t.Error("expected:\n", activity, "\ngot:\n", activities[0])
-    }
-  }
-  sqlTestRun(f, t)
-}
-
-func TestSql_FindRunningActivities(t *testing.T) {
-  f := func (db *Sql) {
-    activity_1 := &Activity{Name: "foo", Project: "bar"}
-    activity_1.End = time.Now()
-    activity_1.Start = activity_1.End.Add(-time.Hour)
-
-    activity_2 := &Activity{Name: "baz", Start: time.Now()}
-
-    var saveErr error
-    saveErr = db.SaveActivity(activity_1)
-    if saveErr != nil {
-      t.Error(saveErr)
-    }
-    saveErr = db.SaveActivity(activity_2)
+    /* saving again replaces the pauses */
+    activity.Resume(time.Now())
+    saveErr = db.SaveActivity(activity)
     if saveErr != nil {
       t.Error(saveErr)
-    }
-
-    activities, findErr := db.FindRunningActivities()
-    if findErr != nil {
-      t.Error(findErr)
-      return
-    }
-
-    if len(activities) != 1 {
-      t.Error("expected to find 1 activity, but found", len(activities))
       return
     }
 
-    if !activity_2.Equal(activities[0]) {
-      t.Error("expected:\n", activity_2, "\ngot:\n", activities[0])
-    }
-  }
-  sqlTestRun(f, t)
-}
-
-func TestSql_FindActivitiesBetween(t *testing.T) {
-  f := func (db *Sql) {
-    now := time.Now()
-
-    activity_1 := &Activity{Name: "foo", Project: "bar"}
-    activity_1.End = now.Add(-(time.Hour * 24))
-    activity_1.Start = activity_1.End.Add(-time.Hour)
-
-    activity_2 := &Activity{Name: "baz", Start: now}
-
-    var saveErr error
-    saveErr = db.SaveActivity(activity_1)
-    if saveErr != nil {
-      t.Error(saveErr)
-    }
-    saveErr = db.SaveActivity(activity_2)
-    if saveErr != nil {
-      t.Error(saveErr)
-    }
-
-    activities, findErr := db.FindActivitiesBetween(activity_1.Start,
-      activity_1.Start.Add(time.Hour))
+    activities, findErr := db.FindRunningActivities()
     if findErr != nil {
       t.Error(findErr)
       return
     }
-
     if len(activities) != 1 {
       t.Error("expected to find 1 activity, but found", len(activities))
       return
     }
-
-    if !activity_1.Equal(activities[0]) {
-      t.Error("expected:\n", activity_1, "\ngot:\n", activities[0])
+    if !activity.Equal(activities[0]) {
+      t.Error("expected:\n", activity, "\ngot:\n", activities[0])
     }
   }
   sqlTestRun(f, t)
 }
 
-func TestSql_DeleteActivity(t *testing.T) {
+func TestSql_FindActivity_WithNonExistantId(t *testing.T) {
   f := func(db *Sql) {
-    var err error
-    activity := &Activity{Name: "foo"}
-    err = db.SaveActivity(activity)
-    if err != nil {
-      t.Error(err)
+    _, findErr := db.FindActivity(1234)
+    if findErr != ErrNotFound {
+      t.Errorf("expected ErrNotFound, got %T", findErr)
       return
     }
-
-    err = db.DeleteActivity(activity.Id)
-    if err != nil {
-      t.Error(err)
-    }
-
-    _, err = db.FindActivity(activity.Id)
-    if err != ErrNotFound {
-      t.Errorf("expected ErrNotFound, got %v", err)
-    }
   }
   sqlTestRun(f, t)
 }
@@ -0,0 +1,198 @@
+package hourglass
+
+import (
+  "fmt"
+  "sort"
+)
+
+/* help messages */
+const (
+  tagsHelp = "Usage: %s tags <list|rename|delete> [arguments]\n\nManage tags\n\n\tlist\n\trename <old> <new>\n\tdelete <tag>\n\nList shows how many activities use each tag and their total time. Renaming or deleting a tag changes every user's activities, and renaming also renames it in billing rates and goals."
+)
+
+/* tags */
+type TagsCommand struct{}
+
+func (TagsCommand) Run(c Clock, db Database, args ...string) (output string, err error) {
+  if len(args) == 0 {
+    err = SyntaxError("missing subcommand")
+    return
+  }
+
+  switch args[0] {
+  case "list":
+    if len(args) > 1 {
+      err = SyntaxError("too many arguments")
+      return
+    }
+    output, err = listTags(c, db)
+
+  case "rename":
+    if len(args) != 3 || args[1] == "" || args[2] == "" {
+      err = SyntaxError("expected old and new tags")
+      return
+    }
+    var n int
+    n, err = replaceTag(db, args[1], args[2])
+    if err != nil {
+      return
+    }
+    err = renameRateTag(db, args[1], args[2])
+    if err == nil {
+      err = renameGoalTag(db, args[1], args[2])
+    }
+    if err == nil {
+      output = fmt.Sprintf("renamed tag %s to %s on %d activities", args[1], args[2], n)
+    }
+
+  case "delete":
+    if len(args) != 2 || args[1] == "" {
+      err = SyntaxError("missing tag")
+      return
+    }
+    var n int
+    n, err = replaceTag(db, args[1], "")
+    if err == nil {
+      output = fmt.Sprintf("deleted tag %s from %d activities", args[1], n)
+    }
+
+  default:
+    err = SyntaxError(fmt.Sprint("invalid subcommand: ", args[0]))
+  }
+  return
+}
+
+func (TagsCommand) Help() string {
+  return tagsHelp
+}
+
+func listTags(c Clock, db Database) (output string, err error) {
+  var activities []*Activity
+  activities, err = db.FindAllActivities()
+  if err != nil {
+    return
+  }
+
+  counts := make(map[string]int)
+  durations := make(map[string]Duration)
+  for _, activity := range activities {
+    for _, tag := range activity.Tags {
+      counts[tag]++
+      durations[tag] += activity.Duration(c)
+    }
+  }
+  if len(counts) == 0 {
+    output = "there aren't any tags"
+    return
+  }
+
+  tags := make([]string, 0, len(counts))
+  for tag := range counts {
+    tags = append(tags, tag)
+  }
+  sort.Strings(tags)
+
+  output = "| tag\t| activities\t| duration\t|"
+  for _, tag := range tags {
+    output += fmt.Sprintf("\n| %s\t| %d\t| %s\t|", tag, counts[tag], durations[tag])
+  }
+  return
+}
+
+/*
+ * replace a tag on every user's activities, like rates and goals which are
+ * shared, or remove it if the new tag is empty
+ */
+func replaceTag(db Database, old, new string) (n int, err error) {
+  var activities []*Activity
+  activities, err = allUsers(db).FindAllActivities()
+  if err != nil {
+    return
+  }
+
+  for _, activity := range activities {
+    var tags []string
+    found := false
+    for _, tag := range activity.Tags {
+      if tag == old {
+        found = true
+        tag = new
+      }
+      if tag == "" || containsTag(tags, tag) {
+        continue
+      }
+      tags = append(tags, tag)
+    }
+    if !found {
+      continue
+    }
+    activity.Tags = tags
+    err = db.SaveActivity(activity)
+    if err != nil {
+      return
+    }
+    n++
+  }
+
+  if n == 0 {
+    err = fmt.Errorf("there aren't any activities tagged %s", old)
+  }
+  return
+}
+
+func containsTag(tags []string, tag string) bool {
+  for _, t := range tags {
+    if t == tag {
+      return true
+    }
+  }
+  return false
+}
+
+func renameRateTag(db Database, old, new string) (err error) {
+  var rates []*Rate
+  rates, err = db.FindRates()
+  if err != nil {
+    return
+  }
+  for _, rate := range rates {
+    if rate.Tag != old {
+      continue
+    }
+    err = db.DeleteRate(rate.Project, rate.Tag)
+    if err != nil {
+      return
+    }
+    renamed := *rate
+    renamed.Tag = new
+    err = db.SaveRate(&renamed)
+    if err != nil {
+      return
+    }
+  }
+  return
+}
+
+func renameGoalTag(db Database, old, new string) (err error) {
+  var goals []*Goal
+  goals, err = db.FindGoals()
+  if err != nil {
+    return
+  }
+  for _, goal := range goals {
+    if goal.Tag != old {
+      continue
+    }
+    err = db.DeleteGoal(goal.Project, goal.Tag)
+    if err != nil {
+      return
+    }
+    renamed := *goal
+    renamed.Tag = new
+    err = db.SaveGoal(&renamed)
+    if err != nil {
+      return
+    }
+  }
+  return
+}
@@ -0,0 +1,79 @@
+package hourglass
+
+import (
+  "testing"
+)
+
+var tagsCommandTests = []struct {
+  args []string
+  output string
+  err bool
+}{
+  {
+    []string{"list"},
+    "| tag\t| activities\t| duration\t|\n" +
+    "| client, urgent\t| 1\t| 01h00m\t|\n" +
+    "| meetings\t| 2\t| 03h00m\t|\n" +
+    "| mtg\t| 1\t| 01h00m\t|",
+    false,
+  },
+  {[]string{"rename", "mtg", "meetings"}, "renamed tag mtg to meetings on 1 activities", false},
+  {[]string{"rename", "mtg", "meetings"}, "", true},
+  {[]string{"delete", "client, urgent"}, "deleted tag client, urgent from 1 activities", false},
+  {
+    []string{"list"},
+    "| tag\t| activities\t| duration\t|\n" +
+    "| meetings\t| 2\t| 03h00m\t|",
+    false,
+  },
+  {[]string{"list", "junk"}, "", true},
+  {[]string{"rename", "foo"}, "", true},
+  {[]string{"delete"}, "", true},
+  {[]string{"junk"}, "", true},
+  {nil, "", true},
+}
+
+func TestTagsCommand_Run(t *testing.T) {
+  db := &Memory{}
+  db.SaveActivity(&Activity{Name: "a", Tags: []string{"meetings"},
+    Start: when(2013, 4, 26, 8), End: when(2013, 4, 26, 10)})
+  db.SaveActivity(&Activity{Name: "b", Tags: []string{"mtg", "meetings"},
+    Start: when(2013, 4, 26, 10), End: when(2013, 4, 26, 11)})
+  db.SaveActivity(&Activity{Name: "c", Tags: []string{"client, urgent"},
+    Start: when(2013, 4, 26, 11)})
+  db.SaveRate(&Rate{"", "mtg", Money{5000, "USD"}, true})
+  c := fakeCmdClock{when(2013, 4, 26, 12)}
+
+  for i, config := range tagsCommandTests {
+    output, err := TagsCommand{}.Run(c, db, config.args...)
+    if err != nil {
+      if !config.err {
+        t.Errorf("test %d: %s", i, err)
+      }
+      continue
+    } else if config.err {
+      t.Errorf("test %d: expected error, got nil", i)
+      continue
+    }
+    if output != config.output {
+      t.Errorf("test %d: expected %q, got %q", i, config.output, output)
+    }
+  }
+
+  /* duplicates are merged when renaming */
+  activity, _ := db.FindActivity(2)
+  if len(activity.Tags) != 1 || activity.Tags[0] != "meetings" {
+    t.Errorf("expected [meetings], got %q", activity.Tags)
+  }
+  rates, _ := db.FindRates()
+  if len(rates) != 1 || rates[0].Tag != "meetings" {
+    t.Errorf("expected rate to be renamed, got %+v", rates)
+  }
+}
+
+func TestTagsCommand_Help(t *testing.T) {
+  cmd := TagsCommand{}
+  if cmd.Help() == "" {
+    t.Error("no help available")
+  }
+}
@@ -0,0 +1,202 @@
+package hourglass
+
+import (
+  "fmt"
+  "sort"
+  "strings"
+  "sync"
+)
+
+/* help messages */
+const (
+  templateHelp = "Usage: %s template <add|list|delete> [arguments]\n\nManage activity templates\n\n\tadd <template> <name> [project] [tag1[, tag2[, ...]]]\n\tlist\n\tdelete <template>\n\nStart an activity from a template with \"start @<template>\". Templates are kept in a file next to the database, like ~/.hourglass-templates.csv for ~/.hourglass.db or team-templates.csv for -db team.csv."
+)
+
+/* saved name, project and tags for starting activities */
+type Template struct {
+  Name string
+  ActivityName string
+  Project string
+  Tags []string
+}
+
+/* templates file, stored next to the database */
+type Templates struct {
+  Filename string
+  Mutex sync.RWMutex
+}
+
+func (t *Templates) table() csvTable {
+  return csvTable{t.Filename, &t.Mutex}
+}
+
+func (t *Templates) FindAll() (templates []*Template, err error) {
+  var records [][]string
+  records, err = t.table().readAll()
+  if err != nil {
+    return
+  }
+
+  for _, record := range records {
+    if len(record) < 3 {
+      err = fmt.Errorf("invalid template record: %v", record)
+      return
+    }
+    template := &Template{Name: record[0], ActivityName: record[1],
+      Project: record[2]}
+    if len(record) > 3 {
+      template.Tags = record[3:]
+    }
+    templates = append(templates, template)
+  }
+  return
+}
+
+func (t *Templates) Find(name string) (template *Template, err error) {
+  var templates []*Template
+  templates, err = t.FindAll()
+  if err != nil {
+    return
+  }
+  for _, template = range templates {
+    if template.Name == name {
+      return
+    }
+  }
+  return nil, ErrNotFound
+}
+
+/* add a template, replacing any with the same name */
+func (t *Templates) Save(template *Template) (err error) {
+  var templates []*Template
+  templates, err = t.FindAll()
+  if err != nil {
+    return
+  }
+
+  replaced := false
+  for i, val := range templates {
+    if val.Name == template.Name {
+      templates[i] = template
+      replaced = true
+    }
+  }
+  if !replaced {
+    templates = append(templates, template)
+  }
+  err = t.writeAll(templates)
+  return
+}
+
+func (t *Templates) Delete(name string) (err error) {
+  var templates []*Template
+  templates, err = t.FindAll()
+  if err != nil {
+    return
+  }
+
+  for i, template := range templates {
+    if template.Name == name {
+      templates = append(templates[:i], templates[i+1:]...)
+      err = t.writeAll(templates)
+      return
+    }
+  }
+  return ErrNotFound
+}
+
+func (t *Templates) writeAll(templates []*Template) error {
+  sort.Sort(templateSlice(templates))
+  records := make([][]string, len(templates))
+  for i, template := range templates {
+    record := []string{template.Name, template.ActivityName, template.Project}
+    records[i] = append(record, template.Tags...)
+  }
+  return t.table().writeAll(records)
+}
+
+type templateSlice []*Template
+func (s templateSlice) Len() int {
+  return len(s)
+}
+func (s templateSlice) Less(i, j int) bool {
+  return s[i].Name < s[j].Name
+}
+func (s templateSlice) Swap(i, j int) {
+  s[i], s[j] = s[j], s[i]
+}
+
+/* template */
+type TemplateCommand struct {
+  Templates *Templates
+}
+
+func (cmd TemplateCommand) Run(c Clock, db Database, args ...string) (output string, err error) {
+  if len(args) == 0 {
+    err = SyntaxError("missing subcommand")
+    return
+  }
+  if cmd.Templates == nil {
+    err = fmt.Errorf("templates are not available")
+    return
+  }
+
+  switch args[0] {
+  case "add":
+    if len(args) < 3 {
+      err = SyntaxError("template name and activity name are required")
+      return
+    }
+    name := strings.TrimPrefix(args[1], "@")
+    if name == "" {
+      err = SyntaxError("invalid template name")
+      return
+    }
+    template := &Template{Name: name, ActivityName: args[2]}
+    if len(args) > 3 {
+      template.Project = args[3]
+    }
+    if len(args) > 4 {
+      template.Tags = args[4:]
+    }
+    err = cmd.Templates.Save(template)
+    if err == nil {
+      output = fmt.Sprint("saved template ", name)
+    }
+
+  case "list":
+    var templates []*Template
+    templates, err = cmd.Templates.FindAll()
+    if err != nil {
+      return
+    }
+    if len(templates) == 0 {
+      output = "there aren't any templates"
+      return
+    }
+    output = "| template\t| name\t| project\t| tags\t|"
+    for _, template := range templates {
+      output += fmt.Sprintf("\n| @%s\t| %s\t| %s\t| %s\t|", template.Name,
+        template.ActivityName, template.Project, strings.Join(template.Tags, ", "))
+    }
+
+  case "delete":
+    if len(args) < 2 {
+      err = SyntaxError("missing template name")
+      return
+    }
+    name := strings.TrimPrefix(args[1], "@")
+    err = cmd.Templates.Delete(name)
+    if err == nil {
+      output = fmt.Sprint("deleted template ", name)
+    }
+
+  default:
+    err = SyntaxError("invalid subcommand")
+  }
+  return
+}
+
+func (TemplateCommand) Help() string {
+  return templateHelp
+}
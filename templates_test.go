@@ -0,0 +1,207 @@
+package hourglass
+
+import (
+  "testing"
+  "io/ioutil"
+  "os"
+)
+
+func templatesTestRun(f func (templates *Templates), t *testing.T) {
+  dir, err := ioutil.TempDir("", "hourglass")
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer os.RemoveAll(dir)
+
+  f(&Templates{Filename: dir + "/templates.csv"})
+}
+
+func TestTemplates_FindAll_WithMissingFile(t *testing.T) {
+  f := func (templates *Templates) {
+    found, err := templates.FindAll()
+    if err != nil {
+      t.Error(err)
+    } else if len(found) != 0 {
+      t.Errorf("expected no templates, got %v", found)
+    }
+  }
+  templatesTestRun(f, t)
+}
+
+func TestTemplates_Save(t *testing.T) {
+  f := func (templates *Templates) {
+    template := &Template{"standup", "Daily standup", "teamx", []string{"meeting", "a, b"}}
+    err := templates.Save(template)
+    if err != nil {
+      t.Error(err)
+      return
+    }
+
+    /* saving with the same name replaces the template */
+    template = &Template{"standup", "Standup", "teamy", nil}
+    err = templates.Save(template)
+    if err != nil {
+      t.Error(err)
+      return
+    }
+    err = templates.Save(&Template{Name: "lunch", ActivityName: "Lunch"})
+    if err != nil {
+      t.Error(err)
+      return
+    }
+
+    var found []*Template
+    found, err = templates.FindAll()
+    if err != nil {
+      t.Error(err)
+      return
+    }
+    if len(found) != 2 {
+      t.Errorf("expected 2 templates, got %d", len(found))
+      return
+    }
+    if found[0].Name != "lunch" || found[1].Name != "standup" {
+      t.Errorf("expected templates to be sorted, got %v, %v", found[0], found[1])
+    }
+    if found[1].ActivityName != "Standup" || found[1].Project != "teamy" || len(found[1].Tags) != 0 {
+      t.Errorf("expected %v, got %v", template, found[1])
+    }
+  }
+  templatesTestRun(f, t)
+}
+
+func TestTemplates_Find(t *testing.T) {
+  f := func (templates *Templates) {
+    template := &Template{"standup", "Daily standup", "teamx", []string{"meeting", "a, b"}}
+    err := templates.Save(template)
+    if err != nil {
+      t.Error(err)
+      return
+    }
+
+    var found *Template
+    found, err = templates.Find("standup")
+    if err != nil {
+      t.Error(err)
+      return
+    }
+    if found.ActivityName != template.ActivityName || found.Project != template.Project ||
+      len(found.Tags) != 2 || found.Tags[1] != "a, b" {
+      t.Errorf("expected %v, got %v", template, found)
+    }
+
+    _, err = templates.Find("junk")
+    if err != ErrNotFound {
+      t.Errorf("expected ErrNotFound, got %v", err)
+    }
+  }
+  templatesTestRun(f, t)
+}
+
+func TestTemplates_Delete(t *testing.T) {
+  f := func (templates *Templates) {
+    err := templates.Save(&Template{Name: "standup", ActivityName: "Daily standup"})
+    if err != nil {
+      t.Error(err)
+      return
+    }
+
+    err = templates.Delete("standup")
+    if err != nil {
+      t.Error(err)
+    }
+    _, err = templates.Find("standup")
+    if err != ErrNotFound {
+      t.Errorf("expected ErrNotFound, got %v", err)
+    }
+
+    err = templates.Delete("standup")
+    if err != ErrNotFound {
+      t.Errorf("expected ErrNotFound, got %v", err)
+    }
+  }
+  templatesTestRun(f, t)
+}
+
+/* template command tests */
+var templateCommandTests = []struct {
+  args [][]string
+  output string
+  err bool
+  syntaxErr bool
+}{
+  /* test 0: no subcommand */
+  {[][]string{nil}, "", true, true},
+
+  /* test 1: bad subcommand */
+  {[][]string{{"foo"}}, "", true, true},
+
+  /* test 2: add without activity name */
+  {[][]string{{"add", "standup"}}, "", true, true},
+
+  /* test 3: add */
+  {[][]string{{"add", "standup", "Daily standup", "teamx", "meeting"}}, "saved template standup", false, false},
+
+  /* test 4: list */
+  {
+    [][]string{{"add", "@standup", "Daily standup", "teamx", "meeting", "team"}, {"list"}},
+    "| template\t| name\t| project\t| tags\t|\n" +
+    "| @standup\t| Daily standup\t| teamx\t| meeting, team\t|",
+    false, false,
+  },
+
+  /* test 5: list with no templates */
+  {[][]string{{"list"}}, "there aren't any templates", false, false},
+
+  /* test 6: delete */
+  {[][]string{{"add", "standup", "Daily standup"}, {"delete", "@standup"}}, "deleted template standup", false, false},
+
+  /* test 7: delete missing template */
+  {[][]string{{"delete", "standup"}}, "", true, false},
+}
+
+func TestTemplateCommand_Run(t *testing.T) {
+  for testNum, config := range templateCommandTests {
+    f := func (templates *Templates) {
+      cmd := TemplateCommand{templates}
+      db := &Memory{}
+      c := fakeCmdClock{when(2013, 4, 26, 9)}
+
+      var output string
+      var err error
+      for _, args := range config.args {
+        output, err = cmd.Run(c, db, args...)
+      }
+
+      outputOk, diff, checkErr := checkStringsEqual(config.output, output)
+      if !outputOk {
+        if err == nil {
+          t.Errorf("test %d: bad output:\n%s", testNum, diff)
+        } else {
+          t.Errorf("test %d: output didn't match, but couldn't create diff: %s", testNum, checkErr)
+        }
+      }
+
+      if err != nil {
+        if !config.err {
+          t.Errorf("test %d: %s", testNum, err)
+        } else if config.syntaxErr {
+          _, ok := err.(SyntaxError)
+          if !ok {
+            t.Errorf("test %d: expected error type SyntaxError, got %T", testNum, err)
+          }
+        }
+      } else if config.err {
+        t.Errorf("test %d: expected error, got nil", testNum)
+      }
+    }
+    templatesTestRun(f, t)
+  }
+}
+
+func TestTemplateCommand_Help(t *testing.T) {
+  cmd := TemplateCommand{}
+  if cmd.Help() == "" {
+    t.Error("no help available")
+  }
+}
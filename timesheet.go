@@ -0,0 +1,194 @@
+package hourglass
+
+import (
+  "bytes"
+  "encoding/csv"
+  "flag"
+  "fmt"
+  "sort"
+  "strings"
+  "time"
+)
+
+/* help messages */
+const (
+  timesheetHelp = "Usage: %s timesheet [--round <unit>] [--mode up|down|nearest] [--per entry|day] [--format text|csv] [--user <name> | --all-users] [today|week|all|<from> <to>]\n\nSummarize time per day and project for billing\n\nDurations are rounded to a multiple of the unit, either for each activity or for each day's total. The default range is this week. Dates are formatted like 2006-01-02. Only your own activities are included unless another user is given with --user or --all-users is used."
+)
+
+/* one line of a timesheet */
+type timesheetRow struct {
+  date time.Time
+  project string
+  names []string
+  actual Duration
+  billed Duration
+}
+
+func (row *timesheetRow) addName(name string) {
+  for _, n := range row.names {
+    if n == name {
+      return
+    }
+  }
+  row.names = append(row.names, name)
+}
+
+type timesheetRows []*timesheetRow
+
+func (rows timesheetRows) Len() int {
+  return len(rows)
+}
+func (rows timesheetRows) Less(i, j int) bool {
+  if !rows[i].date.Equal(rows[j].date) {
+    return rows[i].date.Before(rows[j].date)
+  }
+  /* unsorted last, like project totals */
+  if rows[i].project == "" {
+    return false
+  } else if rows[j].project == "" {
+    return true
+  }
+  return rows[i].project < rows[j].project
+}
+func (rows timesheetRows) Swap(i, j int) {
+  rows[i], rows[j] = rows[j], rows[i]
+}
+
+/* timesheet */
+type TimesheetCommand struct {
+  Unit time.Duration
+  Mode string
+  Per string
+  Format string
+  UserOptions
+}
+
+func (cmd *TimesheetCommand) Flags(fs *flag.FlagSet) {
+  fs.DurationVar(&cmd.Unit, "round", 0, "round durations to a multiple of this, like 6m or 15m")
+  fs.StringVar(&cmd.Mode, "mode", "up", "rounding direction: up, down or nearest")
+  fs.StringVar(&cmd.Per, "per", "entry", "round each activity (entry) or each day's total (day)")
+  fs.StringVar(&cmd.Format, "format", "text", "output format: text or csv")
+  cmd.flags(fs)
+}
+
+func parseRoundingMode(mode string) (RoundingMode, error) {
+  switch mode {
+  case "", "up":
+    return RoundUp, nil
+  case "down":
+    return RoundDown, nil
+  case "nearest":
+    return RoundNearest, nil
+  }
+  return 0, SyntaxError(fmt.Sprint("invalid rounding mode: ", mode))
+}
+
+func (cmd TimesheetCommand) Run(c Clock, db Database, args ...string) (output string, err error) {
+  var mode RoundingMode
+  mode, err = parseRoundingMode(cmd.Mode)
+  if err != nil {
+    return
+  }
+  if cmd.Per != "" && cmd.Per != "entry" && cmd.Per != "day" {
+    err = SyntaxError(fmt.Sprint("invalid rounding period: ", cmd.Per))
+    return
+  }
+  if cmd.Format != "" && cmd.Format != "text" && cmd.Format != "csv" {
+    err = SyntaxError(fmt.Sprint("unsupported format: ", cmd.Format))
+    return
+  }
+  if cmd.Unit < 0 {
+    err = SyntaxError("rounding unit must be positive")
+    return
+  }
+
+  if len(args) == 0 {
+    args = []string{"week"}
+  }
+  var lower, upper time.Time
+  lower, upper, err = parseRange(c.Now(), args)
+  if err != nil {
+    return
+  }
+  var activities []*Activity
+  activities, err = findActivitiesInRange(cmd.database(db), lower, upper)
+  if err != nil {
+    return
+  }
+  activities = cmd.filter(activities)
+
+  rows := timesheetRows{}
+  index := make(map[string]*timesheetRow)
+  for _, activity := range activities {
+    start := c.Local(activity.Start)
+    date := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, start.Location())
+    key := date.Format(QueryDateFormat) + "\x00" + activity.Project
+    row, ok := index[key]
+    if !ok {
+      row = &timesheetRow{date: date, project: activity.Project}
+      index[key] = row
+      rows = append(rows, row)
+    }
+
+    duration := activity.Duration(c)
+    row.actual += duration
+    if cmd.Per != "day" {
+      row.billed += duration.Round(cmd.Unit, mode)
+    }
+    row.addName(activity.Name)
+  }
+  if cmd.Per == "day" {
+    for _, row := range rows {
+      row.billed = row.actual.Round(cmd.Unit, mode)
+    }
+  }
+  sort.Sort(rows)
+
+  if cmd.Format == "csv" {
+    output, err = rows.csv()
+  } else {
+    output = rows.text()
+  }
+  return
+}
+
+func (TimesheetCommand) Help() string {
+  return timesheetHelp
+}
+
+func (rows timesheetRows) csv() (output string, err error) {
+  buf := new(bytes.Buffer)
+  w := csv.NewWriter(buf)
+  w.Write([]string{"date", "project", "description", "duration", "billed", "hours"})
+  for _, row := range rows {
+    w.Write([]string{row.date.Format(QueryDateFormat), row.project,
+      strings.Join(row.names, ", "), row.actual.String(), row.billed.String(),
+      row.billed.Hours()})
+  }
+  w.Flush()
+  err = w.Error()
+  output = strings.TrimSuffix(buf.String(), "\n")
+  return
+}
+
+func (rows timesheetRows) text() (output string) {
+  if len(rows) == 0 {
+    return "there are no activities in this range"
+  }
+
+  var actual, billed Duration
+  output = "| date\t| project\t| description\t| duration\t| billed\t| hours\t|"
+  for _, row := range rows {
+    project := row.project
+    if project == "" {
+      project = "unsorted"
+    }
+    output += fmt.Sprintf("\n| %s\t| %s\t| %s\t| %s\t| %s\t| %s\t|",
+      row.date.Format(QueryDateFormat), project, strings.Join(row.names, ", "),
+      row.actual, row.billed, row.billed.Hours())
+    actual += row.actual
+    billed += row.billed
+  }
+  output += fmt.Sprintf("\ntotal: %s, billed: %s (%s hours)", actual, billed, billed.Hours())
+  return
+}
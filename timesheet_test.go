@@ -0,0 +1,131 @@
+package hourglass
+
+import (
+  "testing"
+  "time"
+)
+
+func timesheetTestDb() *Memory {
+  db := &Memory{}
+  minutes := func(day, hour, minute int) time.Time {
+    return when(2013, 4, day, hour).Add(time.Duration(minute) * time.Minute)
+  }
+  db.SaveActivity(&Activity{Name: "foo", Project: "bar", Start: minutes(25, 9, 0), End: minutes(25, 9, 7)})
+  db.SaveActivity(&Activity{Name: "baz", Project: "bar", Start: minutes(25, 10, 0), End: minutes(25, 10, 7)})
+  db.SaveActivity(&Activity{Name: "foo", Start: minutes(25, 11, 0), End: minutes(25, 11, 50)})
+  db.SaveActivity(&Activity{Name: "foo", Project: "bar", Start: minutes(26, 9, 0), End: minutes(26, 10, 1)})
+  db.SaveActivity(&Activity{Name: "qux", Project: "bar", Start: minutes(20, 9, 0), End: minutes(20, 10, 0)})
+  return db
+}
+
+var timesheetTests = []struct {
+  cmd TimesheetCommand
+  args []string
+  output string
+  err bool
+}{
+  /* test 0: no rounding */
+  {
+    TimesheetCommand{}, nil,
+    "| date\t| project\t| description\t| duration\t| billed\t| hours\t|\n" +
+    "| 2013-04-25\t| bar\t| foo, baz\t| 00h14m\t| 00h14m\t| 0.23\t|\n" +
+    "| 2013-04-25\t| unsorted\t| foo\t| 00h50m\t| 00h50m\t| 0.83\t|\n" +
+    "| 2013-04-26\t| bar\t| foo\t| 01h01m\t| 01h01m\t| 1.02\t|\n" +
+    "total: 02h05m, billed: 02h05m (2.08 hours)",
+    false,
+  },
+
+  /* test 1: round each entry up */
+  {
+    TimesheetCommand{Unit: 15 * time.Minute, Mode: "up"}, []string{"2013-04-25", "2013-04-25"},
+    "| date\t| project\t| description\t| duration\t| billed\t| hours\t|\n" +
+    "| 2013-04-25\t| bar\t| foo, baz\t| 00h14m\t| 00h30m\t| 0.50\t|\n" +
+    "| 2013-04-25\t| unsorted\t| foo\t| 00h50m\t| 01h00m\t| 1.00\t|\n" +
+    "total: 01h04m, billed: 01h30m (1.50 hours)",
+    false,
+  },
+
+  /* test 2: round each day up */
+  {
+    TimesheetCommand{Unit: 15 * time.Minute, Mode: "up", Per: "day"}, []string{"2013-04-25", "2013-04-25"},
+    "| date\t| project\t| description\t| duration\t| billed\t| hours\t|\n" +
+    "| 2013-04-25\t| bar\t| foo, baz\t| 00h14m\t| 00h15m\t| 0.25\t|\n" +
+    "| 2013-04-25\t| unsorted\t| foo\t| 00h50m\t| 01h00m\t| 1.00\t|\n" +
+    "total: 01h04m, billed: 01h15m (1.25 hours)",
+    false,
+  },
+
+  /* test 3: round to nearest 6 minutes as csv */
+  {
+    TimesheetCommand{Unit: 6 * time.Minute, Mode: "nearest", Format: "csv"}, []string{"all"},
+    "date,project,description,duration,billed,hours\n" +
+    "2013-04-20,bar,qux,01h00m,01h00m,1.00\n" +
+    "2013-04-25,bar,\"foo, baz\",00h14m,00h12m,0.20\n" +
+    "2013-04-25,,foo,00h50m,00h48m,0.80\n" +
+    "2013-04-26,bar,foo,01h01m,01h00m,1.00",
+    false,
+  },
+
+  /* test 4: empty range */
+  {TimesheetCommand{}, []string{"2013-01-01", "2013-01-02"}, "there are no activities in this range", false},
+
+  /* test 5: invalid mode */
+  {TimesheetCommand{Mode: "sideways"}, nil, "", true},
+
+  /* test 6: invalid period */
+  {TimesheetCommand{Per: "month"}, nil, "", true},
+
+  /* test 7: invalid format */
+  {TimesheetCommand{Format: "pdf"}, nil, "", true},
+
+  /* test 8: invalid range */
+  {TimesheetCommand{}, []string{"junk"}, "", true},
+}
+
+func TestTimesheetCommand_Run(t *testing.T) {
+  db := timesheetTestDb()
+  c := fakeCmdClock{when(2013, 4, 26, 12)}
+  for i, config := range timesheetTests {
+    output, err := config.cmd.Run(c, db, config.args...)
+    if err != nil {
+      if !config.err {
+        t.Errorf("test %d: %s", i, err)
+      } else if _, ok := err.(SyntaxError); !ok {
+        t.Errorf("test %d: expected error type SyntaxError, got %T", i, err)
+      }
+      continue
+    } else if config.err {
+      t.Errorf("test %d: expected error, got nil", i)
+      continue
+    }
+    outputOk, diff, checkErr := checkStringsEqual(config.output, output)
+    if !outputOk {
+      if checkErr == nil {
+        t.Errorf("test %d: bad output:\n%s", i, diff)
+      } else {
+        t.Errorf("test %d: output didn't match, but couldn't create diff: %s", i, checkErr)
+      }
+    }
+  }
+}
+
+func TestTimesheetCommand_Flags(t *testing.T) {
+  info := &CommandInfo{Name: "timesheet", Command: &TimesheetCommand{}}
+  c := fakeCmdClock{when(2013, 4, 26, 12)}
+  output, err := info.Run(c, timesheetTestDb(), "--round", "1h", "--per", "day", "--format", "csv", "today")
+  if err != nil {
+    t.Error(err)
+    return
+  }
+  expected := "date,project,description,duration,billed,hours\n2013-04-26,bar,foo,01h01m,02h00m,2.00"
+  if output != expected {
+    t.Errorf("expected %q, got %q", expected, output)
+  }
+}
+
+func TestTimesheetCommand_Help(t *testing.T) {
+  cmd := TimesheetCommand{}
+  if cmd.Help() == "" {
+    t.Error("no help available")
+  }
+}
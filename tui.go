@@ -0,0 +1,330 @@
+package hourglass
+
+import (
+  "bytes"
+  "fmt"
+  "io"
+  "os"
+  "os/exec"
+  "strconv"
+  "strings"
+  "text/tabwriter"
+  "time"
+)
+
+/* help messages */
+const (
+  tuiHelp = "Usage: %s tui\n\nShow today's activities in an interactive terminal view\n\nKeys:\n\tj, k\tmove the selection down and up\n\ts\tstart an activity\n\tx\tstop the selected activity\n\tr\trestart the selected activity\n\te\tedit the selected activity\n\td\tdelete the selected activity\n\tq\tquit"
+)
+
+/* terminal escape sequences */
+const (
+  tuiClear = "\x1b[H\x1b[2J"
+  tuiReverse = "\x1b[7m"
+  tuiReset = "\x1b[0m"
+  tuiEnterScreen = "\x1b[?1049h\x1b[?25l"
+  tuiExitScreen = "\x1b[?25h\x1b[?1049l"
+)
+
+/* keys */
+const (
+  keyBackspace = 127
+  keyCtrlH = 8
+  keyCtrlC = 3
+  keyEnter = 13
+  keyNewline = 10
+  keyEscape = 27
+)
+
+/* how often the running timers are redrawn */
+const TuiTick = time.Second
+
+/* state of the terminal ui, apart from the terminal itself */
+type Tui struct {
+  Clock Clock
+  Database Database
+  Templates *Templates
+  MaxRunning time.Duration
+  /* only allow registered projects */
+  Strict bool
+
+  activities []*Activity
+  rates []*Rate
+  selected int
+  message string
+  prompt string
+  input string
+  submit func(string) (string, error)
+  quit bool
+}
+
+/* load today's activities, keeping the selection in range */
+func (t *Tui) Refresh() (err error) {
+  lower, upper := dayRange(t.Clock.Now())
+  t.activities, err = t.Database.FindActivitiesBetween(lower, upper)
+  if err != nil {
+    return
+  }
+  t.rates, err = t.Database.FindRates()
+  if err != nil {
+    return
+  }
+  if t.selected >= len(t.activities) {
+    t.selected = len(t.activities) - 1
+  }
+  if t.selected < 0 {
+    t.selected = 0
+  }
+  return
+}
+
+func (t *Tui) Selected() *Activity {
+  if len(t.activities) == 0 {
+    return nil
+  }
+  return t.activities[t.selected]
+}
+
+func (t *Tui) Quit() bool {
+  return t.quit
+}
+
+/* draw the whole screen */
+func (t *Tui) Render() string {
+  buf := new(bytes.Buffer)
+  fmt.Fprintf(buf, "hourglass - %s\n\n", t.Clock.Now().Format("Mon Jan 02 15:04:05"))
+
+  table := &activityTable{t.activities, t.Clock, tableModeDay, t.MaxRunning, t.rates, false}
+  tableBuf := new(bytes.Buffer)
+  writer := tabwriter.NewWriter(tableBuf, 0, 0, 1, ' ', 0)
+  fmt.Fprint(writer, table.String())
+  writer.Flush()
+
+  /* the first line is the header, then one line per activity */
+  for i, line := range strings.Split(tableBuf.String(), "\n") {
+    if i > 0 && i - 1 == t.selected && i <= len(t.activities) {
+      fmt.Fprintf(buf, "%s%s%s\n", tuiReverse, line, tuiReset)
+    } else {
+      fmt.Fprintln(buf, line)
+    }
+  }
+
+  fmt.Fprintln(buf)
+  if t.prompt != "" {
+    fmt.Fprintf(buf, "%s%s", t.prompt, t.input)
+  } else {
+    if t.message != "" {
+      fmt.Fprintln(buf, t.message)
+    }
+    fmt.Fprint(buf, "j/k: move  s: start  x: stop  r: restart  e: edit  d: delete  q: quit")
+  }
+  return buf.String()
+}
+
+/* handle a single key press */
+func (t *Tui) HandleKey(key byte) {
+  if t.prompt != "" {
+    t.handlePromptKey(key)
+    return
+  }
+
+  t.message = ""
+  activity := t.Selected()
+  switch key {
+  case 'q', keyCtrlC:
+    t.quit = true
+  case 'j':
+    if t.selected < len(t.activities) - 1 {
+      t.selected++
+    }
+  case 'k':
+    if t.selected > 0 {
+      t.selected--
+    }
+  case 's':
+    t.ask("start (name [project] [tags] or @template): ", func(input string) (string, error) {
+      return StartCommand{Templates: t.Templates, Strict: t.Strict}.Run(t.Clock, t.Database, strings.Fields(input)...)
+    })
+  case 'x':
+    if activity != nil {
+      t.run(t.stopActivity(activity))
+    }
+  case 'r':
+    if activity != nil {
+      t.run(RestartCommand{}.Run(t.Clock, t.Database, activityId(activity)))
+    }
+  case 'e':
+    if activity != nil {
+      t.ask(fmt.Sprintf("edit activity %d (name|project|tags|start|end value): ", activity.Id),
+        func(input string) (string, error) {
+          args := append([]string{activityId(activity)}, strings.Fields(input)...)
+          return EditCommand{Strict: t.Strict}.Run(t.Clock, t.Database, args...)
+        })
+    }
+  case 'd':
+    if activity != nil {
+      t.ask(fmt.Sprintf("delete activity %d? (y/n) ", activity.Id), func(input string) (string, error) {
+        if input != "y" && input != "yes" {
+          return "", nil
+        }
+        return DeleteCommand{}.Run(t.Clock, t.Database, activityId(activity))
+      })
+    }
+  }
+}
+
+func (t *Tui) handlePromptKey(key byte) {
+  switch key {
+  case keyEnter, keyNewline:
+    submit := t.submit
+    input := t.input
+    t.prompt, t.input, t.submit = "", "", nil
+    t.run(submit(input))
+  case keyEscape, keyCtrlC:
+    t.prompt, t.input, t.submit = "", "", nil
+  case keyBackspace, keyCtrlH:
+    if len(t.input) > 0 {
+      t.input = t.input[:len(t.input) - 1]
+    }
+  default:
+    if key >= ' ' && key < keyBackspace {
+      t.input += string(key)
+    }
+  }
+}
+
+func (t *Tui) ask(prompt string, submit func(string) (string, error)) {
+  t.prompt = prompt
+  t.input = ""
+  t.submit = submit
+}
+
+/* show the result of a command and reload */
+func (t *Tui) run(output string, err error) {
+  if err != nil {
+    t.message = err.Error()
+  } else {
+    t.message = output
+  }
+  err = t.Refresh()
+  if err != nil {
+    t.message = err.Error()
+  }
+}
+
+func (t *Tui) stopActivity(activity *Activity) (output string, err error) {
+  if !activity.IsRunning() {
+    err = fmt.Errorf("activity %d isn't running", activity.Id)
+    return
+  }
+  activity.Stop(t.Clock.Now())
+  err = t.Database.SaveActivity(activity)
+  if err == nil {
+    output = fmt.Sprint("stopped activity ", activity.Id)
+  }
+  return
+}
+
+func activityId(activity *Activity) string {
+  return strconv.FormatInt(activity.Id, 10)
+}
+
+/* redraw on every key press and clock tick until quitting */
+func (t *Tui) Loop(keys <-chan byte, out io.Writer) (err error) {
+  err = t.Refresh()
+  if err != nil {
+    return
+  }
+  for !t.quit {
+    /* raw mode doesn't translate newlines */
+    screen := strings.Replace(t.Render(), "\n", "\r\n", -1)
+    _, err = fmt.Fprint(out, tuiClear, screen)
+    if err != nil {
+      return
+    }
+
+    select {
+    case key, ok := <-keys:
+      if !ok {
+        return
+      }
+      t.HandleKey(key)
+    case <-after(t.Clock, TuiTick):
+    }
+  }
+  return
+}
+
+/* read key presses, turning arrow keys into j and k */
+func readKeys(in io.Reader, keys chan<- byte) {
+  defer close(keys)
+  buf := make([]byte, 16)
+  for {
+    n, err := in.Read(buf)
+    if err != nil {
+      return
+    }
+    switch string(buf[:n]) {
+    case "\x1b[A":
+      keys <- 'k'
+    case "\x1b[B":
+      keys <- 'j'
+    default:
+      for _, key := range buf[:n] {
+        keys <- key
+      }
+    }
+  }
+}
+
+/* terminal settings */
+func stty(args ...string) (output string, err error) {
+  cmd := exec.Command("stty", args...)
+  cmd.Stdin = os.Stdin
+  var out []byte
+  out, err = cmd.Output()
+  output = strings.TrimSpace(string(out))
+  return
+}
+
+/* tui */
+type TuiCommand struct {
+  Templates *Templates
+  MaxRunning time.Duration
+  Strict bool
+}
+
+func (cmd TuiCommand) Run(c Clock, db Database, args ...string) (output string, err error) {
+  if len(args) > 0 {
+    err = SyntaxError("too many arguments")
+    return
+  }
+
+  var state string
+  state, err = stty("-g")
+  if err != nil {
+    err = fmt.Errorf("couldn't read terminal settings: %s", err)
+    return
+  }
+  _, err = stty("raw", "-echo")
+  if err != nil {
+    err = fmt.Errorf("couldn't set up terminal: %s", err)
+    return
+  }
+  defer stty(state)
+
+  fmt.Fprint(os.Stdout, tuiEnterScreen)
+  defer fmt.Fprint(os.Stdout, tuiExitScreen)
+
+  keys := make(chan byte)
+  go readKeys(os.Stdin, keys)
+
+  t := &Tui{Clock: c, Database: db, Templates: cmd.Templates, MaxRunning: cmd.MaxRunning,
+    Strict: cmd.Strict}
+  err = t.Loop(keys, os.Stdout)
+  return
+}
+
+func (TuiCommand) Help() string {
+  return tuiHelp
+}
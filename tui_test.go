@@ -0,0 +1,170 @@
+package hourglass
+
+import (
+  "testing"
+  "bytes"
+  "strings"
+)
+
+func newTestTui(activities ...*Activity) (*Tui, *Memory) {
+  db := &Memory{}
+  for _, activity := range activities {
+    db.SaveActivity(activity)
+  }
+  t := &Tui{Clock: fakeCmdClock{when(2013, 4, 26, 12)}, Database: db}
+  t.Refresh()
+  return t, db
+}
+
+func tuiTestKeys(t *Tui, keys string) {
+  for i := 0; i < len(keys); i++ {
+    t.HandleKey(keys[i])
+  }
+}
+
+func TestTui_Render(t *testing.T) {
+  tui, _ := newTestTui(
+    &Activity{Name: "foo", Start: when(2013, 4, 26, 8), End: when(2013, 4, 26, 9)},
+    &Activity{Name: "bar", Project: "baz", Start: when(2013, 4, 26, 10)},
+    &Activity{Name: "qux", Start: when(2013, 4, 25, 10), End: when(2013, 4, 25, 11)},
+  )
+
+  screen := tui.Render()
+  for _, expected := range []string{"Fri Apr 26 12:00:00", "| id", "baz: 02h00m", "q: quit"} {
+    if !strings.Contains(screen, expected) {
+      t.Errorf("expected screen to contain %q:\n%s", expected, screen)
+    }
+  }
+  if strings.Contains(screen, "qux") {
+    t.Errorf("expected only today's activities:\n%s", screen)
+  }
+
+  lines := strings.Split(screen, "\n")
+  if !strings.HasPrefix(lines[3], tuiReverse) || !strings.Contains(lines[3], "foo") {
+    t.Errorf("expected first activity to be selected: %q", lines[3])
+  }
+
+  tuiTestKeys(tui, "jjj")
+  if tui.Selected().Id != 2 {
+    t.Errorf("expected activity 2 to be selected, got %d", tui.Selected().Id)
+  }
+  tuiTestKeys(tui, "kk")
+  if tui.Selected().Id != 1 {
+    t.Errorf("expected activity 1 to be selected, got %d", tui.Selected().Id)
+  }
+}
+
+func TestTui_Start(t *testing.T) {
+  tui, db := newTestTui()
+  if tui.Selected() != nil {
+    t.Error("expected no selection")
+  }
+
+  tuiTestKeys(tui, "sfoo bar bazz\x7f\r")
+  expected := &Activity{Id: 1, Name: "foo", Project: "bar", Tags: []string{"baz"},
+    Start: when(2013, 4, 26, 12)}
+  if !expected.Equal(db.activities[1]) {
+    t.Errorf("expected %v, got %v", expected, db.activities[1])
+  }
+  if tui.Selected() == nil || tui.Selected().Id != 1 {
+    t.Error("expected new activity to be selected")
+  }
+  if !strings.Contains(tui.Render(), "started activity 1") {
+    t.Error("expected message on screen")
+  }
+
+  /* only registered projects when strict */
+  tui.Strict = true
+  tuiTestKeys(tui, "squx unknown\r")
+  if len(db.activities) != 1 || !strings.Contains(tui.Render(), "unknown project") {
+    t.Error("expected unknown project to be refused")
+  }
+  tui.Strict = false
+
+  /* escape cancels */
+  tuiTestKeys(tui, "sjunk\x1b")
+  if len(db.activities) != 1 {
+    t.Error("expected prompt to be cancelled")
+  }
+}
+
+func TestTui_StopRestartEditDelete(t *testing.T) {
+  tui, db := newTestTui(&Activity{Name: "foo", Start: when(2013, 4, 26, 9)})
+
+  tuiTestKeys(tui, "x")
+  if !db.activities[1].End.Equal(when(2013, 4, 26, 12)) {
+    t.Errorf("expected activity to be stopped, got %v", db.activities[1])
+  }
+  tuiTestKeys(tui, "x")
+  if !strings.Contains(tui.Render(), "activity 1 isn't running") {
+    t.Error("expected error message on screen")
+  }
+
+  tuiTestKeys(tui, "r")
+  if len(db.activities) != 2 || !db.activities[2].IsRunning() {
+    t.Errorf("expected activity to be restarted: %v", db.activities)
+  }
+
+  tui, db = newTestTui(
+    &Activity{Name: "foo", Start: when(2013, 4, 26, 9), End: when(2013, 4, 26, 10)},
+    &Activity{Name: "foo", Start: when(2013, 4, 26, 11)},
+  )
+  tuiTestKeys(tui, "ename bar baz\r")
+  if db.activities[1].Name != "bar baz" {
+    t.Errorf("expected name to be edited, got %q", db.activities[1].Name)
+  }
+  tuiTestKeys(tui, "ejunk\r")
+  if !strings.Contains(tui.Render(), "invalid field name") {
+    t.Error("expected error message on screen")
+  }
+
+  tuiTestKeys(tui, "dn\r")
+  if len(db.activities) != 2 {
+    t.Error("expected delete to be cancelled")
+  }
+  tuiTestKeys(tui, "dy\r")
+  if _, ok := db.activities[1]; ok {
+    t.Error("expected activity to be deleted")
+  }
+  if tui.Selected() == nil || tui.Selected().Id != 2 {
+    t.Error("expected selection to move to the remaining activity")
+  }
+}
+
+func TestTui_Loop(t *testing.T) {
+  tui, _ := newTestTui(&Activity{Name: "foo", Start: when(2013, 4, 26, 9)})
+  keys := make(chan byte, 2)
+  keys <- 'j'
+  keys <- 'q'
+  out := new(bytes.Buffer)
+
+  err := tui.Loop(keys, out)
+  if err != nil {
+    t.Error(err)
+  }
+  if !tui.Quit() {
+    t.Error("expected loop to stop after quitting")
+  }
+  if !strings.Contains(out.String(), tuiClear) || strings.Contains(out.String(), "foo\n") {
+    t.Error("expected screen to be drawn for a raw terminal")
+  }
+}
+
+func TestReadKeys(t *testing.T) {
+  keys := make(chan byte, 10)
+  readKeys(strings.NewReader("\x1b[B"), keys)
+  var result []byte
+  for key := range keys {
+    result = append(result, key)
+  }
+  if string(result) != "j" {
+    t.Errorf("expected %q, got %q", "j", result)
+  }
+}
+
+func TestTuiCommand_Help(t *testing.T) {
+  cmd := TuiCommand{}
+  if cmd.Help() == "" {
+    t.Error("no help available")
+  }
+}
@@ -0,0 +1,105 @@
+package hourglass
+
+import (
+  "flag"
+  "time"
+)
+
+/*
+ * Database shared by several people. New activities, including restarts of
+ * someone else's, are recorded as the user's and only the user's own activities are found, so commands like
+ * stop, start --last and the reports in serve leave everyone else's alone.
+ * Activities without a user are from before users were tracked and belong
+ * to everyone. Activities can still be found by id.
+ */
+type UserDatabase struct {
+  Database
+  User string
+}
+
+func (db *UserDatabase) Unwrap() Database {
+  return db.Database
+}
+
+func (db *UserDatabase) SaveActivity(a *Activity) error {
+  if a.Id == 0 && db.User != "" {
+    a.User = db.User
+  }
+  return db.Database.SaveActivity(a)
+}
+
+func (db *UserDatabase) FindAllActivities() (activities []*Activity, err error) {
+  activities, err = db.Database.FindAllActivities()
+  if err == nil {
+    activities = filterUser(activities, db.User)
+  }
+  return
+}
+
+func (db *UserDatabase) FindRunningActivities() (activities []*Activity, err error) {
+  activities, err = db.Database.FindRunningActivities()
+  if err == nil {
+    activities = filterUser(activities, db.User)
+  }
+  return
+}
+
+func (db *UserDatabase) FindActivitiesBetween(lower, upper time.Time) (activities []*Activity, err error) {
+  activities, err = db.Database.FindActivitiesBetween(lower, upper)
+  if err == nil {
+    activities = filterUser(activities, db.User)
+  }
+  return
+}
+
+/* activities by the user, or all of them for an empty user */
+func filterUser(activities []*Activity, user string) (result []*Activity) {
+  if user == "" {
+    return activities
+  }
+  for _, activity := range activities {
+    if activity.User == "" || activity.User == user {
+      result = append(result, activity)
+    }
+  }
+  return
+}
+
+/* options for commands that report on activities */
+type UserOptions struct {
+  /* whose activities to show, the current user by default */
+  User string
+  AllUsers bool
+}
+
+func (o *UserOptions) flags(fs *flag.FlagSet) {
+  fs.StringVar(&o.User, "user", o.User, "only show activities by this user")
+  fs.BoolVar(&o.AllUsers, "all-users", false, "show activities by every user")
+}
+
+/* the database without the user filter, since the options choose whose activities to show */
+func (o *UserOptions) database(db Database) Database {
+  return allUsers(db)
+}
+
+/* the database underneath any UserDatabase, which finds every user's activities */
+func allUsers(db Database) Database {
+  for inner := db; inner != nil; {
+    if userDb, ok := inner.(*UserDatabase); ok {
+      return userDb.Database
+    }
+    wrapper, ok := inner.(DatabaseWrapper)
+    if !ok {
+      break
+    }
+    inner = wrapper.Unwrap()
+  }
+  return db
+}
+
+func (o *UserOptions) filter(activities []*Activity) []*Activity {
+  if o.AllUsers {
+    return activities
+  }
+  return filterUser(activities, o.User)
+}
@@ -0,0 +1,213 @@
+package hourglass
+
+import (
+  "testing"
+  "net/http"
+  "strings"
+)
+
+func TestUserDatabase(t *testing.T) {
+  fake := &Memory{}
+  fake.SaveActivity(&Activity{Name: "old", Start: when(2013, 4, 26, 8)})
+  fake.SaveActivity(&Activity{Name: "theirs", User: "bob", Start: when(2013, 4, 26, 9)})
+  db := &UserDatabase{Database: fake, User: "alice"}
+
+  activity := &Activity{Name: "mine", Start: when(2013, 4, 26, 10)}
+  err := db.SaveActivity(activity)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if fake.activities[3].User != "alice" {
+    t.Errorf("expected new activity to be alice's, got %q", fake.activities[3].User)
+  }
+
+  /* editing someone else's activity doesn't take it over */
+  theirs, _ := db.FindActivity(2)
+  theirs.Name = "renamed"
+  db.SaveActivity(theirs)
+  if fake.activities[2].User != "bob" {
+    t.Errorf("expected activity to stay bob's, got %q", fake.activities[2].User)
+  }
+
+  var running []*Activity
+  running, err = db.FindRunningActivities()
+  if err != nil {
+    t.Fatal(err)
+  }
+  if len(running) != 2 || running[0].Name != "old" || running[1].Name != "mine" {
+    t.Errorf("expected old and mine to be running, got %v", running)
+  }
+
+  var all, between []*Activity
+  all, err = db.FindAllActivities()
+  if err != nil {
+    t.Fatal(err)
+  }
+  if len(all) != 2 || all[0].Name != "old" || all[1].Name != "mine" {
+    t.Errorf("expected only old and mine, got %v", all)
+  }
+  between, err = db.FindActivitiesBetween(when(2013, 4, 26, 0), when(2013, 4, 27, 0))
+  if err != nil {
+    t.Fatal(err)
+  }
+  if len(between) != 2 || between[0].Name != "old" || between[1].Name != "mine" {
+    t.Errorf("expected only old and mine, got %v", between)
+  }
+}
+
+func TestStartCommand_Run_LastWithUsers(t *testing.T) {
+  fake := &Memory{}
+  fake.SaveActivity(&Activity{Name: "mine", User: "alice", Start: when(2013, 4, 26, 9),
+    End: when(2013, 4, 26, 10)})
+  fake.SaveActivity(&Activity{Name: "theirs", User: "bob", Start: when(2013, 4, 26, 10),
+    End: when(2013, 4, 26, 11)})
+  db := &UserDatabase{Database: fake, User: "alice"}
+  c := fakeCmdClock{when(2013, 4, 26, 12)}
+
+  _, err := StartCommand{Last: true}.Run(c, db)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if fake.activities[3].Name != "mine" {
+    t.Errorf("expected alice's last activity to be copied, got %v", fake.activities[3])
+  }
+}
+
+func TestStopCommand_Run_WithUsers(t *testing.T) {
+  fake := &Memory{}
+  fake.SaveActivity(&Activity{Name: "theirs", User: "bob", Start: when(2013, 4, 26, 9)})
+  fake.SaveActivity(&Activity{Name: "mine", User: "alice", Start: when(2013, 4, 26, 10)})
+  db := &UserDatabase{Database: fake, User: "alice"}
+  c := fakeCmdClock{when(2013, 4, 26, 12)}
+
+  output, err := StopCommand{}.Run(c, db)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if output != "stopped activity 2" {
+    t.Errorf("unexpected output: %q", output)
+  }
+  if !fake.activities[1].IsRunning() {
+    t.Error("expected bob's activity to still be running")
+  }
+}
+
+func TestRestartCommand_Run_WithUsers(t *testing.T) {
+  fake := &Memory{}
+  fake.SaveActivity(&Activity{Name: "theirs", User: "bob", Start: when(2013, 4, 26, 9),
+    End: when(2013, 4, 26, 10)})
+  db := &UserDatabase{Database: fake, User: "alice"}
+  c := fakeCmdClock{when(2013, 4, 26, 12)}
+
+  _, err := RestartCommand{}.Run(c, db, "1")
+  if err != nil {
+    t.Fatal(err)
+  }
+  if fake.activities[2].Name != "theirs" || fake.activities[2].User != "alice" {
+    t.Errorf("expected the restarted activity to be alice's, got %v", fake.activities[2])
+  }
+  if fake.activities[1].User != "bob" {
+    t.Errorf("expected the original to stay bob's, got %q", fake.activities[1].User)
+  }
+}
+
+func TestTagsCommand_Run_WithUsers(t *testing.T) {
+  fake := &Memory{}
+  fake.SaveActivity(&Activity{Name: "theirs", User: "bob", Tags: []string{"mtg"},
+    Start: when(2013, 4, 26, 9), End: when(2013, 4, 26, 10)})
+  fake.SaveActivity(&Activity{Name: "mine", User: "alice", Tags: []string{"mtg"},
+    Start: when(2013, 4, 26, 10), End: when(2013, 4, 26, 11)})
+  fake.SaveRate(&Rate{"", "mtg", Money{5000, "USD"}, true})
+  db := &UserDatabase{Database: fake, User: "alice"}
+  c := fakeCmdClock{when(2013, 4, 26, 12)}
+
+  /* tags are shared like rates, so everyone's activities are renamed */
+  output, err := TagsCommand{}.Run(c, db, "rename", "mtg", "meetings")
+  if err != nil {
+    t.Fatal(err)
+  }
+  if output != "renamed tag mtg to meetings on 2 activities" {
+    t.Errorf("unexpected output: %q", output)
+  }
+  for id, activity := range fake.activities {
+    if activity.TagList() != "meetings" {
+      t.Errorf("expected activity %d to be tagged meetings, got %q", id, activity.Tags)
+    }
+  }
+  if fake.activities[1].User != "bob" {
+    t.Errorf("expected activity to stay bob's, got %q", fake.activities[1].User)
+  }
+}
+
+var listUsersTests = []struct {
+  options UserOptions
+  output string
+}{
+  {
+    UserOptions{User: "alice"},
+    "| id\t| name\t| project\t| tags\t| state\t| start\t| end\t| duration\t|\n" +
+    "| 1\t| old\t| \t| \t| stopped\t| 08:00\t| 09:00\t| 01h00m\t|\n" +
+    "| 3\t| mine\t| \t| \t| stopped\t| 10:00\t| 11:00\t| 01h00m\t|\n" +
+    "unsorted: 02h00m",
+  },
+  {
+    UserOptions{User: "bob"},
+    "| id\t| name\t| project\t| tags\t| state\t| start\t| end\t| duration\t|\n" +
+    "| 1\t| old\t| \t| \t| stopped\t| 08:00\t| 09:00\t| 01h00m\t|\n" +
+    "| 2\t| theirs\t| \t| \t| stopped\t| 09:00\t| 10:00\t| 01h00m\t|\n" +
+    "unsorted: 02h00m",
+  },
+  {
+    UserOptions{User: "alice", AllUsers: true},
+    "| user\t| id\t| name\t| project\t| tags\t| state\t| start\t| end\t| duration\t|\n" +
+    "| \t| 1\t| old\t| \t| \t| stopped\t| 08:00\t| 09:00\t| 01h00m\t|\n" +
+    "| bob\t| 2\t| theirs\t| \t| \t| stopped\t| 09:00\t| 10:00\t| 01h00m\t|\n" +
+    "| alice\t| 3\t| mine\t| \t| \t| stopped\t| 10:00\t| 11:00\t| 01h00m\t|\n" +
+    "unsorted: 03h00m",
+  },
+}
+
+func TestListCommand_Run_WithUsers(t *testing.T) {
+  db := &Memory{}
+  db.SaveActivity(&Activity{Name: "old", Start: when(2013, 4, 26, 8), End: when(2013, 4, 26, 9)})
+  db.SaveActivity(&Activity{Name: "theirs", User: "bob", Start: when(2013, 4, 26, 9),
+    End: when(2013, 4, 26, 10)})
+  db.SaveActivity(&Activity{Name: "mine", User: "alice", Start: when(2013, 4, 26, 10),
+    End: when(2013, 4, 26, 11)})
+  c := fakeCmdClock{when(2013, 4, 26, 12)}
+
+  for i, config := range listUsersTests {
+    /* the options replace the filter of the user's database */
+    cmd := ListCommand{UserOptions: config.options}
+    output, err := cmd.Run(c, &UserDatabase{Database: db, User: "alice"})
+    if err != nil {
+      t.Errorf("test %d: %s", i, err)
+      continue
+    }
+    outputOk, diff, checkErr := checkStringsEqual(config.output, output)
+    if checkErr != nil {
+      t.Errorf("test %d: couldn't create diff: %s", i, checkErr)
+    } else if !outputOk {
+      t.Errorf("test %d: bad output:\n%s", i, diff)
+    }
+  }
+}
+
+func TestServer_Report_WithUsers(t *testing.T) {
+  s, fake := newTestServer(
+    &Activity{Name: "mine", Project: "foo", User: "alice", Start: when(2013, 4, 26, 9),
+      End: when(2013, 4, 26, 10)},
+    &Activity{Name: "theirs", Project: "bar", User: "bob", Start: when(2013, 4, 26, 9),
+      End: when(2013, 4, 26, 11)},
+  )
+  s.Database = &UserDatabase{Database: fake, User: "alice"}
+
+  for _, path := range []string{"/activities", "/report"} {
+    w := serverTestRequest(s, "GET", path, "")
+    if w.Code != http.StatusOK {
+      t.Errorf("%s: expected status 200, got %d", path, w.Code)
+    } else if strings.Contains(w.Body.String(), "bar") || !strings.Contains(w.Body.String(), "foo") {
+      t.Errorf("%s: expected only alice's activities: %s", path, w.Body)
+    }
+  }
+}